@@ -7,6 +7,14 @@ package recon
 // Agent represents a recon daemon running on
 // a machine.
 type Agent struct {
-	UID      string `json:"uid"`
-	HostName string `json:"host_name"`
+	UID      string                 `json:"uid"`
+	HostName string                 `json:"host_name"`
+	Facts    map[string]interface{} `json:"facts,omitempty"` // host inventory, see package inventory
+
+	// Tags are user-defined, e.g. {"env": "prod", "role": "db"}.
+	Tags map[string]string `json:"tags,omitempty"`
+	// Labels are derived from the facts, e.g. {"os": "linux"},
+	// and overridden by the tags. They are attached to every
+	// event of the agent.
+	Labels map[string]string `json:"labels,omitempty"`
 }
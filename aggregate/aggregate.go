@@ -0,0 +1,158 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// Package aggregate summarizes the samples of a metric collector
+// taken during an interval, so that the agent can sample finely and
+// still report once per interval.
+//
+// The samples are the maps of the collectors, nested to any depth.
+// Each number in them, wherever it is found, is replaced by its min,
+// max, mean and 95th percentile over the samples. The other values,
+// such as the strings and the lists, are those of the last sample.
+package aggregate
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// sep separates the keys of the path to a number.
+const sep = "\x00"
+
+// Aggregator aggregates samples. The zero value is ready to use.
+type Aggregator struct {
+	n      int
+	values map[string][]float64 // keyed by the path to the numbers
+	last   interface{}
+}
+
+// Add adds a sample.
+func (a *Aggregator) Add(sample interface{}) {
+	if a.values == nil {
+		a.values = make(map[string][]float64)
+	}
+	a.n++
+	a.last = sample
+	Walk(sample, func(path []string, x float64) {
+		k := strings.Join(path, sep)
+		a.values[k] = append(a.values[k], x)
+	})
+}
+
+// Len returns the number of samples added since the last Reset.
+func (a *Aggregator) Len() int {
+	return a.n
+}
+
+// Reset drops the samples.
+func (a *Aggregator) Reset() {
+	a.n = 0
+	a.values = nil
+	a.last = nil
+}
+
+// Result returns the last sample with its numbers replaced by
+// their stats over all the samples, e.g.
+//
+//	map[string]float64{"min": 0.02, "max": 0.91, "mean": 0.12, "p95": 0.85}
+//
+// The maps are copied as map[string]interface{}. It returns nil if
+// there are no samples.
+func (a *Aggregator) Result() interface{} {
+	if a.n == 0 {
+		return nil
+	}
+	return a.result(reflect.ValueOf(a.last), nil)
+}
+
+func (a *Aggregator) result(v reflect.Value, path []string) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return v.Interface()
+		}
+		return a.result(v.Elem(), path)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			m[k.String()] = a.result(v.MapIndex(k), append(path[:len(path):len(path)], k.String()))
+		}
+		return m
+	}
+	if _, ok := number(v); ok {
+		return Stats(a.values[strings.Join(path, sep)])
+	}
+	return v.Interface()
+}
+
+// Walk calls f with the path to each number in the sample, i.e. the
+// keys of the maps it is nested in, and its value.
+func Walk(sample interface{}, f func(path []string, x float64)) {
+	walk(reflect.ValueOf(sample), nil, f)
+}
+
+func walk(v reflect.Value, path []string, f func(path []string, x float64)) {
+	if !v.IsValid() {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if !v.IsNil() {
+			walk(v.Elem(), path, f)
+		}
+		return
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		for _, k := range v.MapKeys() {
+			walk(v.MapIndex(k), append(path[:len(path):len(path)], k.String()), f)
+		}
+		return
+	}
+	if x, ok := number(v); ok {
+		f(path, x)
+	}
+}
+
+// number returns the value of v if it is a number.
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// Stats returns the min, max, mean and 95th percentile of the values.
+// The percentile is one of the values, found by the nearest rank.
+func Stats(values []float64) map[string]float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, x := range sorted {
+		sum += x
+	}
+	rank := (95*len(sorted)+99)/100 - 1 // ceil(0.95 n) - 1
+	return map[string]float64{
+		"min":  sorted[0],
+		"max":  sorted[len(sorted)-1],
+		"mean": sum / float64(len(sorted)),
+		"p95":  sorted[rank],
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package aggregate
+
+import (
+	"reflect"
+	"testing"
+)
+
+type data map[string]interface{}
+
+func TestAggregator(t *testing.T) {
+	var a Aggregator
+	if a.Result() != nil {
+		t.Errorf("got %v for no samples; want nil", a.Result())
+	}
+	for i := 1; i <= 4; i++ {
+		a.Add(data{
+			"load":     float64(i) / 4,
+			"hostname": "web1",
+			"disk": data{
+				"/dev/sda1": map[string]interface{}{"used": uint64(i * 100), "mounted_on": "/"},
+			},
+			"vmstat":    map[string]uint64{"oom_kills": 1},
+			"processes": []int{i},
+		})
+	}
+	if a.Len() != 4 {
+		t.Errorf("Len() = %d; want 4", a.Len())
+	}
+	want := map[string]interface{}{
+		"load":     map[string]float64{"min": 0.25, "max": 1, "mean": 0.625, "p95": 1},
+		"hostname": "web1",
+		"disk": map[string]interface{}{
+			"/dev/sda1": map[string]interface{}{
+				"used":       map[string]float64{"min": 100, "max": 400, "mean": 250, "p95": 400},
+				"mounted_on": "/",
+			},
+		},
+		"vmstat":    map[string]interface{}{"oom_kills": map[string]float64{"min": 1, "max": 1, "mean": 1, "p95": 1}},
+		"processes": []int{4},
+	}
+	if got := a.Result(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+
+	a.Reset()
+	a.Add(data{"load": 2})
+	want = map[string]interface{}{"load": map[string]float64{"min": 2, "max": 2, "mean": 2, "p95": 2}}
+	if got := a.Result(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after Reset got %v; want %v", got, want)
+	}
+}
+
+func TestStats(t *testing.T) {
+	var values []float64
+	for i := 100; i >= 1; i-- {
+		values = append(values, float64(i))
+	}
+	want := map[string]float64{"min": 1, "max": 100, "mean": 50.5, "p95": 95}
+	if got := Stats(values); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	if got := Stats(values[:20]); got["p95"] != 99 {
+		t.Errorf("p95 of 100 to 81 = %v; want 99", got["p95"])
+	}
+	if Stats(nil) != nil {
+		t.Error("want nil stats for no values")
+	}
+}
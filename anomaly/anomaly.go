@@ -0,0 +1,186 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// Package anomaly finds the values of metrics which deviate from
+// their usual ones, rather than those beyond a fixed threshold, so
+// that the same policy suits hosts of any size.
+//
+// A baseline of each metric, its exponentially weighted moving mean
+// and variance, is learned from its values. A value is anomalous when
+// its z-score, i.e. its distance from the mean in standard deviations,
+// is beyond a threshold. With seasonality, a metric also has a baseline
+// for each hour of the week, so that e.g. the nightly backups aren't
+// anomalous once they have been seen for a few weeks.
+package anomaly
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/codeignition/recon/internal/fileutil"
+)
+
+// Baseline is the exponentially weighted moving mean
+// and variance of the values of a metric.
+type Baseline struct {
+	Mean float64
+	Var  float64
+	N    int // the number of values
+}
+
+// Update adds the value x to the baseline. The weight alpha,
+// between 0 and 1, is the weight of x against the older values.
+func (b *Baseline) Update(x, alpha float64) {
+	if b.N == 0 {
+		b.Mean = x
+		b.Var = 0
+		b.N = 1
+		return
+	}
+	diff := x - b.Mean
+	incr := alpha * diff
+	b.Mean += incr
+	b.Var = (1 - alpha) * (b.Var + diff*incr)
+	b.N++
+}
+
+// StdDev returns the standard deviation of the baseline. It has a
+// floor, so that a change of a metric whose values have never varied
+// has a large but finite z-score.
+func (b *Baseline) StdDev() float64 {
+	return math.Max(math.Sqrt(b.Var), 1e-6*math.Max(1, math.Abs(b.Mean)))
+}
+
+// Z returns the z-score of the value x.
+func (b *Baseline) Z(x float64) float64 {
+	return (x - b.Mean) / b.StdDev()
+}
+
+// hoursPerWeek is the number of the seasonal baselines of a metric.
+const hoursPerWeek = 7 * 24
+
+// hourOfWeek returns the hour of the week of t in its location,
+// from 0 at midnight on Sunday.
+func hourOfWeek(t time.Time) int {
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// Detector keeps the baselines of the metrics and finds their
+// anomalous values. It is saved and loaded as JSON.
+type Detector struct {
+	Alpha      float64 // the weight of a value against the older ones
+	Seasonal   bool    // whether there is a baseline per hour of the week
+	MinSamples int     // the number of values a baseline needs to be used
+
+	Baselines map[string]*Baseline   // keyed by the metric
+	Seasons   map[string][]*Baseline `json:",omitempty"` // the baselines of the hours of the week
+}
+
+// NewDetector returns a Detector with no baselines.
+func NewDetector(alpha float64, seasonal bool, minSamples int) *Detector {
+	return &Detector{
+		Alpha:      alpha,
+		Seasonal:   seasonal,
+		MinSamples: minSamples,
+		Baselines:  make(map[string]*Baseline),
+		Seasons:    make(map[string][]*Baseline),
+	}
+}
+
+// Observe compares the value x of the metric at t with its baseline,
+// and then adds it to the baseline. It returns the baseline x was
+// compared with, which is that of the hour of the week of t if it has
+// enough values, and false if no baseline has enough values yet.
+func (d *Detector) Observe(metric string, t time.Time, x float64) (Baseline, bool) {
+	b, ok := d.Baselines[metric]
+	if !ok {
+		b = new(Baseline)
+		d.Baselines[metric] = b
+	}
+	cmp, ok := *b, b.N >= d.MinSamples
+	if d.Seasonal {
+		s, found := d.Seasons[metric]
+		if !found {
+			s = make([]*Baseline, hoursPerWeek)
+			for i := range s {
+				s[i] = new(Baseline)
+			}
+			d.Seasons[metric] = s
+		}
+		h := s[hourOfWeek(t)]
+		if h.N >= d.MinSamples {
+			cmp, ok = *h, true
+		}
+		h.Update(x, d.Alpha)
+	}
+	b.Update(x, d.Alpha)
+	if !ok {
+		return Baseline{}, false
+	}
+	return cmp, true
+}
+
+// Forget drops the baselines of the metrics for which keep
+// returns false, e.g. those of the filesystems unmounted.
+func (d *Detector) Forget(keep func(metric string) bool) {
+	for m := range d.Baselines {
+		if !keep(m) {
+			delete(d.Baselines, m)
+			delete(d.Seasons, m)
+		}
+	}
+}
+
+// Load loads the detector saved in the file.
+func Load(file string) (*Detector, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	d := new(Detector)
+	if err := json.Unmarshal(b, d); err != nil {
+		return nil, err
+	}
+	if d.Baselines == nil {
+		d.Baselines = make(map[string]*Baseline)
+	}
+	if d.Seasons == nil {
+		d.Seasons = make(map[string][]*Baseline)
+	}
+	for m, b := range d.Baselines {
+		if b == nil {
+			delete(d.Baselines, m)
+		}
+	}
+	for m, s := range d.Seasons {
+		if len(s) != hoursPerWeek {
+			delete(d.Seasons, m)
+			continue
+		}
+		for i := range s {
+			if s[i] == nil {
+				s[i] = new(Baseline)
+			}
+		}
+	}
+	return d, nil
+}
+
+// Save saves the detector in the file, creating its directory
+// if needed. The file is replaced atomically, so that a crash
+// while saving doesn't lose the baselines.
+func (d *Detector) Save(file string) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return fileutil.WriteFile(file, b, 0600)
+}
@@ -0,0 +1,116 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package anomaly
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBaseline(t *testing.T) {
+	var b Baseline
+	for i := 0; i < 1000; i++ {
+		// alternates between 9 and 11
+		b.Update(float64(10+2*(i%2)-1), 0.05)
+	}
+	if math.Abs(b.Mean-10) > 0.1 {
+		t.Errorf("Mean = %v; want about 10", b.Mean)
+	}
+	if math.Abs(b.StdDev()-1) > 0.1 {
+		t.Errorf("StdDev() = %v; want about 1", b.StdDev())
+	}
+	if z := b.Z(14); math.Abs(z-4) > 0.5 {
+		t.Errorf("Z(14) = %v; want about 4", z)
+	}
+
+	// A metric which has never varied.
+	b = Baseline{}
+	for i := 0; i < 10; i++ {
+		b.Update(0, 0.05)
+	}
+	if z := b.Z(1); math.IsInf(z, 0) || z < 1000 {
+		t.Errorf("Z(1) = %v; want a large finite z-score", z)
+	}
+}
+
+func TestDetector(t *testing.T) {
+	d := NewDetector(0.1, false, 5)
+	now := time.Date(2015, 6, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if _, ok := d.Observe("load", now, 1); ok {
+			t.Fatalf("value %d: want no baseline before 5 values", i)
+		}
+	}
+	b, ok := d.Observe("load", now, 1)
+	if !ok || b.Mean != 1 || b.N != 5 {
+		t.Errorf("got %+v, %v; want the baseline of 5 values of 1", b, ok)
+	}
+}
+
+func TestDetectorSeasonal(t *testing.T) {
+	d := NewDetector(0.2, true, 3)
+	monday := time.Date(2015, 6, 1, 2, 30, 0, 0, time.UTC)
+	// Busy at 2 every Monday, idle otherwise.
+	for week := 0; week < 4; week++ {
+		for h := 0; h < hoursPerWeek; h++ {
+			at := monday.Add(time.Duration(week*hoursPerWeek+h) * time.Hour)
+			v := 1.0
+			if h == 0 {
+				v = 50
+			}
+			d.Observe("load", at, v)
+		}
+	}
+	at := monday.Add(4 * hoursPerWeek * time.Hour)
+	b, ok := d.Observe("load", at, 50)
+	if !ok {
+		t.Fatal("want a baseline")
+	}
+	if z := b.Z(50); math.Abs(z) > 3 {
+		t.Errorf("z-score of the usual busy hour = %v; want it within 3", z)
+	}
+	b, _ = d.Observe("load", at.Add(time.Hour), 50)
+	if z := b.Z(50); z < 3 {
+		t.Errorf("z-score of a busy idle hour = %v; want it beyond 3", z)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anomaly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := NewDetector(0.1, true, 5)
+	now := time.Date(2015, 6, 1, 10, 0, 0, 0, time.UTC)
+	d.Observe("load", now, 1)
+	d.Observe("disk./dev/sda1.used_ratio", now, 0.4)
+
+	file := filepath.Join(dir, "state", "anomaly.json")
+	if err := d.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Errorf("got  %+v\nwant %+v", got, d)
+	}
+
+	d.Forget(func(m string) bool { return m == "load" })
+	if _, ok := d.Baselines["disk./dev/sda1.used_ratio"]; ok {
+		t.Error("want the forgotten baseline dropped")
+	}
+	if _, ok := d.Seasons["load"]; !ok {
+		t.Error("want the kept seasonal baselines")
+	}
+}
@@ -0,0 +1,399 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// Package alert evaluates alerting rules against the policy events
+// on the agent itself, and notifies the channels of the rules, so
+// that a host can still raise the alarm when marksman is down.
+//
+// A rule compares a field of the events of a policy with a value,
+// e.g. "status" == "critical", the status of the event, "labels.team"
+// == "web", one of its labels, or "system.load_average.load1" > 4 and
+// "data.status" == "failure", fields of the event data. It fires once
+// the comparison has held for its "for" duration, and resolves when it
+// no longer holds. The channels are notified when a rule fires, every
+// repeat interval while it keeps firing, and when it resolves; an
+// event which doesn't change the state of a rule notifies nobody.
+package alert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/codeignition/recon/policy"
+)
+
+// Config is the alerting configuration of the agent.
+type Config struct {
+	Rules    []Rule    `json:",omitempty"`
+	Channels []Channel `json:",omitempty"`
+}
+
+// Rule is an alerting rule. The durations are strings such as "5m".
+type Rule struct {
+	Name     string
+	Policy   string   `json:",omitempty"` // the name of the policy whose events are checked; all if empty
+	Field    string   // "status", "labels.<key>" or the dot separated keys of the field in the event data, optionally prefixed with "data."
+	Op       string   // one of ==, !=, <, <=, > and >=
+	Value    string   // the value the field is compared with
+	For      string   `json:",omitempty"` // how long the comparison must hold before the rule fires
+	Repeat   string   `json:",omitempty"` // the interval of the repeated notifications while firing; none if empty
+	Severity string   `json:",omitempty"` // e.g. "warning" or "critical", the default
+	Channels []string // the names of the channels notified
+	Message  string   `json:",omitempty"` // the template of the message; DefaultMessage if empty
+}
+
+// DefaultMessage is the template of the messages of the rules which
+// have none. Its data is the Notification.
+const DefaultMessage = `[{{.Status}}] {{.Rule}} on {{.HostName}}: {{.Field}} is {{.Value}} ({{.Op}} {{.Threshold}})`
+
+var defaultMessage = template.Must(template.New("default").Parse(DefaultMessage))
+
+// Status is the status of a notification.
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Notification is sent to the channels of a rule.
+type Notification struct {
+	Rule      string
+	Status    string // StatusFiring or StatusResolved
+	Severity  string
+	Policy    string
+	AgentUID  string
+	HostName  string
+	Field     string
+	Op        string
+	Threshold string      // the value of the rule
+	Value     interface{} // of the field in the last event
+	Since     time.Time   // when the rule started firing
+	Time      time.Time   // of the last event
+	Message   string
+}
+
+// rule is a parsed Rule.
+type rule struct {
+	Rule
+	number   float64 // Value, if it is a number
+	isNumber bool
+	forDur   time.Duration
+	repeat   time.Duration
+	message  *template.Template
+
+	// The notifications not sent yet, guarded by Alerter.mu.
+	// They are sent in order, one at a time, so that a resolved
+	// notification never overtakes the firing one before it.
+	queue   []Notification
+	sending bool
+}
+
+// state is the state of a rule for a policy.
+type state struct {
+	pending  time.Time // when the comparison started holding
+	firing   bool
+	since    time.Time // when it started firing
+	notified time.Time // when the last notification was sent
+}
+
+// Alerter evaluates the rules against the events.
+type Alerter struct {
+	hostName  string
+	rules     []*rule
+	notifiers map[string]Notifier // keyed by the name of the channel
+
+	mu     sync.Mutex
+	states map[string]*state // keyed by the rule and the policy
+}
+
+// New returns an Alerter with the rules and channels of c.
+// The notifications name the host hostName.
+func New(c Config, hostName string) (*Alerter, error) {
+	a := &Alerter{
+		hostName:  hostName,
+		notifiers: make(map[string]Notifier),
+		states:    make(map[string]*state),
+	}
+	for _, ch := range c.Channels {
+		if _, ok := a.notifiers[ch.Name]; ok {
+			return nil, fmt.Errorf("alert channel %q is defined twice", ch.Name)
+		}
+		n, err := NewNotifier(ch)
+		if err != nil {
+			return nil, err
+		}
+		a.notifiers[ch.Name] = n
+	}
+	names := make(map[string]bool)
+	for _, r := range c.Rules {
+		if names[r.Name] {
+			return nil, fmt.Errorf("alert rule %q is defined twice", r.Name)
+		}
+		names[r.Name] = true
+		pr, err := parseRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("alert rule %q: %v", r.Name, err)
+		}
+		for _, ch := range r.Channels {
+			if _, ok := a.notifiers[ch]; !ok {
+				return nil, fmt.Errorf("alert rule %q: unknown channel %q", r.Name, ch)
+			}
+		}
+		a.rules = append(a.rules, pr)
+	}
+	return a, nil
+}
+
+// parseRule checks the rule r and parses its values.
+func parseRule(r Rule) (*rule, error) {
+	if r.Name == "" {
+		return nil, errors.New("rule name can't be empty")
+	}
+	if r.Field == "" {
+		return nil, errors.New("field can't be empty")
+	}
+	pr := &rule{Rule: r}
+	if pr.Severity == "" {
+		pr.Severity = "critical"
+	}
+	f, err := strconv.ParseFloat(r.Value, 64)
+	pr.number, pr.isNumber = f, err == nil
+	switch r.Op {
+	case "==", "!=":
+	case "<", "<=", ">", ">=":
+		if !pr.isNumber {
+			return nil, fmt.Errorf("%s needs a number, not %q", r.Op, r.Value)
+		}
+	default:
+		return nil, fmt.Errorf("unknown operator %q", r.Op)
+	}
+	if r.For != "" {
+		if pr.forDur, err = time.ParseDuration(r.For); err != nil {
+			return nil, err
+		}
+	}
+	if r.Repeat != "" {
+		if pr.repeat, err = time.ParseDuration(r.Repeat); err != nil {
+			return nil, err
+		}
+	}
+	pr.message = defaultMessage
+	if r.Message != "" {
+		if pr.message, err = template.New(r.Name).Parse(r.Message); err != nil {
+			return nil, err
+		}
+	}
+	return pr, nil
+}
+
+// Process evaluates the rules against the event e
+// and sends the resulting notifications. The rules are
+// evaluated against the suppressed events as well, so that
+// they resolve during a silence, but nobody is notified.
+func (a *Alerter) Process(e policy.Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ns := a.evaluate(e)
+	if e.Suppressed {
+		return
+	}
+	for _, n := range ns {
+		for _, r := range a.rules {
+			if r.Name != n.Rule {
+				continue
+			}
+			r.queue = append(r.queue, n)
+			if !r.sending {
+				r.sending = true
+				go a.send(r)
+			}
+		}
+	}
+}
+
+// send sends the queued notifications of the rule r
+// to its channels until the queue is empty.
+func (a *Alerter) send(r *rule) {
+	for {
+		a.mu.Lock()
+		if len(r.queue) == 0 {
+			r.sending = false
+			a.mu.Unlock()
+			return
+		}
+		n := r.queue[0]
+		r.queue = r.queue[1:]
+		a.mu.Unlock()
+
+		for _, ch := range r.Channels {
+			if err := a.notifiers[ch].Notify(n); err != nil {
+				log.Printf("alert %s: channel %s: %v", n.Rule, ch, err)
+			}
+		}
+	}
+}
+
+// evaluate evaluates the rules against the event e, updates
+// their states and returns the notifications to send.
+// a.mu must be held.
+func (a *Alerter) evaluate(e policy.Event) []Notification {
+	var ns []Notification
+	for _, r := range a.rules {
+		if r.Policy != "" && r.Policy != e.PolicyName {
+			continue
+		}
+		v, ok := value(e, r.Field)
+		if !ok {
+			continue
+		}
+		key := r.Name + "\x00" + e.PolicyName
+		s, ok := a.states[key]
+		if !ok {
+			s = new(state)
+			a.states[key] = s
+		}
+
+		status := ""
+		switch {
+		case r.holds(v):
+			if s.pending.IsZero() {
+				s.pending = e.Time
+			}
+			switch {
+			case !s.firing && e.Time.Sub(s.pending) >= r.forDur:
+				s.firing = true
+				s.since = e.Time
+				status = StatusFiring
+			case s.firing && r.repeat > 0 && e.Time.Sub(s.notified) >= r.repeat:
+				status = StatusFiring
+			}
+		case s.firing:
+			status = StatusResolved
+			fallthrough
+		default:
+			delete(a.states, key)
+		}
+		if status == "" {
+			continue
+		}
+		s.notified = e.Time
+		n := Notification{
+			Rule:      r.Name,
+			Status:    status,
+			Severity:  r.Severity,
+			Policy:    e.PolicyName,
+			AgentUID:  e.AgentUID,
+			HostName:  a.hostName,
+			Field:     r.Field,
+			Op:        r.Op,
+			Threshold: r.Value,
+			Value:     v,
+			Since:     s.since,
+			Time:      e.Time,
+		}
+		var b bytes.Buffer
+		if err := r.message.Execute(&b, n); err != nil {
+			log.Printf("alert %s: %v", r.Name, err)
+			b.Reset()
+			defaultMessage.Execute(&b, n)
+		}
+		n.Message = b.String()
+		ns = append(ns, n)
+	}
+	return ns
+}
+
+// holds reports whether the comparison of the rule holds for the value v.
+func (r *rule) holds(v interface{}) bool {
+	x, isNumber := number(v)
+	if !isNumber || !r.isNumber {
+		s := fmt.Sprint(v)
+		switch r.Op {
+		case "==":
+			return s == r.Value
+		case "!=":
+			return s != r.Value
+		}
+		return false
+	}
+	switch r.Op {
+	case "==":
+		return x == r.number
+	case "!=":
+		return x != r.number
+	case "<":
+		return x < r.number
+	case "<=":
+		return x <= r.number
+	case ">":
+		return x > r.number
+	case ">=":
+		return x >= r.number
+	}
+	return false
+}
+
+// value returns the value of the field of the event e. The field
+// "status" is the status of the event, which is the same for all the
+// policy types, and "labels.<key>" one of its labels. The other
+// fields, which may be prefixed with "data." as well, are looked up
+// in the event data.
+func value(e policy.Event, field string) (interface{}, bool) {
+	switch {
+	case field == "status" && e.Status != "":
+		return e.Status, true
+	case strings.HasPrefix(field, "labels."):
+		if v, ok := e.Labels[strings.TrimPrefix(field, "labels.")]; ok {
+			return v, true
+		}
+	case strings.HasPrefix(field, "data."):
+		if v, ok := lookup(e.Data, strings.TrimPrefix(field, "data.")); ok {
+			return v, true
+		}
+	}
+	return lookup(e.Data, field)
+}
+
+// lookup returns the value of the field, the dot separated keys
+// of the maps it is nested in, in the event data.
+func lookup(data interface{}, field string) (interface{}, bool) {
+	v := reflect.ValueOf(data)
+	for _, k := range strings.Split(field, ".") {
+		for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) {
+			if v.IsNil() {
+				return nil, false
+			}
+			v = v.Elem()
+		}
+		if !v.IsValid() || v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		v = v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+	}
+	if !v.IsValid() {
+		return nil, false
+	}
+	return v.Interface(), true
+}
+
+// number returns the value of v if it is a number.
+func number(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,348 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package alert
+
+import (
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codeignition/recon/policy"
+	"github.com/codeignition/recon/policy/handlers"
+	"golang.org/x/net/context"
+)
+
+func TestLookup(t *testing.T) {
+	type data map[string]interface{}
+	d := map[string]interface{}{
+		"status": "critical",
+		"system": data{
+			"load_average": map[string]float64{"load1": 4.5},
+		},
+	}
+	tests := []struct {
+		field string
+		want  interface{}
+		ok    bool
+	}{
+		{"status", "critical", true},
+		{"system.load_average.load1", 4.5, true},
+		{"system.load_average.load5", nil, false},
+		{"status.code", nil, false},
+		{"missing", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := lookup(d, tt.field)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("lookup(%q) = %v, %v; want %v, %v", tt.field, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	e := policy.Event{
+		Status: policy.StatusCritical,
+		Labels: map[string]string{"team": "web"},
+		Data:   map[string]interface{}{"status": "failure", "attempts": 1},
+	}
+	tests := []struct {
+		field string
+		want  interface{}
+		ok    bool
+	}{
+		{"status", policy.StatusCritical, true},
+		{"data.status", "failure", true},
+		{"labels.team", "web", true},
+		{"labels.env", nil, false},
+		{"attempts", 1, true},
+		{"data.attempts", 1, true},
+	}
+	for _, tt := range tests {
+		got, ok := value(e, tt.field)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("value(%q) = %v, %v; want %v, %v", tt.field, got, ok, tt.want, tt.ok)
+		}
+	}
+	// the events made before they had a status
+	if got, _ := value(policy.Event{Data: e.Data}, "status"); got != "failure" {
+		t.Errorf("got status %v; want the one of the data", got)
+	}
+}
+
+func TestRuleHolds(t *testing.T) {
+	tests := []struct {
+		op, value string
+		v         interface{}
+		want      bool
+	}{
+		{"==", "critical", "critical", true},
+		{"!=", "ok", "critical", true},
+		{"==", "ok", "critical", false},
+		{">", "4", 4.5, true},
+		{">", "4", 4, false},
+		{">=", "4", uint64(4), true},
+		{"<", "0.1", 0.05, true},
+		{"==", "3", 3, true},
+		{"<", "4", "3", false}, // not a number
+	}
+	for _, tt := range tests {
+		r, err := parseRule(Rule{Name: "r", Field: "f", Op: tt.op, Value: tt.value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.holds(tt.v); got != tt.want {
+			t.Errorf("%v %s %s = %v; want %v", tt.v, tt.op, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []Config{
+		{Rules: []Rule{{Name: "r", Field: "f", Op: "~", Value: "1"}}},
+		{Rules: []Rule{{Name: "r", Field: "f", Op: ">", Value: "high"}}},
+		{Rules: []Rule{{Name: "r", Field: "f", Op: "==", Value: "1", For: "soon"}}},
+		{Rules: []Rule{{Name: "r", Field: "f", Op: "==", Value: "1", Channels: []string{"pager"}}}},
+		{Rules: []Rule{{Name: "r", Field: "f", Op: "==", Value: "1", Message: "{{.Rule"}}},
+		{Channels: []Channel{{Name: "hook", Type: "webhook"}}},
+		{Channels: []Channel{{Name: "pager", Type: "pager"}}},
+	}
+	for i, c := range tests {
+		if _, err := New(c, "web1"); err == nil {
+			t.Errorf("%d: want an error", i)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	a, err := New(Config{
+		Rules: []Rule{
+			{
+				Name:    "load",
+				Policy:  "sys",
+				Field:   "load1",
+				Op:      ">",
+				Value:   "4",
+				For:     "10s",
+				Repeat:  "1m",
+				Message: "{{.Rule}} {{.Status}} at {{.Value}}",
+			},
+		},
+	}, "web1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2015, 6, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		after  time.Duration
+		policy string
+		load   float64
+		want   []string // the messages
+	}{
+		{0, "sys", 5, nil}, // pending
+		{5 * time.Second, "sys", 5, nil},
+		{10 * time.Second, "sys", 5, []string{"load firing at 5"}},
+		{20 * time.Second, "sys", 6, nil}, // deduplicated
+		{30 * time.Second, "other", 9, nil},
+		{70 * time.Second, "sys", 7, []string{"load firing at 7"}}, // repeated
+		{80 * time.Second, "sys", 1, []string{"load resolved at 1"}},
+		{90 * time.Second, "sys", 1, nil},
+		{100 * time.Second, "sys", 5, nil}, // pending again
+		{105 * time.Second, "sys", 1, nil}, // never fired
+	}
+	for _, tt := range tests {
+		e := policy.Event{
+			Time:       start.Add(tt.after),
+			PolicyName: tt.policy,
+			Data:       map[string]interface{}{"load1": tt.load},
+		}
+		var got []string
+		for _, n := range a.evaluate(e) {
+			got = append(got, n.Message)
+			if n.HostName != "web1" || n.Severity != "critical" {
+				t.Errorf("got %+v; want the host and default severity", n)
+			}
+			if !n.Since.Equal(start.Add(10 * time.Second)) {
+				t.Errorf("got since %v; want %v", n.Since, start.Add(10*time.Second))
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("after %v: got %q; want %q", tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateStatus(t *testing.T) {
+	a, err := New(Config{
+		Rules: []Rule{{Name: "down", Field: "status", Op: "==", Value: "critical", Severity: "warning"}},
+	}, "web1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2015, 6, 1, 10, 0, 0, 0, time.UTC)
+	for i, tt := range []struct {
+		policy, status string
+		want           string
+	}{
+		{"db", "critical", StatusFiring},
+		{"web", "critical", StatusFiring}, // alerts per policy
+		{"db", "critical", ""},
+		{"db", "ok", StatusResolved},
+		{"web", "warning", StatusResolved},
+	} {
+		ns := a.evaluate(policy.Event{
+			Time:       now,
+			PolicyName: tt.policy,
+			Status:     tt.status,
+			// the status in the data of e.g. a tcp policy
+			Data: map[string]interface{}{"status": "failure"},
+		})
+		var got string
+		if len(ns) > 0 {
+			got = ns[0].Status
+			if want := "[" + got + "] down on web1: status is " + tt.status + " (== critical)"; ns[0].Message != want {
+				t.Errorf("%d: got message %q; want %q", i, ns[0].Message, want)
+			}
+		}
+		if got != tt.want || len(ns) > 1 {
+			t.Errorf("%d: got %d notifications %q; want %q", i, len(ns), got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateTCP(t *testing.T) {
+	a, err := New(Config{
+		Rules: []Rule{
+			{Name: "down", Policy: "web", Field: "status", Op: "==", Value: "critical"},
+			{Name: "failure", Policy: "web", Field: "data.status", Op: "==", Value: "failure"},
+			{Name: "team", Policy: "web", Field: "labels.team", Op: "==", Value: "web"},
+		},
+	}, "web1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := handlers.TCP(ctx, policy.Policy{
+		Name:   "web",
+		Type:   "tcp",
+		Labels: map[string]string{"team": "web"},
+		M:      map[string]string{"address": l.Addr().String(), "interval": "10ms", "timeout": "1s"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := func(e policy.Event) []string {
+		var names []string
+		for _, n := range a.evaluate(e) {
+			names = append(names, n.Rule+" "+n.Status)
+		}
+		return names
+	}
+
+	if got, want := rules(<-events), []string{"team firing"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("while listening: got %q; want %q", got, want)
+	}
+	l.Close()
+	e := <-events
+	for e.Status == policy.StatusOK { // dialed before the close
+		e = <-events
+	}
+	if got, want := rules(e), []string{"down firing", "failure firing"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after closing: got %q; want %q", got, want)
+	}
+}
+
+// recorder is a Notifier which records the statuses it is notified of.
+type recorder struct {
+	mu       sync.Mutex
+	statuses []string
+	done     chan struct{} // closed after n notifications
+	n        int
+}
+
+func (r *recorder) Notify(n Notification) error {
+	if n.Status == StatusFiring {
+		time.Sleep(10 * time.Millisecond) // e.g. a slow SMTP server
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = append(r.statuses, n.Status)
+	if len(r.statuses) == r.n {
+		close(r.done)
+	}
+	return nil
+}
+
+func TestProcessOrder(t *testing.T) {
+	a, err := New(Config{
+		Rules: []Rule{{Name: "down", Field: "status", Op: "==", Value: "critical"}},
+	}, "web1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recorder{done: make(chan struct{}), n: 4}
+	a.notifiers["rec"] = rec
+	a.rules[0].Channels = []string{"rec"}
+
+	now := time.Date(2015, 6, 1, 10, 0, 0, 0, time.UTC)
+	for _, status := range []string{policy.StatusCritical, policy.StatusOK, policy.StatusCritical, policy.StatusOK} {
+		a.Process(policy.Event{
+			Time:       now,
+			PolicyName: "db",
+			Status:     status,
+		})
+	}
+	select {
+	case <-rec.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the notifications")
+	}
+	want := []string{StatusFiring, StatusResolved, StatusFiring, StatusResolved}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if !reflect.DeepEqual(rec.statuses, want) {
+		t.Errorf("got %q; want %q", rec.statuses, want)
+	}
+}
+
+func TestProcessSuppressed(t *testing.T) {
+	a, err := New(Config{
+		Rules: []Rule{{Name: "down", Field: "status", Op: "==", Value: "critical"}},
+	}, "web1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recorder{done: make(chan struct{}), n: 2}
+	a.notifiers["rec"] = rec
+	a.rules[0].Channels = []string{"rec"}
+
+	now := time.Date(2015, 6, 1, 10, 0, 0, 0, time.UTC)
+	for _, e := range []policy.Event{
+		{Time: now, PolicyName: "db", Status: policy.StatusCritical},
+		// resolved during a silence
+		{Time: now, PolicyName: "db", Status: policy.StatusOK, Suppressed: true},
+		{Time: now, PolicyName: "db", Status: policy.StatusCritical},
+	} {
+		a.Process(e)
+	}
+	select {
+	case <-rec.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the notifications")
+	}
+	want := []string{StatusFiring, StatusFiring}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if !reflect.DeepEqual(rec.statuses, want) {
+		t.Errorf("got %q; want %q", rec.statuses, want)
+	}
+}
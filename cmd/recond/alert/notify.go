@@ -0,0 +1,156 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/syslog"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Timeout is the time given to a channel to take a notification.
+var Timeout = 10 * time.Second
+
+// Channel is a notification channel.
+type Channel struct {
+	Name string
+	Type string // "webhook", "smtp" or "syslog"
+
+	// URL is the URL to which a webhook posts the notifications as JSON.
+	URL string `json:",omitempty"`
+
+	// Addr is the host:port of the SMTP server, or of the syslog
+	// server if Network is set. The local syslog is used otherwise.
+	Addr    string `json:",omitempty"`
+	Network string `json:",omitempty"` // e.g. "udp" or "tcp"
+
+	// The sender and recipients of the emails, and
+	// the credentials of the SMTP server, if any.
+	From     string   `json:",omitempty"`
+	To       []string `json:",omitempty"`
+	Username string   `json:",omitempty"`
+	Password string   `json:",omitempty"`
+}
+
+// Notifier notifies a channel.
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// NewNotifier returns the Notifier of the channel c.
+func NewNotifier(c Channel) (Notifier, error) {
+	if c.Name == "" {
+		return nil, errors.New("alert channel name can't be empty")
+	}
+	switch c.Type {
+	case "webhook":
+		if c.URL == "" {
+			return nil, fmt.Errorf("alert channel %q: URL can't be empty", c.Name)
+		}
+		return webhook{url: c.URL, client: &http.Client{Timeout: Timeout}}, nil
+	case "smtp":
+		if c.Addr == "" || c.From == "" || len(c.To) == 0 {
+			return nil, fmt.Errorf("alert channel %q: Addr, From and To can't be empty", c.Name)
+		}
+		return mailer(c), nil
+	case "syslog":
+		return syslogger(c), nil
+	default:
+		return nil, fmt.Errorf("alert channel %q: unknown type %q", c.Name, c.Type)
+	}
+}
+
+// webhook posts the notifications as JSON.
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+func (w webhook) Notify(n Notification) error {
+	b, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// mailer emails the notifications.
+type mailer Channel
+
+func (m mailer) Notify(n Notification) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&b, "Subject: [%s] %s on %s\r\n", n.Status, n.Rule, n.HostName)
+	fmt.Fprintf(&b, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "%s\r\n", strings.Replace(n.Message, "\n", "\r\n", -1))
+
+	// smtp.SendMail has no timeout of its own.
+	errc := make(chan error, 1)
+	go func() {
+		errc <- smtp.SendMail(m.Addr, auth, m.From, m.To, b.Bytes())
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(Timeout):
+		return fmt.Errorf("smtp: %s timed out", m.Addr)
+	}
+}
+
+// syslogger logs the notifications to syslog, at the priority of their
+// severity, or at the notice one when they resolve.
+type syslogger Channel
+
+func (s syslogger) Notify(n Notification) error {
+	w, err := syslog.Dial(s.Network, s.Addr, syslog.LOG_DAEMON|syslog.LOG_NOTICE, "recond")
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if n.Status == StatusResolved {
+		return w.Notice(n.Message)
+	}
+	switch n.Severity {
+	case "emergency":
+		return w.Emerg(n.Message)
+	case "alert":
+		return w.Alert(n.Message)
+	case "critical":
+		return w.Crit(n.Message)
+	case "error":
+		return w.Err(n.Message)
+	case "warning":
+		return w.Warning(n.Message)
+	case "info":
+		return w.Info(n.Message)
+	default:
+		return w.Notice(n.Message)
+	}
+}
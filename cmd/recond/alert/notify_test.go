@@ -0,0 +1,164 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package alert
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var notification = Notification{
+	Rule:     "down",
+	Status:   StatusFiring,
+	Severity: "critical",
+	Policy:   "web",
+	HostName: "web1",
+	Time:     time.Date(2015, 6, 1, 10, 0, 0, 0, time.UTC),
+	Message:  "web is down",
+}
+
+func TestWebhook(t *testing.T) {
+	got := make(chan Notification, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		got <- n
+	}))
+	defer ts.Close()
+
+	n, err := NewNotifier(Channel{Name: "hook", Type: "webhook", URL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(notification); err != nil {
+		t.Fatal(err)
+	}
+	if g := <-got; g.Rule != "down" || g.Message != "web is down" || !g.Time.Equal(notification.Time) {
+		t.Errorf("got %+v; want %+v", g, notification)
+	}
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	n, _ = NewNotifier(Channel{Name: "hook", Type: "webhook", URL: missing.URL})
+	if err := n.Notify(notification); err == nil {
+		t.Error("want an error for the 404")
+	}
+}
+
+// smtpServer is a local SMTP server which accepts one message
+// and sends its DATA on the returned channel.
+func smtpServer(t *testing.T) (addr string, data <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := make(chan string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "220 localhost ESMTP\r\n")
+		var body []string
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if inData {
+				if line == "." {
+					inData = false
+					c <- strings.Join(body, "\n")
+					fmt.Fprintf(conn, "250 OK\r\n")
+					continue
+				}
+				body = append(body, line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				fmt.Fprintf(conn, "250 localhost\r\n")
+			case "DATA":
+				inData = true
+				fmt.Fprintf(conn, "354 go ahead\r\n")
+			case "QUIT":
+				fmt.Fprintf(conn, "221 bye\r\n")
+				return
+			default:
+				fmt.Fprintf(conn, "250 OK\r\n")
+			}
+		}
+	}()
+	return l.Addr().String(), c
+}
+
+func TestMailer(t *testing.T) {
+	addr, data := smtpServer(t)
+	n, err := NewNotifier(Channel{
+		Name: "ops",
+		Type: "smtp",
+		Addr: addr,
+		From: "recond@web1",
+		To:   []string{"ops@example.com", "oncall@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(notification); err != nil {
+		t.Fatal(err)
+	}
+	got := <-data
+	for _, want := range []string{
+		"To: ops@example.com, oncall@example.com",
+		"Subject: [firing] down on web1",
+		"\n\nweb is down",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got message\n%s\nwant it to contain %q", got, want)
+		}
+	}
+}
+
+func TestSyslogger(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	n, err := NewNotifier(Channel{Name: "log", Type: "syslog", Network: "udp", Addr: c.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(notification); err != nil {
+		t.Fatal(err)
+	}
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 1024)
+	k, _, err := c.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// LOG_DAEMON|LOG_CRIT is 3<<3 | 2.
+	got := string(b[:k])
+	if !strings.HasPrefix(got, "<26>") || !strings.HasSuffix(strings.TrimSpace(got), "web is down") {
+		t.Errorf("got %q; want a critical daemon message", got)
+	}
+}
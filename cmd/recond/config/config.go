@@ -13,27 +13,47 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/codeignition/recon/cmd/recond/alert"
 	"github.com/codeignition/recon/internal/fileutil"
 	"github.com/codeignition/recon/policy"
 )
 
-const configFileName = ".recond.json"
+const (
+	stateFileName  = "recond.json"  // in StateDir
+	legacyFileName = ".recond.json" // in the home directory, of the older versions
+)
+
+// Dir is the directory of the config files, recond.yaml, .yml or .json
+// and the drop-in files in conf.d (see LoadDir), which are owned by the
+// administrator or configuration management tools; the agent never
+// writes to it. It is $RECOND_CONFIG if set.
+var Dir = "/etc/recond"
+
+// StateDir is the directory in which the agent keeps its state: its
+// UID, the policies and silences it received from marksman, and the
+// state of the policies across restarts.
+var StateDir = "/var/lib/recond"
 
-// config file path in the local machine
-var configPath string
+// legacyPath is the path of the config file of the older versions,
+// which is migrated to StateDir. It is empty if there is no home.
+var legacyPath string
 
 func init() {
-	usr, err := user.Current()
-	if err != nil {
-		log.Fatalln(err)
+	if d := os.Getenv("RECOND_CONFIG"); d != "" {
+		Dir = d
+	}
+	if usr, err := user.Current(); err == nil {
+		legacyPath = filepath.Join(usr.HomeDir, legacyFileName)
 	}
-	configPath = filepath.Join(usr.HomeDir, configFileName)
 }
 
 // Config represents the configuration for the recond
@@ -43,19 +63,61 @@ type Config struct {
 	UID          string // Unique Identifier to register with marksman
 	HostName     string
 	PolicyConfig policy.Config
+	Alerts       alert.Config // evaluated on the agent, so that it alerts when marksman is down
+	Silences     policy.Silences
+	Tags         map[string]string // user-defined, e.g. {"env": "prod"}, attached to the agent and its events
+
+	// Files are the config files in Dir, merged. They
+	// aren't saved, since the agent doesn't own them.
+	Files File `json:"-"`
 }
 
-// Init initializes and returns a Config. i.e. if the config file doesn't exist,
-// it generates an new UID, creates the config file and returns the corresponding Config.
+// Init initializes and returns a Config. i.e. if the state file doesn't exist,
+// it generates an new UID, creates the state file and returns the corresponding Config.
+// The config files in Dir are loaded into its Files.
+//
+// If the state file is corrupt or missing, but its backup, the previous
+// generation kept by Save, is fine, it is restored from the backup. If
+// both are corrupt, Init fails rather than generating a new UID, which
+// would register the agent again as a new one. If neither exists, the
+// config file of the older versions is migrated, if any.
 func Init() (*Config, error) {
-	if fileutil.Exists(configPath) {
-		f, err := os.Open(configPath)
-		if err != nil {
-			return nil, err
-		}
-		defer f.Close()
-		return parseConfig(f)
+	if err := os.MkdirAll(StateDir, 0700); err != nil {
+		return nil, err
+	}
+	c, err := loadState()
+	if err != nil {
+		return nil, err
 	}
+	f, err := LoadDir(Dir)
+	if err != nil {
+		return nil, err
+	}
+	c.Files = *f
+	return c, nil
+}
+
+// loadState loads the config from the state file, restoring or
+// migrating it, or creating a new one, as described for Init.
+func loadState() (*Config, error) {
+	c, err := load(statePath())
+	if err == nil {
+		return c, nil
+	}
+	b, berr := load(backupPath())
+	switch {
+	case berr == nil:
+		log.Printf("config: restoring %s from its backup: %v", statePath(), err)
+		return b, b.Save()
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("config: %v; its backup: %v; fix or remove them to register as a new agent", err, berr)
+	case !os.IsNotExist(berr):
+		return nil, fmt.Errorf("config: %s is missing and its backup is corrupt: %v", statePath(), berr)
+	}
+	if c, err := migrate(); c != nil || err != nil {
+		return c, err
+	}
+
 	uid, err := generateUID()
 	if err != nil {
 		return nil, err
@@ -64,7 +126,7 @@ func Init() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	c := &Config{
+	c = &Config{
 		UID:      uid,
 		HostName: h,
 	}
@@ -72,43 +134,86 @@ func Init() (*Config, error) {
 	return c, err
 }
 
-// Save saves the config in a configuration file.
-// If it already exists, it removes it and writes it freshly.
-// Make sure you lock and unlock the config while calling Save.
-func (c *Config) Save() error {
-	if fileutil.Exists(configPath) {
-		if err := os.Remove(configPath); err != nil {
-			return err
-		}
+// migrate saves the config file of the older versions, if any, as the
+// state file, keeping its UID. It returns nil if there is none.
+func migrate() (*Config, error) {
+	if legacyPath == "" {
+		return nil, nil
 	}
+	c, err := load(legacyPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: migrating %v", err)
+	}
+	if err := c.Save(); err != nil {
+		return nil, err
+	}
+	log.Printf("config: migrated %s to %s; it can be removed", legacyPath, statePath())
+	return c, nil
+}
 
-	f, err := os.Create(configPath)
+// statePath returns the path of the state file.
+func statePath() string {
+	return filepath.Join(StateDir, stateFileName)
+}
+
+// backupPath returns the path of the backup of the state file.
+func backupPath() string {
+	return statePath() + ".bak"
+}
+
+// load loads the config from the named file.
+func load(name string) (*Config, error) {
+	b, err := ioutil.ReadFile(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	// Brad Fitzpatrick:
-	// Never defer a file Close when the file was opened for writing.
-	// Many filesystems do their real work (and thus their real failures) on close.
-	// You can defer a file.Close for Read, but not for write.
+	c, err := decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return c, nil
+}
 
-	var out bytes.Buffer
-	b, err := json.MarshalIndent(c, "", "  ")
+// decode decodes the config in b, which must have a UID.
+func decode(b []byte) (*Config, error) {
+	c, err := parseConfig(bytes.NewReader(b))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	out.Write(b)
-	out.WriteTo(f)
+	if c.UID == "" {
+		return nil, errors.New("no UID")
+	}
+	return c, nil
+}
 
-	if err := f.Close(); err != nil {
+// Save saves the config, but its Files, in the state file. It is replaced
+// atomically, so that a crash or a full disk while saving leaves the old
+// one, and the old one is kept as the backup, unless it is corrupt.
+// Make sure you lock and unlock the config while calling Save.
+func (c *Config) Save() error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
 		return err
 	}
-
-	return nil
+	if old, err := ioutil.ReadFile(statePath()); err == nil {
+		if _, err := decode(old); err == nil {
+			if err := fileutil.WriteFile(backupPath(), old, 0600); err != nil {
+				return err
+			}
+		}
+	}
+	return fileutil.WriteFile(statePath(), b, 0600)
 }
 
 func (c *Config) AddPolicy(p policy.Policy) error {
 	defer c.Unlock()
 	c.Lock()
+	if c.Files.HasPolicy(p.Name) {
+		return errors.New("policy with the given name is in the config files")
+	}
 	for _, k := range c.PolicyConfig {
 		if k.Name == p.Name {
 			return errors.New("policy with the given name already exists")
@@ -118,6 +223,107 @@ func (c *Config) AddPolicy(p policy.Policy) error {
 	return nil
 }
 
+// Policies returns the policies of the config files and those received
+// from marksman, leaving out the latter if they are in the files too.
+func (c *Config) Policies() policy.Config {
+	defer c.Unlock()
+	c.Lock()
+	var ps policy.Config
+	for _, p := range c.Files.PolicyConfig {
+		p.AgentUID = c.UID
+		ps = append(ps, p)
+	}
+	for _, p := range c.PolicyConfig {
+		if !c.Files.HasPolicy(p.Name) {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// AlertConfig returns the alerting rules and channels of the
+// config and of the config files.
+func (c *Config) AlertConfig() alert.Config {
+	return alert.Config{
+		Rules:    append(append([]alert.Rule(nil), c.Alerts.Rules...), c.Files.Alerts.Rules...),
+		Channels: append(append([]alert.Channel(nil), c.Alerts.Channels...), c.Files.Alerts.Channels...),
+	}
+}
+
+// AllTags returns the tags of the config, overridden
+// by those of the config files.
+func (c *Config) AllTags() map[string]string {
+	tags := make(map[string]string)
+	for k, v := range c.Tags {
+		tags[k] = v
+	}
+	for k, v := range c.Files.Tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// AddSilence adds the silence s, replacing the one with the same
+// name if any, and drops the silences which have expired.
+func (c *Config) AddSilence(s policy.Silence) error {
+	if err := s.Valid(); err != nil {
+		return err
+	}
+	defer c.Unlock()
+	c.Lock()
+	now := time.Now()
+	var ss policy.Silences
+	for _, k := range c.Silences {
+		if k.Name != s.Name && !k.Expired(now) {
+			ss = append(ss, k)
+		}
+	}
+	c.Silences = append(ss, s)
+	return nil
+}
+
+// DeleteSilence deletes the silence with the given name.
+func (c *Config) DeleteSilence(name string) error {
+	defer c.Unlock()
+	c.Lock()
+	for i, k := range c.Silences {
+		if k.Name == name {
+			c.Silences = append(c.Silences[:i], c.Silences[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("silence not found")
+}
+
+// Silenced reports whether the policy with the given name is silenced at t.
+func (c *Config) Silenced(policyName string, t time.Time) bool {
+	defer c.Unlock()
+	c.Lock()
+	return c.Silences.Silenced(policyName, t)
+}
+
+// ParseTags parses the comma separated key=value pairs in s,
+// e.g. "env=prod,role=db", into tags.
+func ParseTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("tag %q isn't of the form key=value", kv)
+		}
+		k, v := strings.TrimSpace(kv[:i]), strings.TrimSpace(kv[i+1:])
+		if k == "" {
+			return nil, fmt.Errorf("tag %q has an empty key", kv)
+		}
+		tags[k] = v
+	}
+	return tags, nil
+}
+
 // parseConfig reads from a io.Reader and
 // creates a Config struct accordingly.
 // It takes an io.Reader so that it is easier
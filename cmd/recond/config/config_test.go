@@ -9,11 +9,15 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/codeignition/recon/cmd/recond/alert"
 	"github.com/codeignition/recon/internal/fileutil"
 	"github.com/codeignition/recon/policy"
+	_ "github.com/codeignition/recon/policy/handlers"
 )
 
 func TestGenerateUID(t *testing.T) {
@@ -44,92 +48,142 @@ func TestParseConfig(t *testing.T) {
 	}
 }
 
-func TestInitExisting(t *testing.T) {
-	f, err := ioutil.TempFile("", "recond_fake_config")
+// tempDirs sets Dir and StateDir to new temporary directories,
+// and the config file of the older versions to a missing one.
+func tempDirs(t *testing.T) (cleanup func()) {
+	dir, err := ioutil.TempDir("", "recond_config")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+	Dir = filepath.Join(dir, "etc")
+	StateDir = filepath.Join(dir, "state")
+	legacyPath = filepath.Join(dir, legacyFileName)
+	return func() { os.RemoveAll(dir) }
+}
+
+func TestInitExisting(t *testing.T) {
+	defer tempDirs(t)()
+	if err := os.MkdirAll(StateDir, 0700); err != nil {
+		t.Fatal(err)
 	}
 	fakeUID := "13fcdf794886"
 	fakeContent := fmt.Sprintf(`{"UID":"%s"}
 `, fakeUID)
-	_, err = f.WriteString(fakeContent)
-	if err != nil {
-		t.Error(err)
+	if err := ioutil.WriteFile(statePath(), []byte(fakeContent), 0600); err != nil {
+		t.Fatal(err)
 	}
-	if err := f.Close(); err != nil {
-		t.Error(err)
-	}
-	configPath = f.Name()
 	c, err := Init()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	if c.UID != fakeUID {
 		t.Errorf("got config UID %q; want %q", c.UID, fakeUID)
 	}
-	if err := os.Remove(f.Name()); err != nil {
-		t.Error(err)
-	}
 }
 
 func TestInitNew(t *testing.T) {
-	configPath = filepath.Join(os.TempDir(), "recond_fake_new_config") // doesn't exist
+	defer tempDirs(t)()
 	c, err := Init()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if !fileutil.Exists(configPath) {
-		t.Errorf("Init didn't create the config file")
+	if !fileutil.Exists(statePath()) {
+		t.Errorf("Init didn't create the state file")
 	}
 	if c.UID == "" {
 		t.Errorf("got config UID as an empty string; want a non empty string")
 	}
-	if err := os.Remove(configPath); err != nil {
-		t.Error(err)
-	}
 }
 
-func TestSave(t *testing.T) {
-	f, err := ioutil.TempFile("", "recond_fake_config")
-	if err != nil {
-		t.Error(err)
-	}
+func TestInitMigrate(t *testing.T) {
+	defer tempDirs(t)()
 	fakeUID := "13fcdf794886"
-	fakeContent := fmt.Sprintf(`{"UID":"%s"}
-`, fakeUID)
-	_, err = f.WriteString(fakeContent)
-	if err != nil {
-		t.Error(err)
-	}
-	if err := f.Close(); err != nil {
-		t.Error(err)
+	if err := ioutil.WriteFile(legacyPath, []byte(`{"UID":"`+fakeUID+`"}`), 0600); err != nil {
+		t.Fatal(err)
 	}
-	configPath = f.Name()
 	c, err := Init()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	// this is redundant as it is checked in TestInit but lets leave it anyways
 	if c.UID != fakeUID {
-		t.Errorf("got config UID %q; want %q", c.UID, fakeUID)
+		t.Errorf("got config UID %q; want the migrated %q", c.UID, fakeUID)
+	}
+	if c, err := load(statePath()); err != nil || c.UID != fakeUID {
+		t.Errorf("state file not migrated: %v", err)
+	}
+}
+
+func TestSave(t *testing.T) {
+	defer tempDirs(t)()
+	c, err := Init()
+	if err != nil {
+		t.Fatal(err)
 	}
 	s, err := generateUID()
 	if err != nil {
 		t.Error(err)
 	}
 	c.UID = s
+	c.Files.Tags = map[string]string{"env": "prod"}
 	if err := c.Save(); err != nil {
 		t.Error(err)
 	}
 	c, err = Init()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	if c.UID != s {
 		t.Errorf("got config UID %q; want %q", c.UID, s)
 	}
-	if err := os.Remove(f.Name()); err != nil {
-		t.Error(err)
+	if len(c.Files.Tags) != 0 {
+		t.Errorf("got tags %v of the config files saved", c.Files.Tags)
+	}
+}
+
+func TestInitCorrupt(t *testing.T) {
+	defer tempDirs(t)()
+	if err := os.MkdirAll(StateDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	old := &Config{UID: "13fcdf794886"}
+	if err := old.Save(); err != nil {
+		t.Fatal(err)
+	}
+	cur := &Config{UID: old.UID, HostName: "db1"}
+	if err := cur.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// e.g. the disk filled up while the config was being written
+	if err := ioutil.WriteFile(statePath(), []byte(`{"UID":"13fc`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.UID != old.UID || c.HostName != "" {
+		t.Errorf("got config %+v; want the backup %+v", c, old)
+	}
+	if _, err := load(statePath()); err != nil {
+		t.Errorf("config file not restored: %v", err)
+	}
+
+	// A corrupt config isn't replaced by a new one.
+	for _, name := range []string{statePath(), backupPath()} {
+		if err := ioutil.WriteFile(name, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Init(); err == nil {
+		t.Error("want an error for the corrupt config and backup")
+	}
+	if err := os.Remove(statePath()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Init(); err == nil {
+		t.Error("want an error for the missing config and corrupt backup")
 	}
 }
 
@@ -148,3 +202,222 @@ func TestConcurrentAddPolicy(t *testing.T) {
 		c.AddPolicy(p)
 	}()
 }
+
+func TestAddSilence(t *testing.T) {
+	now := time.Now()
+	c := &Config{
+		Silences: policy.Silences{
+			{Name: "old", Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)},
+			{Name: "upgrade", Start: now.Add(-time.Hour), End: now.Add(-time.Minute)},
+		},
+	}
+	if err := c.AddSilence(policy.Silence{Name: "bad"}); err == nil {
+		t.Error("want an error for the invalid silence")
+	}
+	s := policy.Silence{Name: "upgrade", Policy: "web", Start: now.Add(-time.Minute), End: now.Add(time.Hour)}
+	if err := c.AddSilence(s); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Silences) != 1 || c.Silences[0] != s {
+		t.Errorf("got silences %+v; want only %+v", c.Silences, s)
+	}
+	if !c.Silenced("web", now) || c.Silenced("db", now) {
+		t.Error("want only the web policy silenced")
+	}
+	if err := c.DeleteSilence("upgrade"); err != nil {
+		t.Error(err)
+	}
+	if err := c.DeleteSilence("upgrade"); err == nil {
+		t.Error("want an error for the silence deleted")
+	}
+}
+
+func TestInitFiles(t *testing.T) {
+	defer tempDirs(t)()
+	files := map[string]string{
+		"recond.yaml": `
+version: 1
+tags: {env: staging, role: db}
+policies:
+  - {name: ssh, type: tcp, params: {address: "localhost:22"}}
+`,
+		"conf.d/10-env.json": `{"version": 1, "tags": {"env": "prod"}}`,
+		// the layout before the versions
+		"conf.d/20-web.json": `{"PolicyConfig": [{"Name": "web", "Type": "tcp", "M": {"address": "localhost:80"}}]}`,
+		"conf.d/README":      `not a config file`,
+	}
+	for name, content := range files {
+		name = filepath.Join(Dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Tags = map[string]string{"team": "ops", "env": "dev"}
+	want := map[string]string{"env": "prod", "role": "db", "team": "ops"}
+	if got := c.AllTags(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got tags %v; want %v", got, want)
+	}
+
+	if err := c.AddPolicy(policy.Policy{Name: "web", Type: "tcp"}); err == nil {
+		t.Error("want an error for the policy in the config files")
+	}
+	// e.g. received before the policy was added to the files
+	c.PolicyConfig = policy.Config{{Name: "ssh", AgentUID: c.UID}, {Name: "ntp", AgentUID: c.UID}}
+	var names []string
+	for _, p := range c.Policies() {
+		if p.AgentUID != c.UID {
+			t.Errorf("got agent UID %q of the policy %s; want %q", p.AgentUID, p.Name, c.UID)
+		}
+		names = append(names, p.Name)
+	}
+	if want := []string{"ssh", "web", "ntp"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got policies %v; want %v", names, want)
+	}
+
+	// A policy can't be in two files.
+	dup := filepath.Join(Dir, "conf.d", "30-ssh.json")
+	if err := ioutil.WriteFile(dup, []byte(`{"PolicyConfig": [{"Name": "ssh", "Type": "tcp"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Init(); err == nil || !strings.Contains(err.Error(), "30-ssh.json") {
+		t.Errorf("got error %v; want one for the duplicate policy in 30-ssh.json", err)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		s    string
+		want map[string]string
+		err  bool
+	}{
+		{s: "", want: map[string]string{}},
+		{s: "env=prod, role = db", want: map[string]string{"env": "prod", "role": "db"}},
+		{s: "env=prod,team=", want: map[string]string{"env": "prod", "team": ""}},
+		{s: "url=http://x?a=b", want: map[string]string{"url": "http://x?a=b"}},
+		{s: "env", err: true},
+		{s: "=prod", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTags(tt.s)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: want an error", tt.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v; want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *File
+		err     string // a substring of the error
+	}{
+		{
+			name: "recond.yaml",
+			content: `
+version: 1
+tags:
+  env: prod
+alerts:
+  rules:
+    - {name: web-down, policy: web, field: status, op: "==", value: critical, channels: [ops]}
+  channels:
+    - {name: ops, type: webhook, url: "http://localhost/hook"}
+policies:
+  - name: web
+    type: tcp
+    labels: {team: web}
+    params: {address: "localhost:80", interval: 10s}
+`,
+			want: &File{
+				Tags: map[string]string{"env": "prod"},
+				Alerts: alert.Config{
+					Rules:    []alert.Rule{{Name: "web-down", Policy: "web", Field: "status", Op: "==", Value: "critical", Channels: []string{"ops"}}},
+					Channels: []alert.Channel{{Name: "ops", Type: "webhook", URL: "http://localhost/hook"}},
+				},
+				PolicyConfig: policy.Config{{
+					Name:   "web",
+					Type:   "tcp",
+					Labels: map[string]string{"team": "web"},
+					M:      map[string]string{"address": "localhost:80", "interval": "10s"},
+				}},
+			},
+		},
+		{
+			name:    "web.json",
+			content: `{"version": 1, "policies": [{"name": "web", "type": "tcp", "params": {"address": "localhost:80"}}]}`,
+			want: &File{
+				PolicyConfig: policy.Config{{Name: "web", Type: "tcp", M: map[string]string{"address": "localhost:80"}}},
+			},
+		},
+		{
+			name:    "legacy.json",
+			content: `{"UID": "13fcdf794886", "Tags": {"env": "prod"}, "PolicyConfig": [{"Name": "web", "Type": "tcp"}]}`,
+			want: &File{
+				Tags:         map[string]string{"env": "prod"},
+				PolicyConfig: policy.Config{{Name: "web", Type: "tcp"}},
+			},
+		},
+		{name: "empty.yaml", content: "\n", want: &File{}},
+		{
+			name:    "misspelled.yaml",
+			content: "version: 1\npolicies:\n  - name: web\n    typ: tcp\n",
+			err:     "line 4: field typ not found",
+		},
+		{
+			name:    "invalid.yaml",
+			content: "version: 1\npolicies:\n  - {name: ssh, type: tcp}\n  - {name: web, type: htttp}\n",
+			err:     `line 4: policy "web": policy type unknown`,
+		},
+		{
+			name:    "invalid.json",
+			content: "{\n  \"PolicyConfig\": [\n    {\"Name\": \"\", \"Type\": \"tcp\"}\n  ]\n}",
+			err:     "line 3: policy \"\": policy name can't be empty",
+		},
+		{
+			name:    "misspelled.json",
+			content: "{\n  \"Tags\": {\"env\": \"prod\"},\n  \"PolicyConfg\": []\n}",
+			err:     `line 3: json: unknown field "PolicyConfg"`,
+		},
+		{
+			name:    "comma.json",
+			content: "{\n  \"Tags\": {\"env\": \"prod\"},,\n}",
+			err:     "line 2",
+		},
+		{name: "noversion.yaml", content: "tags: {env: prod}\n", err: "no version"},
+		{name: "future.yaml", content: "version: 2\n", err: "unknown version 2"},
+	}
+	for _, tt := range tests {
+		f, err := parseFile(tt.name, []byte(tt.content))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v; want one with %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(f, tt.want) {
+			t.Errorf("%s: got %+v; want %+v", tt.name, f, tt.want)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+
+	"github.com/codeignition/recon/cmd/recond/alert"
+	"github.com/codeignition/recon/policy"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	mainFileName  = "recond" // in Dir, with one of the extensions
+	dropInDirName = "conf.d" // in Dir, of the drop-in files
+)
+
+// extensions are those of the config files.
+var extensions = []string{".yaml", ".yml", ".json"}
+
+// Version is the version of the schema of the config files. The files
+// of older versions are migrated when they are loaded.
+const Version = 1
+
+// fileV1 is version 1 of the schema of the config files, e.g.
+//
+//	version: 1
+//	tags:
+//	  env: prod
+//	alerts:
+//	  rules:
+//	    - {name: web-down, policy: web, field: status, op: "==", value: critical, channels: [ops]}
+//	  channels:
+//	    - {name: ops, type: webhook, url: "https://hooks.example.com/recon"}
+//	policies:
+//	  - name: web
+//	    type: tcp
+//	    labels: {team: web}
+//	    params: {address: "localhost:80", interval: 10s}
+//
+// The rule web-down fires when the status of the events of the web
+// policy is critical, i.e. when nothing accepts its connections;
+// "data.status", the result of the dials, would be "failure". JSON is
+// YAML as well, so the files may be written in either.
+type fileV1 struct {
+	Version int               `yaml:"version"`
+	Tags    map[string]string `yaml:"tags"`
+	Alerts  struct {
+		Rules    []alert.Rule    `yaml:"rules"`
+		Channels []alert.Channel `yaml:"channels"`
+	} `yaml:"alerts"`
+	Policies []struct {
+		Name   string            `yaml:"name"`
+		Type   string            `yaml:"type"`
+		Labels map[string]string `yaml:"labels"`
+		Params map[string]string `yaml:"params"`
+	} `yaml:"policies"`
+}
+
+// File is the contents of the config files in Dir, written by the
+// administrator or a configuration management tool.
+type File struct {
+	Tags         map[string]string
+	Alerts       alert.Config
+	PolicyConfig policy.Config
+}
+
+// HasPolicy reports whether the policy with the given name is in f.
+func (f *File) HasPolicy(name string) bool {
+	for _, p := range f.PolicyConfig {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadDir loads the config files in dir, recond.yaml, .yml or .json, and
+// then the drop-in files in conf.d, each sorted by name, and merges them.
+// The tags of the later files override those of the earlier ones; their
+// alerting rules and channels, and their policies, are added up. A policy
+// can't be in more than one file.
+func LoadDir(dir string) (*File, error) {
+	names, err := configFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	m := &File{Tags: make(map[string]string)}
+	in := make(map[string]string) // the files of the policies
+	for _, name := range names {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parseFile(name, b)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range f.Tags {
+			m.Tags[k] = v
+		}
+		m.Alerts.Rules = append(m.Alerts.Rules, f.Alerts.Rules...)
+		m.Alerts.Channels = append(m.Alerts.Channels, f.Alerts.Channels...)
+		for _, p := range f.PolicyConfig {
+			if other, ok := in[p.Name]; ok {
+				return nil, fmt.Errorf("config: %s: policy %s is also in %s", name, p.Name, other)
+			}
+			in[p.Name] = name
+			m.PolicyConfig = append(m.PolicyConfig, p)
+		}
+	}
+	return m, nil
+}
+
+// configFiles returns the config files in dir, in the order they are merged.
+func configFiles(dir string) ([]string, error) {
+	var names []string
+	for _, pattern := range []string{
+		filepath.Join(dir, mainFileName),
+		filepath.Join(dir, dropInDirName, "*"),
+	} {
+		var ns []string
+		for _, ext := range extensions {
+			m, err := filepath.Glob(pattern + ext)
+			if err != nil {
+				return nil, err
+			}
+			ns = append(ns, m...)
+		}
+		sort.Strings(ns)
+		names = append(names, ns...)
+	}
+	return names, nil
+}
+
+// parseFile parses the contents b of the config file with the given
+// name, which may be of any version, and validates its policies. An
+// empty file is an empty config.
+func parseFile(name string, b []byte) (*File, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return &File{}, nil
+	}
+	if b[0] == '{' {
+		// The YAML parser reports the syntax errors of JSON, such as
+		// a stray comma, at the start of the object they are in.
+		var v interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			return nil, fmt.Errorf("config: %s: %v", name, jsonError(b, err))
+		}
+	}
+	var v struct {
+		Version *int `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("config: %s: %v", name, err)
+	}
+	switch {
+	case v.Version == nil && b[0] == '{':
+		return parseLegacy(name, b)
+	case v.Version == nil:
+		return nil, fmt.Errorf("config: %s: no version; add \"version: %d\"", name, Version)
+	case *v.Version == 1:
+		return parseV1(name, b)
+	}
+	return nil, fmt.Errorf("config: %s: unknown version %d; the latest is %d", name, *v.Version, Version)
+}
+
+// parseV1 parses the config file of version 1. The fields
+// unknown to the schema, e.g. misspelled ones, are errors.
+func parseV1(name string, b []byte) (*File, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	var v fileV1
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("config: %s: %v", name, err)
+	}
+	f := &File{Tags: v.Tags}
+	f.Alerts.Rules = v.Alerts.Rules
+	f.Alerts.Channels = v.Alerts.Channels
+	for _, p := range v.Policies {
+		f.PolicyConfig = append(f.PolicyConfig, policy.Policy{
+			Name:   p.Name,
+			Type:   p.Type,
+			M:      p.Params,
+			Labels: p.Labels,
+		})
+	}
+	if err := validate(name, b, "policies", f.PolicyConfig); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// legacyFile is the layout of the config files before the versions, the
+// JSON of File with the field names as keys, or of the config file of the
+// older versions in the home directory, whose state is ignored.
+type legacyFile struct {
+	File
+	UID      string
+	HostName string
+	Silences policy.Silences
+}
+
+// parseLegacy parses the config file of the layout before the versions
+// and migrates it. The fields unknown to the layout are errors.
+func parseLegacy(name string, b []byte) (*File, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var f legacyFile
+	if err := dec.Decode(&f); err != nil {
+		// The errors of the unknown fields have no offset.
+		var field string
+		if _, serr := fmt.Sscanf(err.Error(), "json: unknown field %q", &field); serr == nil {
+			if line := keyLine(b, field); line > 0 {
+				return nil, fmt.Errorf("config: %s: line %d: %v", name, line, err)
+			}
+		}
+		return nil, fmt.Errorf("config: %s: %v", name, jsonError(b, err))
+	}
+	if err := validate(name, b, "PolicyConfig", f.PolicyConfig); err != nil {
+		return nil, err
+	}
+	log.Printf("config: %s has the layout before the versions; migrate it to version %d", name, Version)
+	return &f.File, nil
+}
+
+// validate validates the policies ps, which are in the list under the
+// key of the config file with the given name and contents b. The errors
+// have the lines of the policies.
+func validate(name string, b []byte, key string, ps policy.Config) error {
+	for i, p := range ps {
+		err := p.Valid()
+		if err == nil {
+			continue
+		}
+		// b has been parsed already, so the lines can be found.
+		var nodes map[string]yaml.Node
+		yaml.Unmarshal(b, &nodes)
+		if n := nodes[key]; n.Kind == yaml.SequenceNode && i < len(n.Content) {
+			return fmt.Errorf("config: %s: line %d: policy %q: %v", name, n.Content[i].Line, p.Name, err)
+		}
+		return fmt.Errorf("config: %s: policy %q: %v", name, p.Name, err)
+	}
+	return nil
+}
+
+// jsonError adds the line and column in b of the JSON error
+// err, if it is at an offset in b, to its message.
+func jsonError(b []byte, err error) error {
+	var off int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		off = e.Offset
+	case *json.UnmarshalTypeError:
+		off = e.Offset
+	default:
+		return err
+	}
+	if off > int64(len(b)) {
+		off = int64(len(b))
+	}
+	line := 1 + bytes.Count(b[:off], []byte("\n"))
+	col := int(off) - bytes.LastIndex(b[:off], []byte("\n"))
+	return fmt.Errorf("line %d, column %d: %v", line, col, err)
+}
+
+// keyLine returns the line in b of the first key, in any of the objects,
+// which is the given one, or 0 if there is none.
+func keyLine(b []byte, key string) int {
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		return 0
+	}
+	var find func(n *yaml.Node) int
+	find = func(n *yaml.Node) int {
+		for i, c := range n.Content {
+			if n.Kind == yaml.MappingNode && i%2 == 0 && c.Value == key {
+				return c.Line
+			}
+			if line := find(c); line > 0 {
+				return line
+			}
+		}
+		return 0
+	}
+	return find(&n)
+}
@@ -4,5 +4,36 @@ recond is the daemon that runs on the machines.
 It collects the system metrics such as percentage of CPU used, memory consumption, filesystem metrics, network metrics, etc.
 It sends the update every 5 seconds to the marksman server which is the metrics aggregator server.
 
+It also sends the host inventory (CPUs, memory, kernel, distribution, network interfaces, users, etc.)
+while registering with marksman, and publishes the changes to it on the "inventory_changes" subject.
+The tags of the agent, from the "Tags" key of its config or the -tags flag, e.g. env=prod,role=db,
+and the labels derived from its inventory, e.g. os, kernel, distro and virtualization, are sent
+while registering and attached to every policy event. Besides its own "<uid>_add_policy", etc.
+subjects, the agent receives the policies and silences sent to the groups it is in by its labels,
+e.g. "tag.role.db.add_policy", and replies to them with its UID; see package group.
+
+The alerting rules in the "Alerts" key of its config are evaluated against the policy events
+on the machine itself, and notify their webhook, SMTP and syslog channels even when marksman is down.
+The silences received on the "<uid>_silence" subject, e.g. for planned maintenance, mark the events
+of the policies they silence as suppressed, which notify no alerting channels; "<uid>_unsilence" deletes them.
+
+The config files are in /etc/recond, or the directory named by the -config flag or $RECOND_CONFIG:
+recond.yaml and the drop-in files in conf.d, in YAML or JSON, with tags, alerting rules and policies,
+which are merged and validated at start and never written by recond, so that configuration management
+tools own them. Their schema is versioned, e.g.
+
+	version: 1
+	tags: {env: prod, role: web}
+	policies:
+	  - name: web
+	    type: tcp
+	    params: {address: "localhost:80", interval: 10s}
+
+The files of the older, unversioned JSON layout are migrated when they are loaded.
+recond keeps its UID and the policies and silences it receives in /var/lib/recond, or the directory
+named by the -state-dir flag; the ~/.recond.json of the older versions is migrated to it.
+
+When recond runs in a container, the metrics are collected from the host filesystem mounted in it,
+e.g. on /host, named by the -root flag or $RECOND_ROOT.
 */
 package main
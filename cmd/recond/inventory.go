@@ -0,0 +1,49 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/codeignition/recon/inventory"
+	"golang.org/x/net/context"
+)
+
+// inventoryChanges is published on the "inventory_changes"
+// subject whenever the host facts change.
+type inventoryChanges struct {
+	Time     time.Time          `json:"time"`
+	AgentUID string             `json:"agent_uid"`
+	Changes  []inventory.Change `json:"changes"`
+}
+
+// publishInventoryChanges collects the host facts every interval and
+// publishes the changes from the last facts, if any. The complete facts
+// are sent only while registering the agent.
+func publishInventoryChanges(uid string, last inventory.Facts, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		facts, err := inventory.Collect(context.Background(), last)
+		if err != nil {
+			log.Print(err)
+		}
+		c := inventory.Diff(last, facts)
+		if len(c) == 0 {
+			continue
+		}
+		log.Printf("inventory: %d facts changed", len(c))
+		natsEncConn.Publish("inventory_changes", inventoryChanges{
+			Time:     time.Now(),
+			AgentUID: uid,
+			Changes:  c,
+		})
+		last = facts
+	}
+}
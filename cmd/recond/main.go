@@ -8,13 +8,21 @@ import (
 	"errors"
 	"flag"
 	"log"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 
+	"github.com/codeignition/recon/cmd/recond/alert"
 	"github.com/codeignition/recon/cmd/recond/config"
+	"github.com/codeignition/recon/group"
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/internal/mount"
+	"github.com/codeignition/recon/inventory"
+	"github.com/codeignition/recon/metrics"
 	"github.com/codeignition/recon/policy"
-	_ "github.com/codeignition/recon/policy/handlers"
+	"github.com/codeignition/recon/policy/handlers"
 	"github.com/nats-io/nats"
 )
 
@@ -24,6 +32,14 @@ const agentsAPIPath = "/api/agents" // agents path in the marksman server
 // It is populated if the agent registers successfully.
 var natsEncConn *nats.EncodedConn
 
+// alerter evaluates the alerting rules of the config
+// against the policy events.
+var alerter *alert.Alerter
+
+// agentLabels are the labels of the agent, attached to every event.
+// They are set before any policy runs and not modified afterwards.
+var agentLabels map[string]string
+
 // ctxCancelFunc stores the map of policy name to
 // the context cancel function.
 var ctxCancelFunc = struct {
@@ -36,17 +52,46 @@ var ctxCancelFunc = struct {
 var (
 	flagNATSAddr     = flag.String("nats", "", "address of the nats server, use only if you want to override the URL obtained from marksman")
 	flagMarksmanAddr = flag.String("marksman", "http://localhost:3000", "address of the marksman server")
+	flagInventory    = flag.Duration("inventory-interval", time.Hour, "interval at which the host inventory is checked for changes")
+	flagLegacyUnits  = flag.Bool("legacy-units", false, "send the metric values as strings with embedded units, as older versions did")
+	flagFSInclude    = flag.String("fs-include", "", "comma separated filesystem types and mountpoints to collect, e.g. ext4,/data/*; all but the excluded ones if empty")
+	flagFSExclude    = flag.String("fs-exclude", "", "comma separated filesystem types and mountpoints to leave out, besides the virtual filesystems")
+	flagFSTimeout    = flag.Duration("fs-timeout", mount.Timeout, "time given to each filesystem to report its usage")
+	flagConfig       = flag.String("config", config.Dir, "directory of the YAML or JSON config files, recond.yaml and the drop-ins in conf.d, which recond doesn't write; $RECOND_CONFIG if set")
+	flagStateDir     = flag.String("state-dir", config.StateDir, "directory in which recond keeps its UID, the policies it received and their state, e.g. the anomaly baselines")
+	flagTags         = flag.String("tags", "", "comma separated key=value tags of the agent, e.g. env=prod,role=db, overriding those of the config")
+	flagRoot         = flag.String("root", host.Root, "directory the host filesystem is mounted on, e.g. /host when recond runs in a container; $RECOND_ROOT if set")
 )
 
 func main() {
 	log.SetPrefix("recond: ")
 
 	flag.Parse()
+	host.Root = *flagRoot
+	metrics.LegacyUnits = *flagLegacyUnits
+	setMountFilter(&mount.DefaultFilter, *flagFSInclude, *flagFSExclude)
+	mount.Timeout = *flagFSTimeout
+	config.Dir = *flagConfig
+	config.StateDir = *flagStateDir
+	handlers.StateDir = *flagStateDir
 
 	conf, err := config.Init()
 	if err != nil {
 		log.Fatalln(err)
 	}
+	alerter, err = alert.New(conf.AlertConfig(), conf.HostName)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	tags, err := config.ParseTags(*flagTags)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for k, v := range conf.AllTags() {
+		if _, ok := tags[k]; !ok {
+			tags[k] = v
+		}
+	}
 
 	// agent represents a single agent on which the recond
 	// is running.
@@ -55,6 +100,18 @@ func main() {
 		HostName: conf.HostName,
 	}
 
+	facts, err := inventory.Collect(context.Background(), nil)
+	if err != nil {
+		log.Print(err)
+	}
+	agent.Facts = facts
+	agent.Tags = tags
+	agent.Labels = inventory.Labels(facts)
+	for k, v := range tags {
+		agent.Labels[k] = v
+	}
+	agentLabels = agent.Labels
+
 	err = agent.register(*flagMarksmanAddr)
 	if err != nil {
 		log.Fatalln(err)
@@ -67,22 +124,58 @@ func main() {
 	}
 
 	go runStoredPolicies(conf)
+	go publishInventoryChanges(agent.UID, facts, *flagInventory)
 
-	natsEncConn.Subscribe(agent.UID+"_add_policy", AddPolicyHandler(conf))
-	natsEncConn.Subscribe(agent.UID+"_delete_policy", DeletePolicyHandler(conf))
-	natsEncConn.Subscribe(agent.UID+"_modify_policy", ModifyPolicyHandler(conf))
+	subscribe(conf, func(op string) string { return agent.UID + "_" + op })
+	for k, v := range agentLabels {
+		k, v := k, v
+		subscribe(conf, func(op string) string { return group.Subject(k, v, op) })
+	}
 
 	// this is just to block the main function from exiting
 	c := make(chan struct{})
 	<-c
 }
 
+// subscribe subscribes the handlers to the subjects of their
+// operations, e.g. subject("add_policy").
+func subscribe(conf *config.Config, subject func(op string) string) {
+	natsEncConn.Subscribe(subject("add_policy"), AddPolicyHandler(conf))
+	natsEncConn.Subscribe(subject("delete_policy"), DeletePolicyHandler(conf))
+	natsEncConn.Subscribe(subject("modify_policy"), ModifyPolicyHandler(conf))
+	natsEncConn.Subscribe(subject("silence"), SilenceHandler(conf))
+	natsEncConn.Subscribe(subject("unsilence"), UnsilenceHandler(conf))
+}
+
+// setMountFilter sets the included mounts of f, and adds to its
+// excluded ones, from the comma separated lists. The entries which
+// start with a "/" are mountpoints and the others filesystem types.
+func setMountFilter(f *mount.Filter, include, exclude string) {
+	split := func(s string) (types, points []string) {
+		for _, x := range strings.Split(s, ",") {
+			x = strings.TrimSpace(x)
+			switch {
+			case x == "":
+			case strings.HasPrefix(x, "/"):
+				points = append(points, x)
+			default:
+				types = append(types, x)
+			}
+		}
+		return types, points
+	}
+	f.Types, f.Points = split(include)
+	types, points := split(exclude)
+	f.ExcludeTypes = append(f.ExcludeTypes, types...)
+	f.ExcludePoints = append(f.ExcludePoints, points...)
+}
+
 func runStoredPolicies(c *config.Config) {
-	for _, p := range c.PolicyConfig {
+	for _, p := range c.Policies() {
 		log.Printf("adding the policy %s...", p.Name)
 		go func(p policy.Policy) {
 			ctx, cancel := context.WithCancel(context.Background())
-			events, err := p.Execute(ctx)
+			events, err := p.Supervise(ctx)
 			if err != nil {
 				log.Print(err) // TODO: send to a nats errors channel
 			}
@@ -90,16 +183,34 @@ func runStoredPolicies(c *config.Config) {
 			ctxCancelFunc.m[p.Name] = cancel
 			ctxCancelFunc.Unlock()
 
-			for e := range events {
-				natsEncConn.Publish("policy_events", e)
-			}
+			publishEvents(c, events)
 		}(p)
 	}
 }
 
+// publishEvents publishes the policy events to marksman and alerts
+// on them locally. The labels of the agent are added to those of the
+// events, which take precedence. The events of the policies silenced
+// in the config are marked as suppressed and not alerted on.
+func publishEvents(c *config.Config, events <-chan policy.Event) {
+	for e := range events {
+		for k, v := range agentLabels {
+			if e.Labels == nil {
+				e.Labels = make(map[string]string, len(agentLabels))
+			}
+			if _, ok := e.Labels[k]; !ok {
+				e.Labels[k] = v
+			}
+		}
+		e.Suppressed = c.Silenced(e.PolicyName, e.Time)
+		alerter.Process(e)
+		natsEncConn.Publish("policy_events", e)
+	}
+}
+
 func addSystemDataPolicy(c *config.Config) error {
 	// if the policy already exists, return silently
-	for _, p := range c.PolicyConfig {
+	for _, p := range c.Policies() {
 		if p.Name == "default_system_data" {
 			return nil
 		}
@@ -111,6 +222,7 @@ func addSystemDataPolicy(c *config.Config) error {
 		Type:     "system_data",
 		M: map[string]string{
 			"interval": "5s",
+			"jitter":   "5s",
 		},
 	}
 	if err := c.AddPolicy(p); err != nil {
@@ -5,94 +5,163 @@
 package main
 
 import (
+	"errors"
 	"log"
 
 	"github.com/codeignition/recon/cmd/recond/config"
+	"github.com/codeignition/recon/group"
 	"github.com/codeignition/recon/policy"
 	"golang.org/x/net/context"
 )
 
+// ack replies on reply to the message received on subj with msg, or
+// err if it isn't nil. The messages sent to a group are replied to with
+// a group.Ack, which carries the UID of the agent.
+func ack(conf *config.Config, subj, reply, msg string, err error) {
+	if err != nil {
+		msg = err.Error()
+	}
+	if group.IsSubject(subj) {
+		natsEncConn.Publish(reply, group.Ack{
+			UID:     conf.UID,
+			Subject: subj,
+			OK:      err == nil,
+			Message: msg,
+		})
+		return
+	}
+	natsEncConn.Publish(reply, msg)
+}
+
 func AddPolicyHandler(conf *config.Config) func(subj, reply string, p *policy.Policy) {
 	return func(subj, reply string, p *policy.Policy) {
 		log.Printf("add_policy received: %s\n", p.Name)
+		if group.IsSubject(subj) {
+			p.AgentUID = conf.UID
+		}
 		if err := conf.AddPolicy(*p); err != nil {
-			natsEncConn.Publish(reply, err.Error())
+			ack(conf, subj, reply, "", err)
 			return
 		}
 		if err := conf.Save(); err != nil {
-			natsEncConn.Publish(reply, err.Error())
+			ack(conf, subj, reply, "", err)
 			return
 		}
 		ctx, cancel := context.WithCancel(context.Background())
-		events, err := p.Execute(ctx)
+		events, err := p.Supervise(ctx)
 		if err != nil {
-			natsEncConn.Publish(reply, err.Error())
+			ack(conf, subj, reply, "", err)
 			return
 		}
 		ctxCancelFunc.Lock()
 		ctxCancelFunc.m[p.Name] = cancel
 		ctxCancelFunc.Unlock()
 
-		natsEncConn.Publish(reply, "add_policy_ack") // acknowledge policy add
-		for e := range events {
-			natsEncConn.Publish("policy_events", e)
-		}
+		ack(conf, subj, reply, "add_policy_ack", nil) // acknowledge policy add
+		publishEvents(conf, events)
 	}
 }
 
 func DeletePolicyHandler(conf *config.Config) func(subj, reply string, p *policy.Policy) {
 	return func(subj, reply string, p *policy.Policy) {
 		log.Printf("delete_policy received: %s\n", p.Name)
+		if conf.Files.HasPolicy(p.Name) {
+			ack(conf, subj, reply, "", errors.New("policy is in the config files"))
+			return
+		}
 		ctxCancelFunc.Lock()
 		cancel := ctxCancelFunc.m[p.Name]
 		ctxCancelFunc.Unlock()
-		cancel()
+		if cancel != nil { // e.g. a group member without the policy
+			cancel()
+		}
 		if err := deletePolicy(conf, p.Name); err != nil {
-			natsEncConn.Publish(reply, err.Error())
+			ack(conf, subj, reply, "", err)
 			return
 		}
-		natsEncConn.Publish(reply, "delete_policy_ack") // acknowledge policy delete
+		ack(conf, subj, reply, "delete_policy_ack", nil) // acknowledge policy delete
 	}
 }
 
 func ModifyPolicyHandler(conf *config.Config) func(subj, reply string, p *policy.Policy) {
 	return func(subj, reply string, p *policy.Policy) {
 		log.Printf("modify_policy received: %s\n", p.Name)
+		if conf.Files.HasPolicy(p.Name) {
+			ack(conf, subj, reply, "", errors.New("policy is in the config files"))
+			return
+		}
+		if group.IsSubject(subj) {
+			p.AgentUID = conf.UID
+		}
 
 		// We receive the complete policy with the new values
 		// and delete the old policy and stop its execution.
-		// Then we add the new policy.
+		// Then we add the new policy. A group member without
+		// the old policy, e.g. one which joined the group after
+		// it was added, just adds the new one.
 		ctxCancelFunc.Lock()
 		cancel := ctxCancelFunc.m[p.Name]
 		ctxCancelFunc.Unlock()
-		cancel()
-		if err := deletePolicy(conf, p.Name); err != nil {
-			log.Print(err)
-			natsEncConn.Publish(reply, err.Error())
-			return
+		if cancel != nil {
+			cancel()
+			if err := deletePolicy(conf, p.Name); err != nil {
+				log.Print(err)
+				ack(conf, subj, reply, "", err)
+				return
+			}
 		}
 		log.Printf("adding the policy %s...", p.Name)
 		if err := conf.AddPolicy(*p); err != nil {
-			natsEncConn.Publish(reply, err.Error())
+			ack(conf, subj, reply, "", err)
 			return
 		}
 		if err := conf.Save(); err != nil {
-			natsEncConn.Publish(reply, err.Error())
+			ack(conf, subj, reply, "", err)
 			return
 		}
 		ctx, cancel := context.WithCancel(context.Background())
-		events, err := p.Execute(ctx)
+		events, err := p.Supervise(ctx)
 		if err != nil {
-			natsEncConn.Publish(reply, err.Error())
+			ack(conf, subj, reply, "", err)
 			return
 		}
 		ctxCancelFunc.Lock()
 		ctxCancelFunc.m[p.Name] = cancel
 		ctxCancelFunc.Unlock()
 
-		natsEncConn.Publish(reply, "modify_policy_ack") // acknowledge policy delete
-		for e := range events {
-			natsEncConn.Publish("policy_events", e)
+		ack(conf, subj, reply, "modify_policy_ack", nil) // acknowledge policy delete
+		publishEvents(conf, events)
+	}
+}
+
+// SilenceHandler adds a silence, or replaces the one with the same name.
+func SilenceHandler(conf *config.Config) func(subj, reply string, s *policy.Silence) {
+	return func(subj, reply string, s *policy.Silence) {
+		log.Printf("silence received: %s\n", s.Name)
+		if err := conf.AddSilence(*s); err != nil {
+			ack(conf, subj, reply, "", err)
+			return
+		}
+		if err := conf.Save(); err != nil {
+			ack(conf, subj, reply, "", err)
+			return
+		}
+		ack(conf, subj, reply, "silence_ack", nil) // acknowledge silence
+	}
+}
+
+// UnsilenceHandler deletes the silence with the given name.
+func UnsilenceHandler(conf *config.Config) func(subj, reply string, s *policy.Silence) {
+	return func(subj, reply string, s *policy.Silence) {
+		log.Printf("unsilence received: %s\n", s.Name)
+		if err := conf.DeleteSilence(s.Name); err != nil {
+			ack(conf, subj, reply, "", err)
+			return
+		}
+		if err := conf.Save(); err != nil {
+			ack(conf, subj, reply, "", err)
+			return
 		}
+		ack(conf, subj, reply, "unsilence_ack", nil) // acknowledge silence delete
 	}
 }
@@ -0,0 +1,127 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// Package forecast projects a series of samples, such as the used
+// space of a filesystem, into the future, to tell when it will reach
+// a limit rather than whether it has.
+//
+// A model fits the samples of a rolling window and returns the level
+// of the series at the last sample and its trend, i.e. its change per
+// second. Linear fits a least squares line, which weighs all the samples
+// equally, and Holt smooths them exponentially, which follows the recent
+// changes of the trend more closely.
+package forecast
+
+import (
+	"math"
+	"time"
+)
+
+// Sample is a value of the series at a time.
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// Window is a rolling window of samples, in the order of their times.
+type Window struct {
+	Span    time.Duration // the samples older than the last one by more than Span are dropped
+	Samples []Sample
+}
+
+// Add adds the sample s to the window and drops the samples which
+// fell out of it. A sample older than the last one is ignored.
+func (w *Window) Add(s Sample) {
+	if n := len(w.Samples); n > 0 && s.Time.Before(w.Samples[n-1].Time) {
+		return
+	}
+	w.Samples = append(w.Samples, s)
+	i := 0
+	for i < len(w.Samples) && s.Time.Sub(w.Samples[i].Time) > w.Span {
+		i++
+	}
+	w.Samples = w.Samples[i:]
+}
+
+// Model fits a model to the samples and returns the level of the series
+// at the last sample and its trend per second. It returns false if the
+// samples are too few to fit it, i.e. if they span no time.
+type Model func(samples []Sample) (level, trend float64, ok bool)
+
+// Linear fits a line to the samples by least squares.
+func Linear(samples []Sample) (level, trend float64, ok bool) {
+	if len(samples) < 2 {
+		return 0, 0, false
+	}
+	t0 := samples[0].Time
+	var n, sx, sy, sxx, sxy float64
+	for _, s := range samples {
+		x := s.Time.Sub(t0).Seconds()
+		n++
+		sx += x
+		sy += s.Value
+		sxx += x * x
+		sxy += x * s.Value
+	}
+	d := n*sxx - sx*sx
+	if d == 0 {
+		return 0, 0, false
+	}
+	trend = (n*sxy - sx*sy) / d
+	intercept := (sy - trend*sx) / n
+	last := samples[len(samples)-1].Time.Sub(t0).Seconds()
+	return intercept + trend*last, trend, true
+}
+
+// Holt returns a Model which smooths the level of the series with the
+// factor alpha and its trend with beta, both between 0 and 1. The larger
+// they are, the more the recent samples weigh. The samples needn't be
+// evenly spaced; the trend is per second.
+func Holt(alpha, beta float64) Model {
+	return func(samples []Sample) (level, trend float64, ok bool) {
+		if len(samples) < 2 {
+			return 0, 0, false
+		}
+		level = samples[0].Value
+		prev := samples[0].Time
+		initialized := false
+		for _, s := range samples[1:] {
+			dt := s.Time.Sub(prev).Seconds()
+			if dt <= 0 {
+				continue
+			}
+			if !initialized {
+				// The trend starts as the slope
+				// between the first two samples.
+				trend = (s.Value - level) / dt
+				level = s.Value
+				prev = s.Time
+				initialized = true
+				continue
+			}
+			l := alpha*s.Value + (1-alpha)*(level+trend*dt)
+			trend = beta*(l-level)/dt + (1-beta)*trend
+			level = l
+			prev = s.Time
+		}
+		return level, trend, initialized
+	}
+}
+
+// TimeTo returns the time the series with the level and trend
+// takes to grow to the target, or 0 if it already has. It returns
+// false if it never does, i.e. if it isn't growing.
+func TimeTo(level, trend, target float64) (time.Duration, bool) {
+	if level >= target {
+		return 0, true
+	}
+	if trend <= 0 {
+		return 0, false
+	}
+	secs := (target - level) / trend
+	if secs > math.MaxInt64/float64(time.Second) {
+		return 0, false
+	}
+	return time.Duration(secs * float64(time.Second)), true
+}
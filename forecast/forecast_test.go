@@ -0,0 +1,107 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// series returns n samples every step, starting from v and
+// growing by the values of growth in turn.
+func series(n int, step time.Duration, v float64, growth ...float64) []Sample {
+	var a []Sample
+	for i := 0; i < n; i++ {
+		a = append(a, Sample{t0.Add(time.Duration(i) * step), v})
+		v += growth[i%len(growth)]
+	}
+	return a
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-6*math.Max(1, math.Abs(b))
+}
+
+func TestWindow(t *testing.T) {
+	w := Window{Span: 2 * time.Minute}
+	for _, s := range series(5, time.Minute, 0, 1) {
+		w.Add(s)
+	}
+	// older than the last sample
+	w.Add(Sample{t0, 100})
+	if len(w.Samples) != 3 {
+		t.Fatalf("got %d samples; want 3", len(w.Samples))
+	}
+	if w.Samples[0].Value != 2 || w.Samples[2].Value != 4 {
+		t.Errorf("got %v; want the samples 2 to 4", w.Samples)
+	}
+}
+
+func TestModels(t *testing.T) {
+	tests := []struct {
+		name         string
+		model        Model
+		samples      []Sample
+		level, trend float64
+		ok           bool
+	}{
+		{"linear", Linear, series(10, time.Minute, 100, 60), 640, 1, true},
+		{"linear flat", Linear, series(10, time.Minute, 100, 0), 100, 0, true},
+		// alternately grows by 0 and 120 per minute
+		{"linear noisy", Linear, series(11, time.Minute, 0, 0, 120), 6300.0 / 11, 1, true},
+		{"linear single", Linear, series(1, time.Minute, 100, 1), 0, 0, false},
+		{"linear no span", Linear, series(3, 0, 100, 1), 0, 0, false},
+		{"holt", Holt(0.5, 0.5), series(10, time.Minute, 100, 60), 640, 1, true},
+		{"holt single", Holt(0.5, 0.5), series(1, time.Minute, 100, 1), 0, 0, false},
+	}
+	for _, tt := range tests {
+		level, trend, ok := tt.model(tt.samples)
+		if ok != tt.ok || !near(level, tt.level) || !near(trend, tt.trend) {
+			t.Errorf("%s: got %v, %v, %v; want %v, %v, %v", tt.name, level, trend, ok, tt.level, tt.trend, tt.ok)
+		}
+	}
+}
+
+func TestHoltFollowsTrend(t *testing.T) {
+	// Flat for an hour, then growing by 10 a minute.
+	samples := series(60, time.Minute, 0, 0)
+	last := samples[len(samples)-1]
+	for i := 1; i <= 30; i++ {
+		samples = append(samples, Sample{last.Time.Add(time.Duration(i) * time.Minute), float64(10 * i)})
+	}
+	_, holt, _ := Holt(0.5, 0.3)(samples)
+	_, linear, _ := Linear(samples)
+	want := 10.0 / 60
+	if math.Abs(holt-want) > math.Abs(linear-want) {
+		t.Errorf("Holt trend %v is further than the linear %v from %v", holt, linear, want)
+	}
+	if !near(holt, want) && math.Abs(holt-want) > 0.01 {
+		t.Errorf("Holt trend = %v; want about %v", holt, want)
+	}
+}
+
+func TestTimeTo(t *testing.T) {
+	tests := []struct {
+		level, trend, target float64
+		want                 time.Duration
+		ok                   bool
+	}{
+		{100, 1, 160, time.Minute, true},
+		{100, 0.5, 100 + 3600, 2 * time.Hour, true},
+		{200, 1, 100, 0, true},
+		{100, 0, 200, 0, false},
+		{100, -1, 200, 0, false},
+		{0, 1e-300, 1e300, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := TimeTo(tt.level, tt.trend, tt.target)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("TimeTo(%v, %v, %v) = %v, %v; want %v, %v", tt.level, tt.trend, tt.target, got, ok, tt.want, tt.ok)
+		}
+	}
+}
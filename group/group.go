@@ -0,0 +1,133 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// Package group addresses groups of agents by their labels, so that
+// a single message, e.g. a policy, configures every agent in a group.
+//
+// The agents subscribe to the subject of each of their labels and
+// operations, e.g. "tag.role.db.add_policy", and reply to the messages
+// received on them with an Ack carrying their UID. The sender gathers
+// those acks with Request.
+package group
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats"
+)
+
+// Prefix is the first token of the subjects of the groups.
+const Prefix = "tag"
+
+// Subject returns the subject on which the agents with the label
+// key=value receive the operation op, e.g. "tag.role.db.add_policy".
+// The dots, wildcards and white space, which can't be in a token of a
+// subject, are replaced by underscores, so that the kernel release
+// "3.13.0-24-generic" becomes "3_13_0-24-generic".
+func Subject(key, value, op string) string {
+	return Prefix + "." + token(key) + "." + token(value) + "." + op
+}
+
+// IsSubject reports whether subj is the subject of a group.
+func IsSubject(subj string) bool {
+	return strings.HasPrefix(subj, Prefix+".")
+}
+
+// token returns s with the characters which can't be
+// in a token of a subject replaced by underscores.
+func token(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// Ack is the reply of an agent to a message sent to its group.
+type Ack struct {
+	UID     string `json:"uid"`
+	Subject string `json:"subject"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message"` // e.g. "add_policy_ack", or the error
+}
+
+// Acks are the acks of a group, sorted by UID.
+type Acks []Ack
+
+// Failed returns the acks of the agents which failed.
+func (a Acks) Failed() Acks {
+	var f Acks
+	for _, k := range a {
+		if !k.OK {
+			f = append(f, k)
+		}
+	}
+	return f
+}
+
+func (a Acks) String() string {
+	return fmt.Sprintf("%d acks, %d failed", len(a), len(a.Failed()))
+}
+
+// Request publishes v on the subject subj of a group and gathers the
+// acks of its agents until want of them have replied, or until timeout
+// if want isn't positive or they don't all reply in time.
+func Request(c *nats.EncodedConn, subj string, v interface{}, want int, timeout time.Duration) (Acks, error) {
+	inbox := nats.NewInbox()
+	acks := make(chan *Ack)
+	stop := make(chan struct{}) // the acks received late are dropped
+	defer close(stop)
+	sub, err := c.Subscribe(inbox, func(a *Ack) {
+		select {
+		case acks <- a:
+		case <-stop:
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+	if err := c.PublishRequest(subj, inbox, v); err != nil {
+		return nil, err
+	}
+	return gather(acks, want, time.After(timeout)), nil
+}
+
+// gather gathers the acks until want of them are received, or until
+// done. An agent which replies more than once, e.g. because it is in
+// the group twice, is counted once, with its first failure if any.
+func gather(acks <-chan *Ack, want int, done <-chan time.Time) Acks {
+	m := make(map[string]Ack)
+loop:
+	for want <= 0 || len(m) < want {
+		select {
+		case a := <-acks:
+			if k, ok := m[a.UID]; !ok || k.OK {
+				m[a.UID] = *a
+			}
+		case <-done:
+			break loop
+		}
+	}
+	var a Acks
+	for _, k := range m {
+		a = append(a, k)
+	}
+	sort.Sort(byUID(a))
+	return a
+}
+
+type byUID Acks
+
+func (a byUID) Len() int           { return len(a) }
+func (a byUID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byUID) Less(i, j int) bool { return a[i].UID < a[j].UID }
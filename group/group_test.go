@@ -0,0 +1,73 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package group
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSubject(t *testing.T) {
+	tests := []struct {
+		key, value, op string
+		want           string
+	}{
+		{"role", "db", "add_policy", "tag.role.db.add_policy"},
+		{"kernel", "3.13.0-24-generic", "silence", "tag.kernel.3_13_0-24-generic.silence"},
+		{"team", "ops *", "delete_policy", "tag.team.ops__.delete_policy"},
+		{"team", "", "add_policy", "tag.team._.add_policy"},
+	}
+	for _, tt := range tests {
+		got := Subject(tt.key, tt.value, tt.op)
+		if got != tt.want {
+			t.Errorf("Subject(%q, %q, %q) = %q; want %q", tt.key, tt.value, tt.op, got, tt.want)
+		}
+		if !IsSubject(got) {
+			t.Errorf("IsSubject(%q) = false", got)
+		}
+	}
+	if IsSubject("0a1b2c3d4e5f_add_policy") {
+		t.Error("the subject of an agent is taken for that of a group")
+	}
+}
+
+func TestGather(t *testing.T) {
+	acks := make(chan *Ack, 5)
+	acks <- &Ack{UID: "b", OK: true}
+	acks <- &Ack{UID: "a", OK: true}
+	acks <- &Ack{UID: "a", Message: "policy with the given name already exists"}
+	acks <- &Ack{UID: "a", Message: "invalid policy"}
+	acks <- &Ack{UID: "c", OK: true}
+
+	// An agent which replies twice, e.g. because it is in the
+	// group twice, is counted once, with its first failure.
+	want := Acks{
+		{UID: "a", Message: "policy with the given name already exists"},
+		{UID: "b", OK: true},
+		{UID: "c", OK: true},
+	}
+	got := gather(acks, 3, nil)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	if f := got.Failed(); !reflect.DeepEqual(f, want[:1]) {
+		t.Errorf("got failed %v; want %v", f, want[:1])
+	}
+
+	// The agents which don't reply in time are left out.
+	acks <- &Ack{UID: "a", OK: true}
+	done := make(chan time.Time, 1)
+	go func() {
+		for len(acks) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		done <- time.Now()
+	}()
+	got = gather(acks, 2, done)
+	if want := (Acks{{UID: "a", OK: true}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
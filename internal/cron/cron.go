@@ -0,0 +1,187 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// Package cron parses the schedules of crontab(5), such as
+// "30 2 * * sun", which recur at the minutes they match.
+//
+// A schedule has five fields: the minute, the hour, the day of the
+// month, the month and the day of the week, from 0 on Sunday to 7, also
+// Sunday. Each field is "*", a number, a range such as "1-5", or a comma
+// separated list of them, and a range or "*" may have a step, as in
+// "*/15". The months and days of the week may also be named by their
+// first three letters. As in cron, if both the day of the month and of
+// the week are restricted, a day matches if either of them does.
+//
+// The schedules "@yearly", "@monthly", "@weekly", "@daily" and "@hourly"
+// are also accepted.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed schedule. Each field is a set of
+// the values it matches, as the bits of a uint64.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	anyDay                        bool // whether the day of the month or of the week is "*"
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	months = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	days   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// Parse parses the schedule spec.
+func Parse(spec string) (*Schedule, error) {
+	s := strings.TrimSpace(spec)
+	if m, ok := macros[s]; ok {
+		s = m
+	}
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron: %q has %d fields; want 5", spec, len(fields))
+	}
+	var (
+		sch Schedule
+		err error
+	)
+	if sch.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if sch.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if sch.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if sch.month, err = parseField(fields[3], 1, 12, months); err != nil {
+		return nil, err
+	}
+	if sch.dow, err = parseField(fields[4], 0, 7, days); err != nil {
+		return nil, err
+	}
+	// 7 is also Sunday.
+	if sch.dow&(1<<7) != 0 {
+		sch.dow |= 1
+	}
+	sch.anyDay = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*")
+	return &sch, nil
+}
+
+// parseField parses a field whose values are between min and max.
+// The names, if any, are those of the values from min.
+func parseField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rng = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("cron: invalid step in %q", part)
+			}
+		}
+		lo, hi := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			i := strings.Index(rng, "-")
+			var err error
+			if lo, err = parseValue(rng[:i], min, max, names); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(rng[i+1:], min, max, names); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("cron: invalid range %q", rng)
+			}
+		default:
+			v, err := parseValue(rng, min, max, names)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseValue parses a number or a name of a field.
+func parseValue(s string, min, max int, names []string) (int, error) {
+	for i, n := range names {
+		if strings.EqualFold(s, n) {
+			return min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("cron: %q is not between %d and %d", s, min, max)
+	}
+	return v, nil
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// day reports whether the schedule matches the day of t.
+func (s *Schedule) day(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Match reports whether the schedule matches the minute of t.
+func (s *Schedule) Match(t time.Time) bool {
+	return has(s.minute, t.Minute()) && has(s.hour, t.Hour()) &&
+		has(s.month, int(t.Month())) && s.day(t)
+}
+
+// Next returns the first minute after t, in the location of t, which
+// the schedule matches. It returns the zero time if there is none in the
+// next five years, e.g. for the 30th of February.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.day(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
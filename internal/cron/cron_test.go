@@ -0,0 +1,77 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@often",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q): want an error", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// A Monday.
+	from := time.Date(2015, 6, 1, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2015, 6, 1, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2015, 6, 1, 10, 30, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2015, 6, 2, 2, 0, 0, 0, time.UTC)},
+		{"30 2 * * sun", time.Date(2015, 6, 7, 2, 30, 0, 0, time.UTC)},
+		{"30 2 * * 7", time.Date(2015, 6, 7, 2, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * mon-fri", time.Date(2015, 6, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2015, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * fri", time.Date(2015, 6, 5, 0, 0, 0, 0, time.UTC)}, // either day
+		{"0 0 29 feb *", time.Date(2016, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2015, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2015, 6, 1, 11, 0, 0, 0, time.UTC)},
+		{"0 0 30 feb *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next(%v) = %v; want %v", tt.spec, from, got, tt.want)
+		}
+		if !tt.want.IsZero() && !s.Match(tt.want) {
+			t.Errorf("%q: Match(%v) = false; want true", tt.spec, tt.want)
+		}
+	}
+}
+
+func TestNextLocation(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+1800)
+	s, err := Parse("0 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2015, 6, 1, 10, 15, 0, 0, loc)
+	if got, want := s.Next(from), time.Date(2015, 6, 2, 2, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Next(%v) = %v; want %v", from, got, want)
+	}
+}
@@ -6,7 +6,9 @@
 package fileutil
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 // Exists returns true if a file exists.
@@ -16,3 +18,57 @@ func Exists(path string) bool {
 	}
 	return false
 }
+
+// WriteFile writes data to the named file atomically, i.e. after a
+// crash or a failed write the file has either its old or its new
+// contents, never a mix or nothing. The data is written to a temporary
+// file in the same directory, synced to the disk, and renamed over the
+// file; then the directory is synced, so that the rename is durable.
+func WriteFile(name string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(name)
+	f, err := ioutil.TempFile(dir, filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	// Never defer a file Close when the file was opened for writing;
+	// many filesystems report their failures on close.
+	if err := write(f, data, perm); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return syncDir(dir)
+}
+
+// write writes data to f, sets its permissions and syncs it.
+func write(f *os.File, data []byte, perm os.FileMode) error {
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// syncDir syncs the directory dir, making the changes of its entries,
+// such as renames, durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "config.json")
+
+	for _, s := range []string{"old", "new"} {
+		if err := WriteFile(name, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != s {
+			t.Errorf("got %q; want %q", b, s)
+		}
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("got permissions %v; want %v", perm, os.FileMode(0600))
+	}
+
+	// The temporary files are cleaned up.
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Errorf("got files %v; want only %s", names, name)
+	}
+
+	// A failed write leaves the file as it was.
+	if err := WriteFile(filepath.Join(dir, "missing", "config.json"), []byte("x"), 0600); err == nil {
+		t.Error("want an error for the missing directory")
+	}
+}
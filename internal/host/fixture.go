@@ -0,0 +1,99 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codeignition/recon/internal/fileutil"
+	"golang.org/x/net/context"
+)
+
+// Transcript is a Runner which returns canned command outputs.
+// It maps a command line, i.e. the command name and its arguments
+// separated by single spaces, to the output of the command.
+type Transcript map[string][]byte
+
+// Output returns the canned output of the command. Commands
+// which are not in the transcript fail as if they were not found.
+func (t Transcript) Output(ctx context.Context, name string, arg ...string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cmd := strings.Join(append([]string{name}, arg...), " ")
+	out, ok := t[cmd]
+	if !ok {
+		return nil, fmt.Errorf("%s: command not found in transcript", cmd)
+	}
+	return out, nil
+}
+
+// ReadTranscript reads a transcript file. Each command output in the
+// file is preceded by a line holding the command line between "-- "
+// and " --", e.g.
+//
+//	-- uname -r --
+//	3.13.0-24-generic
+func ReadTranscript(file string) (Transcript, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t := make(Transcript)
+	var (
+		cmd string
+		out bytes.Buffer
+	)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "-- ") && strings.HasSuffix(line, " --") {
+			if cmd != "" {
+				t[cmd] = append([]byte(nil), out.Bytes()...)
+			}
+			cmd = strings.TrimSuffix(strings.TrimPrefix(line, "-- "), " --")
+			out.Reset()
+			continue
+		}
+		if cmd == "" {
+			continue // comments before the first command
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if cmd != "" {
+		t[cmd] = out.Bytes()
+	}
+	return t, nil
+}
+
+// Fixture points Root at the directory dir and Exec at the transcript
+// in the file "commands" under dir, if it exists. It returns a function
+// which restores the previous Root and Exec. It is meant to be used
+// in tests.
+func Fixture(dir string) (restore func(), err error) {
+	root, runner := Root, Exec
+	t := make(Transcript)
+	if p := filepath.Join(dir, "commands"); fileutil.Exists(p) {
+		t, err = ReadTranscript(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+	Root, Exec = dir, t
+	return func() {
+		Root, Exec = root, runner
+	}, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// Package host provides access to the files and commands of the
+// machine that the metric collectors read from. Both the root of the
+// filesystem and the command runner can be replaced, so that the
+// collectors can be run against fixture directories and canned
+// command outputs.
+package host
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"golang.org/x/net/context"
+)
+
+// Root is the directory treated as the root of the filesystem
+// by the collectors, e.g. "/host" when recond runs in a container
+// with the host filesystem mounted there. It is $RECOND_ROOT if set.
+var Root = "/"
+
+func init() {
+	if d := os.Getenv("RECOND_ROOT"); d != "" {
+		Root = d
+	}
+}
+
+// Exec is the runner used by the collectors to run commands.
+var Exec Runner = execRunner{}
+
+// Path returns the path of name relative to Root.
+func Path(name string) string {
+	return filepath.Join(Root, name)
+}
+
+// Open opens the named file relative to Root for reading.
+func Open(name string) (*os.File, error) {
+	return os.Open(Path(name))
+}
+
+// ReadFile reads the named file relative to Root.
+func ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(Path(name))
+}
+
+// ReadDir returns the names of the entries of the
+// named directory relative to Root.
+func ReadDir(name string) ([]string, error) {
+	f, err := os.Open(Path(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdirnames(-1)
+}
+
+// Stat returns the FileInfo of the named file relative to Root.
+func Stat(name string) (os.FileInfo, error) {
+	return os.Stat(Path(name))
+}
+
+// Exists returns true if the named file relative to Root exists.
+func Exists(name string) bool {
+	_, err := os.Stat(Path(name))
+	return err == nil
+}
+
+// Runner runs commands.
+type Runner interface {
+	// Output runs the command and returns its standard output.
+	// The command must be stopped when ctx is done.
+	Output(ctx context.Context, name string, arg ...string) ([]byte, error)
+}
+
+// Command runs the command with Exec and returns its standard output.
+func Command(ctx context.Context, name string, arg ...string) ([]byte, error) {
+	return Exec.Output(ctx, name, arg...)
+}
+
+type execRunner struct{}
+
+func (execRunner) Output(ctx context.Context, name string, arg ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, arg...).Output()
+}
@@ -0,0 +1,63 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestFixture(t *testing.T) {
+	restore, err := Fixture("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	tests := []struct {
+		cmd  []string
+		want string
+	}{
+		{[]string{"uname", "-r"}, "3.13.0-24-generic\n"},
+		{[]string{"/usr/bin/lsb_release", "-a"}, "Distributor ID:\tUbuntu\nRelease:\t14.04\n\n"},
+		{[]string{"true"}, ""},
+	}
+	for _, tt := range tests {
+		out, err := Command(context.Background(), tt.cmd[0], tt.cmd[1:]...)
+		if err != nil {
+			t.Errorf("%v: %v", tt.cmd, err)
+			continue
+		}
+		if string(out) != tt.want {
+			t.Errorf("%v: got %q; want %q", tt.cmd, out, tt.want)
+		}
+	}
+	if _, err := Command(context.Background(), "uname", "-a"); err == nil {
+		t.Error("want error for a command not in the transcript")
+	}
+
+	b, err := ReadFile("/proc/comm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "systemd\n" {
+		t.Errorf("got %q; want %q", b, "systemd\n")
+	}
+}
+
+func TestFixtureRestore(t *testing.T) {
+	restore, err := Fixture("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore()
+	if Root != "/" {
+		t.Errorf("want Root restored to %q; got %q", "/", Root)
+	}
+	if _, ok := Exec.(execRunner); !ok {
+		t.Errorf("want Exec restored to execRunner; got %T", Exec)
+	}
+}
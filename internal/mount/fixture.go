@@ -0,0 +1,75 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package mount
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/codeignition/recon/internal/host"
+)
+
+// Fixture points the host package at dir, as host.Fixture does, and
+// makes Statfs return the usages in the file "statfs" under dir instead
+// of calling statfs. Each line of the file holds a mountpoint followed
+// by the fields of its Usage, in order, e.g.
+//
+//	/boot 97033216 23697408 66189312 24480 24 24456
+//
+// It returns a function which restores the previous Root, Exec and
+// Statfs. It is meant to be used in tests.
+func Fixture(dir string) (restore func(), err error) {
+	f, err := os.Open(filepath.Join(dir, "statfs"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	usages := make(map[string]Usage)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		a := strings.Fields(s.Text())
+		if len(a) == 0 || strings.HasPrefix(a[0], "#") {
+			continue
+		}
+		if len(a) != 7 {
+			return nil, fmt.Errorf("mount: unexpected statfs line %q", s.Text())
+		}
+		var n [6]uint64
+		for i := range n {
+			if n[i], err = strconv.ParseUint(a[i+1], 10, 64); err != nil {
+				return nil, fmt.Errorf("mount: unexpected statfs line %q", s.Text())
+			}
+		}
+		usages[filepath.Join(dir, unescape(a[0]))] = Usage{n[0], n[1], n[2], n[3], n[4], n[5]}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	restoreHost, err := host.Fixture(dir)
+	if err != nil {
+		return nil, err
+	}
+	prev := Statfs
+	Statfs = func(path string) (Usage, error) {
+		u, ok := usages[path]
+		if !ok {
+			return Usage{}, syscall.ENOENT
+		}
+		return u, nil
+	}
+	return func() {
+		Statfs = prev
+		restoreHost()
+	}, nil
+}
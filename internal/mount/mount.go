@@ -0,0 +1,304 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+// Package mount provides the mounted filesystems of the machine,
+// read from /proc/self/mountinfo, and their usage, found by calling
+// statfs on each of them.
+//
+// statfs blocks on a network filesystem whose server is gone, so
+// it is given a timeout per mount, and a mount whose statfs hasn't
+// returned yet isn't called again until it does.
+package mount
+
+import (
+	"bufio"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+// Mount is a mounted filesystem.
+type Mount struct {
+	Device  string   // e.g. /dev/sda1, or tmpfs for a filesystem without one
+	Point   string   // the mountpoint, e.g. /boot
+	Type    string   // e.g. ext4
+	Options []string // the mount and the filesystem options, e.g. rw and errors=remount-ro
+}
+
+// Usage is the usage of a filesystem, as reported by df.
+// The sizes are in bytes.
+type Usage struct {
+	Size      uint64
+	Used      uint64
+	Available uint64 // to unprivileged users
+
+	Inodes     uint64
+	InodesUsed uint64
+	InodesFree uint64
+}
+
+// Filter selects the mounts by their filesystem type and
+// mountpoint. The mountpoints are patterns as in path.Match,
+// each of which also matches the mountpoints under it, e.g.
+// "/sys" matches "/sys/kernel/security" and "/var/lib/docker/*"
+// matches what is under /var/lib/docker but not itself.
+type Filter struct {
+	// Types and Points are the types and mountpoints included,
+	// or all if empty.
+	Types  []string
+	Points []string
+
+	// ExcludeTypes and ExcludePoints are the types and mountpoints
+	// excluded, unless they are included explicitly.
+	ExcludeTypes  []string
+	ExcludePoints []string
+}
+
+// DefaultFilter is the filter used by the collectors. It excludes the
+// virtual filesystems, which have no usage worth reporting, and the
+// layers of the containers.
+var DefaultFilter = Filter{
+	ExcludeTypes: []string{
+		"autofs", "binfmt_misc", "bpf", "cgroup", "cgroup2", "configfs",
+		"debugfs", "devpts", "devtmpfs", "efivarfs", "fusectl", "hugetlbfs",
+		"mqueue", "nsfs", "overlay", "proc", "pstore", "rpc_pipefs",
+		"securityfs", "selinuxfs", "squashfs", "sysfs", "tmpfs", "tracefs",
+	},
+	ExcludePoints: []string{
+		"/dev", "/proc", "/sys",
+		"/var/lib/docker/*", "/var/lib/containers/*", "/var/lib/kubelet/pods",
+	},
+}
+
+// Match reports whether the mount m is selected by the filter f.
+func (f Filter) Match(m Mount) bool {
+	return selects(f.Types, f.ExcludeTypes, m.Type, func(t string) bool {
+		return t == m.Type
+	}) && selects(f.Points, f.ExcludePoints, m.Point, func(p string) bool {
+		return matchPoint(p, m.Point)
+	})
+}
+
+// selects reports whether s is selected by the include and exclude
+// lists, using match to compare it with their entries.
+func selects(include, exclude []string, s string, match func(string) bool) bool {
+	for _, x := range include {
+		if match(x) {
+			return true
+		}
+	}
+	if len(include) > 0 {
+		return false
+	}
+	for _, x := range exclude {
+		if match(x) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPoint reports whether the pattern matches the mountpoint
+// or one of the directories above it.
+func matchPoint(pattern, point string) bool {
+	for p := point; ; p = path.Dir(p) {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+		if p == "/" || p == "." {
+			return false
+		}
+	}
+}
+
+// List returns the mounts selected by the filter f, in the order
+// in which they were mounted. A filesystem mounted over another
+// hides it, so the hidden mount is left out.
+func List(f Filter) ([]Mount, error) {
+	r, err := host.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var mounts []Mount
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		m, err := parse(s.Text())
+		if err != nil {
+			return nil, err
+		}
+		// The later mount on the same point is the visible one.
+		for i := range mounts {
+			if mounts[i].Point == m.Point {
+				mounts = append(mounts[:i], mounts[i+1:]...)
+				break
+			}
+		}
+		mounts = append(mounts, m)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	var selected []Mount
+	for _, m := range mounts {
+		if f.Match(m) {
+			selected = append(selected, m)
+		}
+	}
+	return selected, nil
+}
+
+// parse parses a line of /proc/self/mountinfo, e.g.
+//
+//	36 25 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,errors=remount-ro
+//
+// The optional fields, such as shared:1, end with a "-".
+func parse(line string) (Mount, error) {
+	f := strings.Fields(line)
+	sep := -1
+	for i := 6; i < len(f); i++ {
+		if f[i] == "-" {
+			sep = i
+			break
+		}
+	}
+	if sep == -1 || len(f) < sep+3 {
+		return Mount{}, fmt.Errorf("mount: unexpected mountinfo line %q", line)
+	}
+	m := Mount{
+		Device:  unescape(f[sep+2]),
+		Point:   unescape(f[4]),
+		Type:    f[sep+1],
+		Options: strings.Split(f[5], ","),
+	}
+	if len(f) > sep+3 {
+		for _, o := range strings.Split(f[sep+3], ",") {
+			if !contains(m.Options, o) {
+				m.Options = append(m.Options, o)
+			}
+		}
+	}
+	return m, nil
+}
+
+// unescape replaces the octal escapes of the spaces, tabs,
+// newlines and backslashes in the paths of mountinfo, e.g.
+// "/media/My\040Disk".
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b = append(b, byte(n))
+				i += 3
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+func contains(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Timeout is the time given to statfs on each mount.
+var Timeout = 5 * time.Second
+
+// Statfs returns the usage of the filesystem at path. It is a
+// variable so that the tests can replace it.
+var Statfs = statfs
+
+func statfs(path string) (Usage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return Usage{}, err
+	}
+	bs := uint64(st.Frsize)
+	if bs == 0 {
+		bs = uint64(st.Bsize)
+	}
+	return Usage{
+		Size:       st.Blocks * bs,
+		Used:       (st.Blocks - st.Bfree) * bs,
+		Available:  st.Bavail * bs,
+		Inodes:     st.Files,
+		InodesUsed: st.Files - st.Ffree,
+		InodesFree: st.Ffree,
+	}, nil
+}
+
+// call is a statfs of a mountpoint.
+type call struct {
+	start time.Time
+	done  chan struct{} // closed when statfs returns
+	u     Usage
+	err   error
+}
+
+// pending holds the statfs of the mountpoints which haven't returned.
+var pending = struct {
+	sync.Mutex
+	m map[string]*call
+}{
+	m: make(map[string]*call),
+}
+
+// Stat returns the usage of the filesystem mounted at the mountpoint.
+// The concurrent callers share a single statfs of the mountpoint, which
+// they give Timeout, or less if ctx is done. Once it has been blocked
+// for longer than Timeout, the callers fail right away until it returns.
+func Stat(ctx context.Context, point string) (Usage, error) {
+	p := host.Path(point)
+	pending.Lock()
+	c, ok := pending.m[p]
+	if !ok {
+		c = &call{start: time.Now(), done: make(chan struct{})}
+		pending.m[p] = c
+		go func() {
+			c.u, c.err = Statfs(p)
+			pending.Lock()
+			delete(pending.m, p)
+			pending.Unlock()
+			close(c.done)
+		}()
+	}
+	pending.Unlock()
+
+	wait := Timeout - time.Since(c.start)
+	if wait <= 0 {
+		return Usage{}, fmt.Errorf("mount: statfs %s: still blocked", point)
+	}
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	select {
+	case <-c.done:
+		if c.err != nil {
+			return Usage{}, fmt.Errorf("mount: statfs %s: %v", point, c.err)
+		}
+		return c.u, nil
+	case <-ctx.Done():
+		return Usage{}, fmt.Errorf("mount: statfs %s: %v", point, ctx.Err())
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package mount
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+func TestList(t *testing.T) {
+	restore, err := host.Fixture("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	got, err := List(DefaultFilter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Mount{
+		{"/dev/mapper/vgubuntu-root", "/", "ext4", []string{"rw", "relatime", "errors=remount-ro"}},
+		{"nas:/export/media", "/mnt/nas", "nfs4", []string{"rw", "relatime", "vers=4.2", "rsize=1048576"}},
+		{"/dev/sdb1", "/media/alice/My Passport", "exfat", []string{"rw", "nosuid", "nodev", "relatime", "fmask=0022"}},
+		{"/dev/sdc1", "/var/lib/docker", "xfs", []string{"rw", "relatime", "attr2", "inode64"}},
+		// mounted over /dev/nvme0n1p2
+		{"/dev/sda1", "/boot/efi", "vfat", []string{"rw", "relatime"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		f     Filter
+		typ   string
+		point string
+		want  bool
+	}{
+		{DefaultFilter, "ext4", "/", true},
+		{DefaultFilter, "tmpfs", "/run", false},
+		{DefaultFilter, "ext4", "/sys/fs/pstore", false},
+		{DefaultFilter, "xfs", "/var/lib/docker", true},
+		{DefaultFilter, "ext4", "/var/lib/docker/volumes/data", false},
+		{Filter{Types: []string{"tmpfs"}, ExcludeTypes: []string{"tmpfs"}}, "tmpfs", "/run", true},
+		{Filter{Types: []string{"tmpfs"}}, "ext4", "/", false},
+		{Filter{Points: []string{"/mnt"}}, "nfs4", "/mnt/nas", true},
+		{Filter{Points: []string{"/mnt"}}, "ext4", "/", false},
+		{Filter{Points: []string{"/media/*"}}, "vfat", "/media/usb", true},
+		{Filter{ExcludePoints: []string{"/media/*"}}, "ext4", "/media", true},
+	}
+	for _, tt := range tests {
+		m := Mount{Type: tt.typ, Point: tt.point}
+		if got := tt.f.Match(m); got != tt.want {
+			t.Errorf("%+v.Match(%s on %s) = %v; want %v", tt.f, tt.typ, tt.point, got, tt.want)
+		}
+	}
+}
+
+func TestStat(t *testing.T) {
+	restore, err := Fixture("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	got, err := Stat(context.Background(), "/media/alice/My Passport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Usage{Size: 1000202043392, Used: 412316860416, Available: 587885182976}
+	if got != want {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+	if _, err := Stat(context.Background(), "/mnt/nas"); err == nil {
+		t.Error("want error for a mount not in the fixture")
+	}
+}
+
+func TestStatTimeout(t *testing.T) {
+	prev, prevTimeout := Statfs, Timeout
+	defer func() { Statfs, Timeout = prev, prevTimeout }()
+
+	var calls int32
+	unblock := make(chan struct{})
+	Statfs = func(string) (Usage, error) {
+		atomic.AddInt32(&calls, 1)
+		<-unblock
+		return Usage{Size: 1}, nil
+	}
+	Timeout = 10 * time.Millisecond
+
+	ctx := context.Background()
+	if _, err := Stat(ctx, "/mnt/nas"); err == nil {
+		t.Fatal("want error for a blocked statfs")
+	}
+	// Not called again while the first call is blocked.
+	if _, err := Stat(ctx, "/mnt/nas"); err == nil || !strings.Contains(err.Error(), "still blocked") {
+		t.Fatalf("got error %v; want one for the statfs still blocked", err)
+	}
+	close(unblock)
+
+	var u Usage
+	var err error
+	for i := 0; i < 100; i++ {
+		if u, err = Stat(ctx, "/mnt/nas"); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Size != 1 {
+		t.Errorf("got %+v; want Size 1", u)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("statfs called %d times; want 2", n)
+	}
+}
+
+func TestStatShared(t *testing.T) {
+	prev, prevTimeout := Statfs, Timeout
+	defer func() { Statfs, Timeout = prev, prevTimeout }()
+
+	var calls int32
+	unblock := make(chan struct{})
+	Statfs = func(string) (Usage, error) {
+		atomic.AddInt32(&calls, 1)
+		<-unblock
+		return Usage{Size: 1}, nil
+	}
+	Timeout = time.Minute
+
+	// e.g. the filesystem and the disk forecast collectors
+	// stat the same mountpoint at the same time.
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, err := Stat(context.Background(), "/data")
+			if err == nil && u.Size != 1 {
+				err = fmt.Errorf("got %+v; want Size 1", u)
+			}
+			errs <- err
+		}()
+	}
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond) // for the other to join
+	close(unblock)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("statfs called %d times; want 1", n)
+	}
+}
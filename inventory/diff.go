@@ -0,0 +1,104 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package inventory
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change operations
+const (
+	Add     = "add"
+	Remove  = "remove"
+	Replace = "replace"
+)
+
+// Change is a single difference between two facts documents.
+type Change struct {
+	// Path is the slash separated path of the changed value,
+	// e.g. "kernel/release". Slashes and tildes in the keys are
+	// escaped as in a JSON pointer (RFC 6901).
+	Path string      `json:"path"`
+	Op   string      `json:"op"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Diff returns the changes, sorted by path, required to turn
+// the old facts into the new ones. Lists are compared as a
+// whole. Diff expects facts as returned by Collect.
+func Diff(old, cur Facts) []Change {
+	var c []Change
+	diff(&c, nil, map[string]interface{}(old), map[string]interface{}(cur))
+	sort.Sort(byPath(c))
+	return c
+}
+
+func diff(c *[]Change, path []string, old, cur interface{}) {
+	o, ok1 := old.(map[string]interface{})
+	n, ok2 := cur.(map[string]interface{})
+	if !ok1 || !ok2 {
+		if !reflect.DeepEqual(old, cur) {
+			*c = append(*c, Change{Path: join(path), Op: Replace, Old: old, New: cur})
+		}
+		return
+	}
+	for k, v := range o {
+		p := append(path[:len(path):len(path)], k)
+		if w, ok := n[k]; ok {
+			diff(c, p, v, w)
+		} else {
+			*c = append(*c, Change{Path: join(p), Op: Remove, Old: v})
+		}
+	}
+	for k, v := range n {
+		if _, ok := o[k]; !ok {
+			p := append(path[:len(path):len(path)], k)
+			*c = append(*c, Change{Path: join(p), Op: Add, New: v})
+		}
+	}
+}
+
+var escaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func join(path []string) string {
+	a := make([]string, len(path))
+	for i := range path {
+		a[i] = escaper.Replace(path[i])
+	}
+	return strings.Join(a, "/")
+}
+
+type byPath []Change
+
+func (a byPath) Len() int           { return len(a) }
+func (a byPath) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byPath) Less(i, j int) bool { return a[i].Path < a[j].Path }
+
+// remove removes the values matching the path from the facts.
+// A "*" element in the path matches any key.
+func (f Facts) remove(path []string) {
+	remove(map[string]interface{}(f), path)
+}
+
+func remove(m map[string]interface{}, path []string) {
+	if len(path) == 0 {
+		return
+	}
+	for k, v := range m {
+		if path[0] != "*" && path[0] != k {
+			continue
+		}
+		if len(path) == 1 {
+			delete(m, k)
+			continue
+		}
+		if n, ok := v.(map[string]interface{}); ok {
+			remove(n, path[1:])
+		}
+	}
+}
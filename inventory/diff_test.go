@@ -0,0 +1,98 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := Facts{
+		"kernel": map[string]interface{}{
+			"release": "3.13.0-24-generic",
+			"name":    "Linux",
+		},
+		"network": map[string]interface{}{
+			"interfaces": map[string]interface{}{
+				"eth0": map[string]interface{}{"mtu": "1500"},
+			},
+		},
+		"etc": map[string]interface{}{
+			"passwd": map[string]interface{}{
+				"root": map[string]interface{}{"uid": "0"},
+				"bob":  map[string]interface{}{"uid": "1000"},
+			},
+		},
+		"cpu": map[string]interface{}{
+			"0": map[string]interface{}{"flags": []interface{}{"fpu", "vme"}},
+		},
+	}
+	cur := Facts{
+		"kernel": map[string]interface{}{
+			"release": "3.13.0-32-generic",
+			"name":    "Linux",
+		},
+		"network": map[string]interface{}{
+			"interfaces": map[string]interface{}{
+				"eth0":    map[string]interface{}{"mtu": "1500"},
+				"docker0": map[string]interface{}{"mtu": "1500"},
+			},
+		},
+		"etc": map[string]interface{}{
+			"passwd": map[string]interface{}{
+				"root": map[string]interface{}{"uid": "0"},
+			},
+		},
+		"cpu": map[string]interface{}{
+			"0": map[string]interface{}{"flags": []interface{}{"fpu", "vme"}},
+		},
+		"lsb": map[string]interface{}{"id": "Ubuntu"},
+	}
+	want := []Change{
+		{Path: "etc/passwd/bob", Op: Remove, Old: map[string]interface{}{"uid": "1000"}},
+		{Path: "kernel/release", Op: Replace, Old: "3.13.0-24-generic", New: "3.13.0-32-generic"},
+		{Path: "lsb", Op: Add, New: map[string]interface{}{"id": "Ubuntu"}},
+		{Path: "network/interfaces/docker0", Op: Add, New: map[string]interface{}{"mtu": "1500"}},
+	}
+	got := Diff(old, cur)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+
+	if c := Diff(cur, cur); len(c) != 0 {
+		t.Errorf("want no changes between identical facts; got %v", c)
+	}
+}
+
+func TestDiffEscapesPath(t *testing.T) {
+	old := Facts{"filesystem": map[string]interface{}{"/dev/sda1": "ext4"}}
+	cur := Facts{"filesystem": map[string]interface{}{"/dev/sda1": "xfs"}}
+	c := Diff(old, cur)
+	if len(c) != 1 || c[0].Path != "filesystem/~1dev~1sda1" {
+		t.Errorf(`want a single change at "filesystem/~1dev~1sda1"; got %v`, c)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	f := Facts{
+		"cpu": map[string]interface{}{
+			"0":     map[string]interface{}{"mhz": "800.000", "model": "58"},
+			"1":     map[string]interface{}{"mhz": "2400.000", "model": "58"},
+			"total": 2.0,
+		},
+	}
+	f.remove([]string{"cpu", "*", "mhz"})
+	want := Facts{
+		"cpu": map[string]interface{}{
+			"0":     map[string]interface{}{"model": "58"},
+			"1":     map[string]interface{}{"model": "58"},
+			"total": 2.0,
+		},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("got %v; want %v", f, want)
+	}
+}
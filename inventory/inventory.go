@@ -0,0 +1,129 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+// Package inventory gathers the data of the ohai-like metric
+// collectors into a host facts document, and finds the changes
+// between two such documents.
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/codeignition/recon/metrics"
+	_ "github.com/codeignition/recon/metrics/all"
+	"golang.org/x/net/context"
+)
+
+// Facts is the host facts document. It maps a collector
+// name to the data collected by it.
+type Facts map[string]interface{}
+
+// Collectors are the names of the collectors whose
+// data makes up the facts.
+var Collectors = []string{
+	"cpu",
+	"memory",
+	"kernel",
+	"lsb",
+	"network",
+	"blockdevice",
+	"etc",
+	"languages",
+	"initpackage",
+	"virtualization",
+}
+
+// volatile are the paths of the data which changes too often
+// to be a fact, e.g. the free memory. They are left out of the
+// facts so that they don't show up as changes.
+var volatile = []string{
+	"cpu/*/mhz",
+	"kernel/modules/*/refcount",
+	"memory/free",
+	"memory/available",
+	"memory/buffers",
+	"memory/cached",
+	"memory/active",
+	"memory/inactive",
+	"memory/dirty",
+	"memory/writeback",
+	"memory/anon_pages",
+	"memory/mapped",
+	"memory/slab",
+	"memory/slab_reclaimable",
+	"memory/slab_unreclaim",
+	"memory/page_tables",
+	"memory/nfs_unstable",
+	"memory/bounce",
+	"memory/committed_as",
+	"memory/vmalloc_used",
+	"memory/vmalloc_chunk",
+	"memory/swap/free",
+	"memory/swap/cached",
+	"network/arp",
+	"network/neighbour_inet6",
+}
+
+// Timeout is the time each collector is given to collect its facts.
+var Timeout = time.Minute
+
+// Collect collects the facts. If a collector fails, its facts are
+// carried over from prev so that a transient failure doesn't show up
+// as a change. The failures are reported in the returned error.
+func Collect(ctx context.Context, prev Facts) (Facts, error) {
+	var cs []metrics.Collector
+	var errs metrics.Errors
+	for _, name := range Collectors {
+		c, ok := metrics.Lookup(name)
+		if !ok {
+			errs = append(errs, metrics.Error{Collector: name, Error: "collector not registered"})
+			continue
+		}
+		cs = append(cs, c)
+	}
+	a, cerrs := metrics.CollectAll(ctx, Timeout, cs...)
+	errs = append(errs, cerrs...)
+
+	f := make(Facts)
+	for name, d := range a {
+		v, err := normalize(d)
+		if err != nil {
+			errs = append(errs, metrics.Error{Collector: name, Error: err.Error()})
+			continue
+		}
+		f[name] = v
+	}
+	for _, e := range errs {
+		if v, ok := prev[e.Collector]; ok {
+			f[e.Collector] = v
+		}
+	}
+	for _, p := range volatile {
+		f.remove(strings.Split(p, "/"))
+	}
+	if len(errs) > 0 {
+		return f, fmt.Errorf("inventory: %s", errs)
+	}
+	return f, nil
+}
+
+// normalize converts the collected data into the generic JSON
+// types so that facts can be compared irrespective of the types
+// used by each collector.
+func normalize(d interface{}) (interface{}, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
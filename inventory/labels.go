@@ -0,0 +1,48 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package inventory
+
+import (
+	"runtime"
+	"strings"
+)
+
+// labelFacts maps the labels derived from the facts
+// to the paths of the facts they are derived from.
+var labelFacts = []struct {
+	label string
+	path  []string
+}{
+	{"kernel", []string{"kernel", "release"}},
+	{"arch", []string{"kernel", "machine"}},
+	{"distro", []string{"lsb", "id"}},
+	{"distro_version", []string{"lsb", "release"}},
+	{"virtualization", []string{"virtualization", "system"}},
+}
+
+// Labels returns the labels derived from the facts f, e.g.
+// {"os": "linux", "distro": "ubuntu", "virtualization": "kvm"},
+// to be attached to the agent and its events. The labels whose
+// facts are missing are left out; the values are lower case.
+func Labels(f Facts) map[string]string {
+	l := map[string]string{
+		"os": runtime.GOOS,
+	}
+	for _, lf := range labelFacts {
+		var v interface{} = map[string]interface{}(f)
+		for _, k := range lf.path {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				v = nil
+				break
+			}
+			v = m[k]
+		}
+		if s, ok := v.(string); ok && s != "" {
+			l[lf.label] = strings.ToLower(s)
+		}
+	}
+	return l
+}
@@ -0,0 +1,46 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package inventory
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestLabels(t *testing.T) {
+	f := Facts{
+		"kernel": map[string]interface{}{
+			"name":    "Linux",
+			"release": "3.13.0-24-generic",
+			"machine": "x86_64",
+		},
+		"lsb": map[string]interface{}{
+			"id":      "Ubuntu",
+			"release": "14.04",
+		},
+		"virtualization": map[string]interface{}{
+			"system": "kvm",
+			"role":   "guest",
+		},
+	}
+	want := map[string]string{
+		"os":             runtime.GOOS,
+		"kernel":         "3.13.0-24-generic",
+		"arch":           "x86_64",
+		"distro":         "ubuntu",
+		"distro_version": "14.04",
+		"virtualization": "kvm",
+	}
+	if got := Labels(f); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+
+	// Missing facts, e.g. of a failed collector, are left out.
+	want = map[string]string{"os": runtime.GOOS}
+	if got := Labels(Facts{"lsb": "unknown"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
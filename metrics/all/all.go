@@ -0,0 +1,29 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+// Package all imports every metrics package so that
+// their collectors are registered with the metrics package.
+package all
+
+import (
+	_ "github.com/codeignition/recon/metrics/misc/blockdevice"
+	_ "github.com/codeignition/recon/metrics/misc/counters"
+	_ "github.com/codeignition/recon/metrics/misc/cpu"
+	_ "github.com/codeignition/recon/metrics/misc/etc"
+	_ "github.com/codeignition/recon/metrics/misc/filesystem"
+	_ "github.com/codeignition/recon/metrics/misc/initpackage"
+	_ "github.com/codeignition/recon/metrics/misc/kernel"
+	_ "github.com/codeignition/recon/metrics/misc/languages"
+	_ "github.com/codeignition/recon/metrics/misc/lsb"
+	_ "github.com/codeignition/recon/metrics/misc/memory"
+	_ "github.com/codeignition/recon/metrics/misc/netstat"
+	_ "github.com/codeignition/recon/metrics/misc/network"
+	_ "github.com/codeignition/recon/metrics/misc/ps"
+	_ "github.com/codeignition/recon/metrics/misc/uptime"
+	_ "github.com/codeignition/recon/metrics/misc/virtualization"
+	_ "github.com/codeignition/recon/metrics/system"
+	_ "github.com/codeignition/recon/metrics/system/cgroup"
+)
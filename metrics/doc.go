@@ -0,0 +1,38 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+/*
+Package metrics provides a registry of the metric collectors
+found in its subpackages. Each subpackage registers its collector
+when it is imported, so that policies can request them by name.
+
+Units
+
+The collectors return numbers as numbers, in the same units everywhere:
+
+	sizes      bytes, e.g. memory, disk, cache and module sizes
+	durations  seconds, e.g. uptime and CPU time
+	shares     ratios between 0 and 1, e.g. CPU and disk usage
+	counts     integers, e.g. packets, inodes and cores
+	sensors    degrees Celsius, volts and RPM
+
+Identifiers, such as process, user and CPU model ids, remain strings,
+except the process ids which are integers. Where the key used to name
+the unit, it was renamed along with the value:
+
+	disk, filesystem   kb_size, kb_used, kb_available  size, used, available
+	                   percentage_used                  used_ratio
+	                   inodes_percentage_used           inodes_used_ratio
+	ps                 percentage_cpu_used              cpu_used_ratio
+	                   percentage_mem_used              memory_used_ratio
+	uptime             uptime_seconds, uptime           uptime
+	                   idletime_seconds, idletime       idletime
+
+The flags of the block devices, removable and rotational, are booleans.
+
+Setting LegacyUnits makes the collectors return the values in the shape
+they used to, i.e. mostly strings with the units embedded in them such
+as "16303256 kB" and "42%". recond sets it with the -legacy-units flag.
+*/
+package metrics
@@ -0,0 +1,193 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Collector collects a particular kind of data from the system.
+type Collector interface {
+	// Name is the unique name of the collector, e.g. "cpu".
+	Name() string
+	// Description briefly describes the data collected.
+	Description() string
+	// Collect collects the data and returns an error if any.
+	// It must give up and return when ctx is done.
+	Collect(ctx context.Context) (interface{}, error)
+}
+
+// CollectFunc is the type of the function wrapped by NewCollector.
+type CollectFunc func(context.Context) (interface{}, error)
+
+type collector struct {
+	name        string
+	description string
+	f           CollectFunc
+}
+
+func (c collector) Name() string        { return c.name }
+func (c collector) Description() string { return c.description }
+
+func (c collector) Collect(ctx context.Context) (interface{}, error) {
+	return c.f(ctx)
+}
+
+// NewCollector returns a Collector with the given name and
+// description which calls f to collect the data.
+func NewCollector(name, description string, f CollectFunc) Collector {
+	return collector{
+		name:        name,
+		description: description,
+		f:           f,
+	}
+}
+
+// collectorMap maps a collector name to the collector
+var collectorMap = struct {
+	sync.Mutex
+	m map[string]Collector
+}{
+	m: make(map[string]Collector),
+}
+
+// Register registers the collector with its name.
+func Register(c Collector) error {
+	if c == nil {
+		return errors.New("collector can't be nil")
+	}
+	name := c.Name()
+	if name == "" {
+		return errors.New("collector name can't be empty")
+	}
+
+	collectorMap.Lock()
+	defer collectorMap.Unlock()
+
+	if _, ok := collectorMap.m[name]; ok {
+		return errors.New("collector with the given name already exists")
+	}
+
+	collectorMap.m[name] = c
+	return nil
+}
+
+// Lookup returns the collector registered with the given name.
+func Lookup(name string) (Collector, bool) {
+	collectorMap.Lock()
+	c, ok := collectorMap.m[name]
+	collectorMap.Unlock()
+	return c, ok
+}
+
+// Names returns the sorted names of all the registered collectors.
+func Names() []string {
+	collectorMap.Lock()
+	names := make([]string, 0, len(collectorMap.m))
+	for k := range collectorMap.m {
+		names = append(names, k)
+	}
+	collectorMap.Unlock()
+	sort.Strings(names)
+	return names
+}
+
+// Error is the error returned by a particular collector.
+type Error struct {
+	Collector string `json:"collector"`
+	Error     string `json:"error"`
+	TimedOut  bool   `json:"timed_out,omitempty"`
+}
+
+// Errors is a list of collector errors.
+type Errors []Error
+
+func (e Errors) Error() string {
+	a := make([]string, len(e))
+	for i := range e {
+		a[i] = e[i].Collector + ": " + e[i].Error
+	}
+	return strings.Join(a, "; ")
+}
+
+// CollectAll runs the collectors in parallel and maps their names to
+// the collected data. Each collector is given a context with the timeout
+// as its deadline. A collector that fails or doesn't return before its
+// deadline is left out of the data and reported in the returned errors,
+// which are sorted by the collector name.
+func CollectAll(ctx context.Context, timeout time.Duration, cs ...Collector) (map[string]interface{}, Errors) {
+	type result struct {
+		name     string
+		data     interface{}
+		err      error
+		timedOut bool
+	}
+	results := make(chan result, len(cs))
+	for _, c := range cs {
+		go func(c Collector) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			// done is buffered so that a collector stuck in a system call
+			// which can't be cancelled, e.g. reading from a hung NFS mount,
+			// exits whenever it returns, even if nobody waits for it anymore.
+			done := make(chan result, 1)
+			go func() {
+				// A collector which panics fails
+				// rather than taking the agent down.
+				defer func() {
+					if r := recover(); r != nil {
+						done <- result{name: c.Name(), err: fmt.Errorf("panic: %v", r)}
+					}
+				}()
+				d, err := c.Collect(ctx)
+				done <- result{name: c.Name(), data: d, err: err}
+			}()
+			select {
+			case r := <-done:
+				if r.err != nil && ctx.Err() == context.DeadlineExceeded {
+					r.timedOut = true
+				}
+				results <- r
+			case <-ctx.Done():
+				results <- result{
+					name:     c.Name(),
+					err:      ctx.Err(),
+					timedOut: ctx.Err() == context.DeadlineExceeded,
+				}
+			}
+		}(c)
+	}
+
+	d := make(map[string]interface{})
+	var errs Errors
+	for range cs {
+		r := <-results
+		if r.err != nil {
+			e := Error{Collector: r.name, Error: r.err.Error(), TimedOut: r.timedOut}
+			if r.timedOut {
+				e.Error = fmt.Sprintf("timed out after %v: %s", timeout, r.err)
+			}
+			errs = append(errs, e)
+			continue
+		}
+		d[r.name] = r.data
+	}
+	sort.Sort(byCollector(errs))
+	return d, errs
+}
+
+type byCollector Errors
+
+func (a byCollector) Len() int           { return len(a) }
+func (a byCollector) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byCollector) Less(i, j int) bool { return a[i].Collector < a[j].Collector }
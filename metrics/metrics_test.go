@@ -0,0 +1,120 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func fakeCollect(ctx context.Context) (interface{}, error) {
+	return map[string]string{"foo": "foo_value"}, nil
+}
+
+// unregister removes the collector registered with the name,
+// so that the tests can be run more than once.
+func unregister(name string) {
+	collectorMap.Lock()
+	delete(collectorMap.m, name)
+	collectorMap.Unlock()
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(nil); err == nil {
+		t.Fatal("Register should return an error when the collector is nil")
+	}
+	if err := Register(NewCollector("", "fake", fakeCollect)); err == nil {
+		t.Fatal("Register should return an error when the name is empty")
+	}
+	if err := Register(NewCollector("fake", "fake", fakeCollect)); err != nil {
+		t.Fatal(err)
+	}
+	defer unregister("fake")
+
+	// test registering twice
+	if err := Register(NewCollector("fake", "fake", fakeCollect)); err == nil {
+		t.Fatal(`want error "collector with the given name already exists"; got nil`)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if _, ok := Lookup("unknownDummyCollector"); ok {
+		t.Error("want unknown collector lookup to fail")
+	}
+	Register(NewCollector("lookup", "fake", fakeCollect))
+	defer unregister("lookup")
+	c, ok := Lookup("lookup")
+	if !ok {
+		t.Fatal("want registered collector to be found")
+	}
+	d, err := c.Collect(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := d.(map[string]string)["foo"]; v != "foo_value" {
+		t.Errorf(`want d["foo"] = %s; got %s`, "foo_value", v)
+	}
+
+	var found bool
+	for _, name := range Names() {
+		if name == "lookup" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error(`want "lookup" in Names()`)
+	}
+}
+
+func TestCollectAll(t *testing.T) {
+	ok := NewCollector("ok", "fake", fakeCollect)
+	failing := NewCollector("failing", "fake", func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("unexpected output")
+	})
+	slow := NewCollector("slow", "fake", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	panicking := NewCollector("panicking", "fake", func(ctx context.Context) (interface{}, error) {
+		var m map[string]int
+		m["boom"]++
+		return m, nil
+	})
+	hung := NewCollector("hung", "fake", func(ctx context.Context) (interface{}, error) {
+		// ignores the context, like a read from a hung NFS mount
+		time.Sleep(time.Second)
+		return "too late", nil
+	})
+
+	d, errs := CollectAll(context.Background(), 100*time.Millisecond, hung, slow, failing, panicking, ok)
+	if _, found := d["ok"]; !found {
+		t.Error(`want the data of "ok" collector`)
+	}
+	if len(d) != 1 {
+		t.Errorf("want data of only 1 collector; got %d", len(d))
+	}
+	if len(errs) != 4 {
+		t.Fatalf("want 4 errors; got %v", errs)
+	}
+	for _, e := range errs {
+		switch e.Collector {
+		case "failing", "panicking":
+			if e.TimedOut {
+				t.Errorf("want %q collector to fail without timing out; got %v", e.Collector, e)
+			}
+		default:
+			if !e.TimedOut {
+				t.Errorf("want %q collector to time out; got %v", e.Collector, e)
+			}
+		}
+	}
+	if e := errs[2]; e.Collector != "panicking" || !strings.HasPrefix(e.Error, "panic: ") {
+		t.Errorf("want the panic of the panicking collector; got %v", e)
+	}
+}
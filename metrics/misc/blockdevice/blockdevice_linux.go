@@ -7,30 +7,33 @@ package blockdevice
 
 import (
 	"errors"
-	"io/ioutil"
-	"os"
+	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 
-	"github.com/codeignition/recon/internal/fileutil"
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("blockdevice", "block devices from /sys/block", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 // Data represents the block devices data.
 type Data map[string]interface{}
 
 // CollectData collects the data and returns an error if any.
-func CollectData() (Data, error) {
+func CollectData(ctx context.Context) (Data, error) {
 	d := make(Data)
 	basePath := "/sys/block/"
-	if !fileutil.Exists(basePath) {
+	if !host.Exists(basePath) {
 		return nil, errors.New("blockdevice: cannot find /sys/block/ directory")
 	}
-	f, err := os.Open(basePath)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	devices, err := f.Readdirnames(-1)
+	devices, err := host.ReadDir(basePath)
 	if err != nil {
 		return nil, err
 	}
@@ -41,8 +44,8 @@ func CollectData() (Data, error) {
 		devicePath := filepath.Join(basePath, device)
 		for _, key := range [...]string{"size", "removable"} {
 			p := filepath.Join(devicePath, key)
-			if fileutil.Exists(p) {
-				b, err := ioutil.ReadFile(p)
+			if host.Exists(p) {
+				b, err := host.ReadFile(p)
 				if err != nil {
 					return nil, err
 				}
@@ -52,8 +55,8 @@ func CollectData() (Data, error) {
 
 		for _, key := range [...]string{"model", "rev", "state", "timeout", "vendor"} {
 			p := filepath.Join(devicePath, "device", key)
-			if fileutil.Exists(p) {
-				b, err := ioutil.ReadFile(p)
+			if host.Exists(p) {
+				b, err := host.ReadFile(p)
 				if err != nil {
 					return nil, err
 				}
@@ -62,13 +65,54 @@ func CollectData() (Data, error) {
 		}
 
 		p := filepath.Join(devicePath, "queue", "rotational")
-		if fileutil.Exists(p) {
-			b, err := ioutil.ReadFile(p)
+		if host.Exists(p) {
+			b, err := host.ReadFile(p)
 			if err != nil {
 				return nil, err
 			}
 			m["rotational"] = strings.TrimSpace(string(b))
 		}
+
+		if !metrics.LegacyUnits {
+			t, err := typed(m)
+			if err != nil {
+				return nil, fmt.Errorf("blockdevice: %s: %v", device, err)
+			}
+			d[device] = t
+		}
 	}
 	return d, nil
 }
+
+// sectorSize is the size of the sectors in which the kernel
+// reports the size of a block device, irrespective of the
+// actual sector size of the device.
+const sectorSize = 512
+
+// typed returns the device data m with its numeric values
+// converted; the size in bytes, the timeout in seconds and
+// the flags as booleans.
+func typed(m map[string]string) (map[string]interface{}, error) {
+	t := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		switch k {
+		case "size":
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid size %q", v)
+			}
+			t[k] = n * sectorSize
+		case "timeout":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timeout %q", v)
+			}
+			t[k] = n
+		case "removable", "rotational":
+			t[k] = v == "1"
+		default:
+			t[k] = v
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package blockdevice
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Data
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			want: Data{
+				"sda": map[string]interface{}{
+					"size":       uint64(250059350016),
+					"removable":  false,
+					"model":      "ST9500325AS",
+					"vendor":     "ATA",
+					"rev":        "0001",
+					"state":      "running",
+					"timeout":    30,
+					"rotational": true,
+				},
+				"sr0": map[string]interface{}{
+					"size":       uint64(1073741312),
+					"removable":  true,
+					"model":      "DVD+-RW GT32N",
+					"vendor":     "HL-DT-ST",
+					"rotational": true,
+				},
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			want: Data{
+				"nvme0n1": map[string]interface{}{
+					"size":       uint64(512110190592),
+					"removable":  false,
+					"model":      "Samsung SSD 980 PRO 1TB",
+					"state":      "live",
+					"rotational": false,
+				},
+				"loop0": map[string]interface{}{
+					"size":       uint64(4096),
+					"removable":  false,
+					"rotational": false,
+				},
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			want: Data{
+				"vda": map[string]interface{}{
+					"size":       uint64(21474836480),
+					"removable":  false,
+					"rotational": true,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, tt.want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.fixture, d, tt.want)
+		}
+	}
+}
+
+func TestCollectDataMissingSysfs(t *testing.T) {
+	restore, err := host.Fixture("testdata/nonexistent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+	if _, err := CollectData(context.Background()); err == nil {
+		t.Error("want error when /sys/block is missing")
+	}
+}
@@ -7,15 +7,28 @@
 package counters
 
 import (
-	"os/exec"
+	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("counters", "network interface counters", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 // Data represents the counters data.
 type Data map[string]interface{}
 
 // CollectData collects the data and returns an error if any.
-func CollectData() (Data, error) {
+// It uses the output of `ip -d -s link`, and falls back to
+// /proc/net/dev where iproute2 is missing, e.g. with busybox.
+func CollectData(ctx context.Context) (Data, error) {
 	d := make(Data)
 
 	d["network"] = make(map[string]interface{})
@@ -24,40 +37,120 @@ func CollectData() (Data, error) {
 	network["interfaces"] = make(map[string]interface{})
 	ifaces := network["interfaces"].(map[string]interface{})
 
-	out, err := exec.Command("ip", "-d", "-s", "link").Output()
+	out, err := host.Command(ctx, "ip", "-d", "-s", "link")
 	if err != nil {
-		return nil, err
+		if err := procNetDev(ifaces); err != nil {
+			return nil, err
+		}
+		return d, nil
 	}
 	lines := strings.Split(string(out), "\n")
-	for i := 0; i < len(lines); {
-		if strings.ContainsAny(lines[i], "<>") {
-			rx := strings.Fields(lines[i+3]) // bytes  packets  errors  dropped overrun mcast
-			tx := strings.Fields(lines[i+5]) // bytes  packets  errors  dropped carrier collsns
-
-			k := strings.TrimSpace(strings.Split(lines[i], ":")[1]) // [0] is the index
-			ifaces[k] = map[string]map[string]string{
-				"rx": {
-					"bytes":   rx[0],
-					"packets": rx[1],
-					"errors":  rx[2],
-					"dropped": rx[3],
-					"overrun": rx[4],
-					"mcast":   rx[5],
-				},
-				"tx": {
-					"bytes":      tx[0],
-					"packets":    tx[1],
-					"errors":     tx[2],
-					"dropped":    tx[3],
-					"carrier":    tx[4],
-					"collisions": tx[5],
-				},
+	var k string // current interface
+	for i := 0; i < len(lines); i++ {
+		// 2: eth0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc pfifo_fast state UP mode DEFAULT group default qlen 1000
+		//     link/ether 52:54:00:12:34:56 brd ff:ff:ff:ff:ff:ff promiscuity 0
+		//     RX: bytes  packets  errors  dropped overrun mcast
+		//     1234       12       0       0       0       0
+		//     TX: bytes  packets  errors  dropped carrier collsns
+		//     1234       12       0       0       0       0
+		if strings.ContainsAny(lines[i], "<>") && !strings.HasPrefix(lines[i], " ") {
+			k = strings.TrimSpace(strings.Split(lines[i], ":")[1]) // [0] is the index
+			continue
+		}
+		line := strings.TrimSpace(lines[i])
+		if k == "" || i+1 >= len(lines) {
+			continue
+		}
+		a := strings.Fields(lines[i+1])
+		if len(a) < 6 {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "RX:"):
+			rx, err := stats(rxKeys, a)
+			if err != nil {
+				return nil, err
 			}
-
-			i += 6
-		} else {
+			counters(ifaces, k)["rx"] = rx
+			i++
+		case strings.HasPrefix(line, "TX:"):
+			tx, err := stats(txKeys, a)
+			if err != nil {
+				return nil, err
+			}
+			counters(ifaces, k)["tx"] = tx
 			i++
 		}
 	}
 	return d, nil
 }
+
+// rxKeys and txKeys are the keys of the receive and transmit
+// counters, in the order of the columns of `ip -s link`.
+var (
+	rxKeys = []string{"bytes", "packets", "errors", "dropped", "overrun", "mcast"}
+	txKeys = []string{"bytes", "packets", "errors", "dropped", "carrier", "collisions"}
+)
+
+// stats maps the keys to the corresponding values.
+func stats(keys, values []string) (interface{}, error) {
+	if metrics.LegacyUnits {
+		m := make(map[string]string, len(keys))
+		for i, k := range keys {
+			m[k] = values[i]
+		}
+		return m, nil
+	}
+	m := make(map[string]uint64, len(keys))
+	for i, k := range keys {
+		n, err := strconv.ParseUint(values[i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("counters: invalid %s %q", k, values[i])
+		}
+		m[k] = n
+	}
+	return m, nil
+}
+
+// counters returns the counters map of the interface k,
+// adding it to ifaces if it doesn't exist.
+func counters(ifaces map[string]interface{}, k string) map[string]interface{} {
+	if _, ok := ifaces[k]; !ok {
+		ifaces[k] = make(map[string]interface{})
+	}
+	return ifaces[k].(map[string]interface{})
+}
+
+// procNetDev adds the counters in /proc/net/dev to ifaces.
+func procNetDev(ifaces map[string]interface{}) error {
+	b, err := host.ReadFile("/proc/net/dev")
+	if err != nil {
+		return err
+	}
+	// Inter-|   Receive                                                |  Transmit
+	//  face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+	//   eth0: 1234    12    0    0    0     0          0         0     1234    12    0    0    0     0       0          0
+	lines := strings.Split(string(b), "\n")
+	for _, line := range lines {
+		l := strings.SplitN(line, ":", 2)
+		if len(l) != 2 {
+			continue
+		}
+		a := strings.Fields(l[1])
+		if len(a) < 16 {
+			continue
+		}
+		rx, err := stats(rxKeys, []string{a[0], a[1], a[2], a[3], a[4], a[7]})
+		if err != nil {
+			return err
+		}
+		tx, err := stats(txKeys, []string{a[8], a[9], a[10], a[11], a[14], a[13]})
+		if err != nil {
+			return err
+		}
+		c := counters(ifaces, strings.TrimSpace(l[0]))
+		c["rx"] = rx
+		c["tx"] = tx
+	}
+	return nil
+}
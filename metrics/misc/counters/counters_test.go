@@ -0,0 +1,82 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package counters
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		iface   string
+		rx, tx  map[string]uint64
+		count   int // number of interfaces
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			iface:   "wlan0",
+			count:   2,
+			rx:      map[string]uint64{"bytes": 912345678, "packets": 712345, "errors": 0, "dropped": 12, "overrun": 0, "mcast": 0},
+			tx:      map[string]uint64{"bytes": 51234567, "packets": 312345, "errors": 0, "dropped": 0, "carrier": 0, "collisions": 0},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			iface:   "enp1s0",
+			count:   3,
+			rx:      map[string]uint64{"bytes": 3123456789, "packets": 2345678, "errors": 0, "dropped": 213, "overrun": 0, "mcast": 10234},
+			tx:      map[string]uint64{"bytes": 123456789, "packets": 912345, "errors": 0, "dropped": 0, "carrier": 0, "collisions": 0},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			iface:   "veth1a2b3c4@if4",
+			count:   3,
+			rx:      map[string]uint64{"bytes": 45678, "packets": 321, "errors": 0, "dropped": 0, "overrun": 0, "mcast": 0},
+			tx:      map[string]uint64{"bytes": 98765, "packets": 654, "errors": 0, "dropped": 0, "carrier": 0, "collisions": 0},
+		},
+		{
+			// busybox has no `ip -s`, so /proc/net/dev is used
+			fixture: "alpine-3.18",
+			iface:   "eth0",
+			count:   2,
+			rx:      map[string]uint64{"bytes": 8912345, "packets": 61234, "errors": 1, "dropped": 2, "overrun": 3, "mcast": 45},
+			tx:      map[string]uint64{"bytes": 1234567, "packets": 9876, "errors": 0, "dropped": 4, "carrier": 6, "collisions": 5},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		ifaces := d["network"].(map[string]interface{})["interfaces"].(map[string]interface{})
+		if len(ifaces) != tt.count {
+			t.Errorf("%s: got %d interfaces; want %d", tt.fixture, len(ifaces), tt.count)
+		}
+		c, ok := ifaces[tt.iface].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: interface %s not found", tt.fixture, tt.iface)
+			continue
+		}
+		if !reflect.DeepEqual(c["rx"], tt.rx) {
+			t.Errorf("%s: %s rx: got %v; want %v", tt.fixture, tt.iface, c["rx"], tt.rx)
+		}
+		if !reflect.DeepEqual(c["tx"], tt.tx) {
+			t.Errorf("%s: %s tx: got %v; want %v", tt.fixture, tt.iface, c["tx"], tt.tx)
+		}
+	}
+}
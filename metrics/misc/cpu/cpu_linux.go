@@ -7,17 +7,28 @@ package cpu
 
 import (
 	"bufio"
-	"os"
+	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("cpu", "processors and their features from /proc/cpuinfo", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 // Data represents the CPU data.
 type Data map[string]interface{}
 
 // CollectData collects the data and returns an error if any.
-func CollectData() (Data, error) {
+func CollectData(ctx context.Context) (Data, error) {
 	d := make(Data)
-	f, err := os.Open("/proc/cpuinfo")
+	f, err := host.Open("/proc/cpuinfo")
 	if err != nil {
 		return nil, err
 	}
@@ -55,8 +66,22 @@ func CollectData() (Data, error) {
 				pm["stepping"] = v
 			case "cpu MHz":
 				pm["mhz"] = v
+				if !metrics.LegacyUnits {
+					mhz, err := strconv.ParseFloat(v, 64)
+					if err != nil {
+						return nil, fmt.Errorf("cpu: invalid cpu MHz %q", v)
+					}
+					pm["mhz"] = mhz
+				}
 			case "cache size":
 				pm["cache_size"] = v
+				if !metrics.LegacyUnits {
+					b, err := metrics.ParseBytes(v)
+					if err != nil {
+						return nil, fmt.Errorf("cpu: cache size: %v", err)
+					}
+					pm["cache_size"] = b
+				}
 			case "physical id":
 				pm["physical_id"] = v
 				real[v] = struct{}{}
@@ -64,6 +89,13 @@ func CollectData() (Data, error) {
 				pm["core_id"] = v
 			case "cpu cores":
 				pm["cores"] = v
+				if !metrics.LegacyUnits {
+					n, err := strconv.Atoi(v)
+					if err != nil {
+						return nil, fmt.Errorf("cpu: invalid cpu cores %q", v)
+					}
+					pm["cores"] = n
+				}
 			case "flags":
 				pm["flags"] = strings.Split(v, " ")
 
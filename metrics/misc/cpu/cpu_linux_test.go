@@ -0,0 +1,95 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package cpu
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		total, real int
+		first       map[string]interface{} // data of processor "0"
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			total:   2,
+			real:    1,
+			first: map[string]interface{}{
+				"vendor_id":   "GenuineIntel",
+				"family":      "6",
+				"model":       "58",
+				"model_name":  "Intel(R) Core(TM) i5-3210M CPU @ 2.50GHz",
+				"stepping":    "9",
+				"mhz":         1200.0,
+				"cache_size":  uint64(3145728),
+				"physical_id": "0",
+				"core_id":     "0",
+				"cores":       2,
+				"flags":       []string{"fpu", "vme", "de", "pse", "tsc", "msr", "pae", "mce"},
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			total:   1,
+			real:    1,
+			first: map[string]interface{}{
+				"vendor_id":   "AuthenticAMD",
+				"family":      "25",
+				"model":       "80",
+				"model_name":  "AMD Ryzen 7 5800U with Radeon Graphics",
+				"stepping":    "0",
+				"mhz":         1397.003,
+				"cache_size":  uint64(524288),
+				"physical_id": "0",
+				"core_id":     "0",
+				"cores":       8,
+				"flags":       []string{"fpu", "vme", "de", "pse", "tsc", "msr", "pae", "mce", "cx8", "apic", "sep"},
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			total:   2,
+			real:    2,
+			first: map[string]interface{}{
+				"vendor_id":   "GenuineIntel",
+				"family":      "6",
+				"model":       "85",
+				"model_name":  "Intel Xeon Processor (Cascadelake)",
+				"stepping":    "6",
+				"mhz":         2992.968,
+				"cache_size":  uint64(16777216),
+				"physical_id": "0",
+				"core_id":     "0",
+				"cores":       1,
+				"flags":       []string{"fpu", "vme", "de", "pse", "tsc", "msr", "pae", "mce", "hypervisor"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if d["total"] != tt.total || d["real"] != tt.real {
+			t.Errorf("%s: got total %v, real %v; want total %d, real %d", tt.fixture, d["total"], d["real"], tt.total, tt.real)
+		}
+		if got := d["0"]; !reflect.DeepEqual(got, tt.first) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.fixture, got, tt.first)
+		}
+	}
+}
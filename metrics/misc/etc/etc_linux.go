@@ -6,20 +6,29 @@ package etc
 
 import (
 	"bufio"
-	"os"
 	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("etc", "users and groups from /etc/passwd and /etc/group", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 // Data represents the etc data.
 type Data map[string]interface{}
 
 // CollectData collects the data and returns an error if any.
-func CollectData() (Data, error) {
+func CollectData(ctx context.Context) (Data, error) {
 	d := make(Data)
 
 	d["passwd"] = make(map[string]interface{})
 	passwd := d["passwd"].(map[string]interface{})
-	f, err := os.Open("/etc/passwd")
+	f, err := host.Open("/etc/passwd")
 	if err != nil {
 		return d, err
 	}
@@ -44,7 +53,7 @@ func CollectData() (Data, error) {
 
 	d["group"] = make(map[string]interface{})
 	group := d["group"].(map[string]interface{})
-	f, err = os.Open("/etc/group")
+	f, err = host.Open("/etc/group")
 	if err != nil {
 		return d, err
 	}
@@ -0,0 +1,101 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package etc
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture          string
+		users, groups    int
+		user, group      string
+		passwd, groupMap interface{}
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			users:   4,
+			groups:  4,
+			user:    "alice",
+			passwd: map[string]string{
+				"uid":   "1000",
+				"gid":   "1000",
+				"gecos": "Alice,,,",
+				"dir":   "/home/alice",
+				"shell": "/bin/bash",
+			},
+			group: "adm",
+			groupMap: map[string]interface{}{
+				"gid":     "4",
+				"members": []string{"syslog", "alice"},
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			users:   3,
+			groups:  3,
+			user:    "systemd-network",
+			passwd: map[string]string{
+				"uid":   "100",
+				"gid":   "102",
+				"gecos": "systemd Network Management,,,",
+				"dir":   "/run/systemd",
+				"shell": "/usr/sbin/nologin",
+			},
+			group: "docker",
+			groupMap: map[string]interface{}{
+				"gid":     "998",
+				"members": []string{"bob"},
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			users:   3,
+			groups:  3,
+			user:    "root",
+			passwd: map[string]string{
+				"uid":   "0",
+				"gid":   "0",
+				"gecos": "root",
+				"dir":   "/root",
+				"shell": "/bin/ash",
+			},
+			group: "nogroup",
+			groupMap: map[string]interface{}{
+				"gid":     "65533",
+				"members": []string{""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		passwd := d["passwd"].(map[string]interface{})
+		group := d["group"].(map[string]interface{})
+		if len(passwd) != tt.users || len(group) != tt.groups {
+			t.Errorf("%s: got %d users, %d groups; want %d users, %d groups", tt.fixture, len(passwd), len(group), tt.users, tt.groups)
+		}
+		if got := passwd[tt.user]; !reflect.DeepEqual(got, tt.passwd) {
+			t.Errorf("%s: %s: got %v; want %v", tt.fixture, tt.user, got, tt.passwd)
+		}
+		if got := group[tt.group]; !reflect.DeepEqual(got, tt.groupMap) {
+			t.Errorf("%s: %s: got %v; want %v", tt.fixture, tt.group, got, tt.groupMap)
+		}
+	}
+}
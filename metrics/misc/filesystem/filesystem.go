@@ -4,130 +4,113 @@
 
 // +build linux
 
+// Package filesystem provides the usage, inodes and mount options of
+// the mounted filesystems, keyed by their device. The filesystems are
+// those selected by mount.DefaultFilter, which leaves out the virtual
+// ones such as proc and tmpfs.
 package filesystem
 
 import (
-	"bufio"
-	"os"
-	"os/exec"
-	"strings"
+	"fmt"
+	"math"
+	"strconv"
 
-	"github.com/codeignition/recon/internal/fileutil"
+	"github.com/codeignition/recon/internal/mount"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("filesystem", "filesystem usage, inodes and mounts", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 // Data represents the filesystem data.
 type Data map[string]interface{}
 
 // CollectData collects the data and returns an error if any.
-func CollectData() (Data, error) {
-	d := make(Data)
-	if err := sizeData(d); err != nil {
-		return d, err
-	}
-	if err := inodeData(d); err != nil {
-		return d, err
+// A filesystem whose usage can't be found, e.g. a network
+// filesystem whose server is gone, has an error instead.
+func CollectData(ctx context.Context) (Data, error) {
+	mounts, err := mount.List(mount.DefaultFilter)
+	if err != nil {
+		return nil, err
 	}
-	if err := mountData(d); err != nil {
-		return d, err
+	d := make(Data)
+	for _, m := range mounts {
+		// A filesystem bind mounted elsewhere is
+		// reported at its first mountpoint.
+		if _, ok := d[m.Device]; ok {
+			continue
+		}
+		fs := map[string]interface{}{
+			"mount":         m.Point,
+			"fs_type":       m.Type,
+			"mount_options": m.Options,
+		}
+		u, err := mount.Stat(ctx, m.Point)
+		if err != nil {
+			fs["error"] = err.Error()
+			d[m.Device] = fs
+			continue
+		}
+		for k, v := range Sizes(u) {
+			fs[k] = v
+		}
+		fs["mounted_on"] = m.Point
+		inodes(fs, u)
+		d[m.Device] = fs
 	}
 	return d, nil
 }
 
-func sizeData(d Data) error {
-	out, err := exec.Command("df", "-P").Output()
-	if err != nil {
-		return err
-	}
-
-	lines := strings.Split(string(out), "\n")
-
-	// lines[0] contains the column headings
-	// Filesystem     1024-blocks     Used Available Capacity Mounted on
-	for _, line := range lines[1:] {
-		a := strings.Fields(line)
-		if len(a) >= 6 {
-			d[a[0]] = map[string]interface{}{
-				"kb_size":         a[1],
-				"kb_used":         a[2],
-				"kb_available":    a[3],
-				"percentage_used": a[4],
-				"mounted_on":      a[5],
-			}
+// Sizes returns the size, used and available space of the usage u
+// in bytes, and the used space as a ratio of the space available
+// to the unprivileged users, as df does.
+func Sizes(u mount.Usage) map[string]interface{} {
+	if metrics.LegacyUnits {
+		return map[string]interface{}{
+			"kb_size":         strconv.FormatUint(u.Size>>10, 10),
+			"kb_used":         strconv.FormatUint(u.Used>>10, 10),
+			"kb_available":    strconv.FormatUint(u.Available>>10, 10),
+			"percentage_used": percentage(u.Used, u.Used+u.Available),
 		}
 	}
-	return nil
-}
-
-func inodeData(d Data) error {
-	out, err := exec.Command("df", "-iP").Output()
-	if err != nil {
-		return err
+	m := map[string]interface{}{
+		"size":      u.Size,
+		"used":      u.Used,
+		"available": u.Available,
 	}
-
-	lines := strings.Split(string(out), "\n")
-
-	// lines[0] contains the column headings
-	// Filesystem       Inodes  IUsed    IFree IUse% Mounted on
-	for _, line := range lines[1:] {
-		a := strings.Fields(line)
-		if len(a) >= 6 {
-			m := d[a[0]].(map[string]interface{})
-			m["total_inodes"] = a[1]
-			m["inodes_used"] = a[2]
-			m["inodes_available"] = a[3]
-			m["inodes_percentage_used"] = a[4]
-			m["mount"] = a[5]
-		}
+	if u.Used+u.Available > 0 {
+		m["used_ratio"] = metrics.Ratio(100 * float64(u.Used) / float64(u.Used+u.Available))
 	}
-	return nil
+	return m
 }
 
-func mountData(d Data) error {
-	out, err := exec.Command("mount").Output()
-	if err != nil {
-		return err
+// inodes adds the inode counts of the usage u to fs. The ratio
+// is left out for the filesystems without inodes, e.g. vfat.
+func inodes(fs map[string]interface{}, u mount.Usage) {
+	if metrics.LegacyUnits {
+		fs["total_inodes"] = strconv.FormatUint(u.Inodes, 10)
+		fs["inodes_used"] = strconv.FormatUint(u.InodesUsed, 10)
+		fs["inodes_available"] = strconv.FormatUint(u.InodesFree, 10)
+		fs["inodes_percentage_used"] = percentage(u.InodesUsed, u.Inodes)
+		return
 	}
-
-	lines := strings.Split(string(out), "\n")
-	for _, line := range lines {
-		// proc on /proc type proc (rw,noexec,nosuid,nodev)
-
-		a := strings.Fields(line)
-		if len(a) >= 6 {
-			if _, ok := d[a[0]]; !ok {
-				d[a[0]] = make(map[string]interface{})
-			}
-			m := d[a[0]].(map[string]interface{})
-			m["mount"] = a[2]
-			m["fs_type"] = a[4]
-			m["mount_options"] = strings.Split(strings.Trim(a[5], "()"), ",")
-		}
+	fs["total_inodes"] = u.Inodes
+	fs["inodes_used"] = u.InodesUsed
+	fs["inodes_available"] = u.InodesFree
+	if u.Inodes > 0 {
+		fs["inodes_used_ratio"] = metrics.Ratio(100 * float64(u.InodesUsed) / float64(u.Inodes))
 	}
+}
 
-	// Get missing mount info from /proc/mounts
-
-	if fileutil.Exists("/proc/mounts") {
-		f, err := os.Open("/proc/mounts")
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		s := bufio.NewScanner(f)
-		for s.Scan() {
-			a := strings.Fields(s.Text())
-			if len(a) >= 4 {
-				if _, ok := d[a[0]]; !ok {
-					d[a[0]] = map[string]interface{}{
-						"mount":         a[1],
-						"fs_type":       a[2],
-						"mount_options": strings.Split(a[3], ","),
-					}
-				}
-			}
-		}
-		if err := s.Err(); err != nil {
-			return err
-		}
+// percentage returns n as a percentage of total rounded up,
+// e.g. "44%", or "-" if total is 0, as df prints it.
+func percentage(n, total uint64) string {
+	if total == 0 {
+		return "-"
 	}
-	return nil
+	return fmt.Sprintf("%d%%", uint64(math.Ceil(100*float64(n)/float64(total))))
 }
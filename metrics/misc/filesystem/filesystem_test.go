@@ -0,0 +1,111 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package filesystem
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/mount"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		fs      string
+		want    map[string]interface{}
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			fs:      "/dev/sda1",
+			want: map[string]interface{}{
+				"size":              uint64(245998739456),
+				"used":              uint64(100467040256),
+				"available":         uint64(133009924096),
+				"used_ratio":        0.4303,
+				"mounted_on":        "/",
+				"total_inodes":      uint64(15269888),
+				"inodes_used":       uint64(1123456),
+				"inodes_available":  uint64(14146432),
+				"inodes_used_ratio": 0.0736,
+				"mount":             "/",
+				"fs_type":           "ext4",
+				"mount_options":     []string{"rw", "relatime", "errors=remount-ro", "data=ordered"},
+			},
+		},
+		{
+			// excluded by default
+			fixture: "ubuntu-14.04",
+			fs:      "securityfs",
+			want:    nil,
+		},
+		{
+			fixture: "ubuntu-22.04",
+			fs:      "/dev/nvme0n1p2",
+			want: map[string]interface{}{
+				"size":             uint64(536834048),
+				"used":             uint64(6365184),
+				"available":        uint64(530468864),
+				"used_ratio":       0.0119,
+				"mounted_on":       "/boot/efi",
+				"total_inodes":     uint64(0),
+				"inodes_used":      uint64(0),
+				"inodes_available": uint64(0),
+				"mount":            "/boot/efi",
+				"fs_type":          "vfat",
+				"mount_options":    []string{"rw", "relatime", "fmask=0077", "dmask=0077", "codepage=437", "iocharset=iso8859-1", "shortname=mixed", "errors=remount-ro"},
+			},
+		},
+		{
+			// statfs fails
+			fixture: "ubuntu-22.04",
+			fs:      "nas:/export/media",
+			want: map[string]interface{}{
+				"mount":         "/mnt/nas",
+				"fs_type":       "nfs4",
+				"mount_options": []string{"rw", "relatime", "vers=4.2", "rsize=1048576"},
+				"error":         "mount: statfs /mnt/nas: no such file or directory",
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			fs:      "/dev/vda1",
+			want: map[string]interface{}{
+				"size":              uint64(97033216),
+				"used":              uint64(23697408),
+				"available":         uint64(66189312),
+				"used_ratio":        0.2636,
+				"mounted_on":        "/boot",
+				"total_inodes":      uint64(24480),
+				"inodes_used":       uint64(24),
+				"inodes_available":  uint64(24456),
+				"inodes_used_ratio": 0.001,
+				"mount":             "/boot",
+				"fs_type":           "ext4",
+				"mount_options":     []string{"rw", "relatime"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := mount.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		got, _ := d[tt.fs].(map[string]interface{})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %s:\ngot  %v\nwant %v", tt.fixture, tt.fs, got, tt.want)
+		}
+	}
+}
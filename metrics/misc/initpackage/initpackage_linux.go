@@ -7,18 +7,36 @@
 package initpackage
 
 import (
-	"io/ioutil"
 	"log"
 	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
 // Name is the name of the init package used by the system
 var Name string
 
 func init() {
-	if b, err := ioutil.ReadFile("/proc/1/comm"); err == nil {
-		Name = strings.TrimSpace(string(b))
+	if d, err := CollectData(context.Background()); err == nil {
+		Name = d["name"]
 	} else {
 		log.Println("initpackage: ", err)
 	}
+	metrics.Register(metrics.NewCollector("initpackage", "init package used by the system", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
+// Data holds the name of the init package.
+type Data map[string]string
+
+// CollectData collects the data and returns an error if any.
+func CollectData(ctx context.Context) (Data, error) {
+	b, err := host.ReadFile("/proc/1/comm")
+	if err != nil {
+		return nil, err
+	}
+	return Data{"name": strings.TrimSpace(string(b))}, nil
 }
@@ -0,0 +1,38 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package initpackage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture, name string
+	}{
+		{"ubuntu-14.04", "init"},
+		{"ubuntu-22.04", "systemd"},
+		{"alpine-3.18", "openrc-init"},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if d["name"] != tt.name {
+			t.Errorf("%s: got %q; want %q", tt.fixture, d["name"], tt.name)
+		}
+	}
+}
@@ -6,10 +6,21 @@
 package kernel
 
 import (
-	"os/exec"
+	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("kernel", "kernel name, release, version and loaded modules", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 // Data represents the kernel data.
 type Data map[string]interface{}
 
@@ -24,35 +35,51 @@ var unameArgs = map[string]string{
 }
 
 // CollectData collects the data and returns an error if any.
-func CollectData() (Data, error) {
+func CollectData(ctx context.Context) (Data, error) {
 	d := make(Data)
 	d["modules"] = make(map[string]interface{})
 	modules := d["modules"].(map[string]interface{})
 
 	for k, v := range unameArgs {
-		out, err := exec.Command("uname", v).Output()
+		out, err := host.Command(ctx, "uname", v)
 		if err != nil {
 			return nil, err
 		}
 		s := strings.TrimSpace(string(out))
 		d[k] = s
-		out, err = exec.Command("env", "lsmod").Output()
-		if err != nil {
-			return nil, err
+	}
+
+	out, err := host.Command(ctx, "env", "lsmod")
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(out), "\n")
+
+	// lines[0] contains column headings.
+	for _, line := range lines[1:] {
+		l := strings.Fields(line)
+		if len(l) < 3 {
+			continue
 		}
-		lines := strings.Split(string(out), "\n")
-
-		// lines[0] contains column headings.
-		for _, line := range lines[1:] {
-			l := strings.Fields(line)
-			if len(l) >= 3 {
-				modules[l[0]] = map[string]string{
-					"size":     l[1],
-					"refcount": l[2],
-				}
+		if metrics.LegacyUnits {
+			modules[l[0]] = map[string]string{
+				"size":     l[1],
+				"refcount": l[2],
 			}
+			continue
+		}
+		size, err := strconv.ParseUint(l[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("kernel: invalid size of module %s: %q", l[0], l[1])
+		}
+		refcount, err := strconv.Atoi(l[2])
+		if err != nil {
+			return nil, fmt.Errorf("kernel: invalid refcount of module %s: %q", l[0], l[2])
+		}
+		modules[l[0]] = map[string]interface{}{
+			"size":     size,
+			"refcount": refcount,
 		}
-
 	}
 	return d, nil
 }
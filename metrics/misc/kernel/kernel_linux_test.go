@@ -0,0 +1,83 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package kernel
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Data
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			want: Data{
+				"name":    "Linux",
+				"release": "3.13.0-24-generic",
+				"version": "#46-Ubuntu SMP Thu Apr 10 19:11:08 UTC 2014",
+				"machine": "x86_64",
+				"os":      "GNU/Linux",
+				"modules": map[string]interface{}{
+					"nls_utf8": map[string]interface{}{"size": uint64(12557), "refcount": 1},
+					"isofs":    map[string]interface{}{"size": uint64(39837), "refcount": 1},
+					"rfcomm":   map[string]interface{}{"size": uint64(69160), "refcount": 0},
+					"bnep":     map[string]interface{}{"size": uint64(19624), "refcount": 2},
+					"i915":     map[string]interface{}{"size": uint64(783485), "refcount": 4},
+				},
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			want: Data{
+				"name":    "Linux",
+				"release": "6.2.0-37-generic",
+				"version": "#38~22.04.1-Ubuntu SMP PREEMPT_DYNAMIC Thu Nov  2 18:01:13 UTC 2",
+				"machine": "x86_64",
+				"os":      "GNU/Linux",
+				"modules": map[string]interface{}{
+					"tls":       map[string]interface{}{"size": uint64(139264), "refcount": 0},
+					"nvme":      map[string]interface{}{"size": uint64(57344), "refcount": 3},
+					"nvme_core": map[string]interface{}{"size": uint64(200704), "refcount": 4},
+				},
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			want: Data{
+				"name":    "Linux",
+				"release": "6.1.62-0-virt",
+				"version": "#1-Alpine SMP PREEMPT_DYNAMIC Thu, 09 Nov 2023 10:13:58 +0000",
+				"machine": "x86_64",
+				"os":      "Linux",
+				"modules": map[string]interface{}{
+					"virtio_net":   map[string]interface{}{"size": uint64(69632), "refcount": 0},
+					"net_failover": map[string]interface{}{"size": uint64(24576), "refcount": 1},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, tt.want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.fixture, d, tt.want)
+		}
+	}
+}
@@ -7,38 +7,46 @@ package languages
 
 import (
 	"fmt"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
-	"github.com/codeignition/recon/internal/fileutil"
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("languages", "installed language runtimes", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 // Data represents the languages data.
 type Data map[string]interface{}
 
 // CollectData collects the data and returns an error if any.
-func CollectData() (Data, error) {
+func CollectData(ctx context.Context) (Data, error) {
 	d := make(Data)
-	goData(d)
-	perlData(d)
-	pythonData(d)
-	rubyData(d)
+	goData(ctx, d)
+	perlData(ctx, d)
+	pythonData(ctx, d)
+	rubyData(ctx, d)
 	// TODO: c ?
 	return d, nil
 }
 
-func goData(d map[string]interface{}) {
-	if out, err := exec.Command("go", "version").Output(); err == nil {
-		lines := strings.Split(string(out), " ")
-		d["go"] = make(map[string]string)
-		m := d["go"].(map[string]string)
-		m["version"] = lines[2][2:]
+func goData(ctx context.Context, d map[string]interface{}) {
+	// go version go1.4.2 linux/amd64
+	if out, err := host.Command(ctx, "go", "version"); err == nil {
+		f := strings.Fields(string(out))
+		if len(f) > 2 && strings.HasPrefix(f[2], "go") {
+			d["go"] = map[string]string{"version": f[2][2:]}
+		}
 	}
 }
 
-func perlData(d map[string]interface{}) {
-	if out, err := exec.Command("perl", "-V:version", "-V:archname").Output(); err == nil {
+func perlData(ctx context.Context, d map[string]interface{}) {
+	if out, err := host.Command(ctx, "perl", "-V:version", "-V:archname"); err == nil {
 		lines := strings.Split(string(out), "\n")
 		d["perl"] = make(map[string]string)
 		m := d["perl"].(map[string]string)
@@ -57,8 +65,13 @@ func perlData(d map[string]interface{}) {
 	}
 }
 
-func pythonData(d map[string]interface{}) {
-	if out, err := exec.Command("python", "-c", "import sys; print(sys.version)").Output(); err == nil {
+func pythonData(ctx context.Context, d map[string]interface{}) {
+	out, err := host.Command(ctx, "python", "-c", "import sys; print(sys.version)")
+	if err != nil {
+		// Recent distributions only ship python3.
+		out, err = host.Command(ctx, "python3", "-c", "import sys; print(sys.version)")
+	}
+	if err == nil {
 		// only the first line is required.
 		line := strings.Split(string(out), "\n")[0] // length check necessary?
 		d["python"] = make(map[string]string)
@@ -66,12 +79,21 @@ func pythonData(d map[string]interface{}) {
 		l := strings.SplitN(line, " ", 2)
 		if len(l) == 2 {
 			m["version"] = l[0]
-			m["builddate"] = strings.Trim(l[1], "(default, ) ")
+			// 2.7.6 (default, Mar 22 2014, 22:59:56)
+			// 3.10.12 (main, Nov 20 2023, 15:14:05) [GCC 11.4.0]
+			b := l[1]
+			if i := strings.Index(b, ")"); i >= 0 {
+				b = b[:i]
+			}
+			if i := strings.Index(b, ", "); i >= 0 {
+				b = b[i+2:]
+			}
+			m["builddate"] = strings.Trim(b, "( ")
 		}
 	}
 }
 
-func rubyData(d map[string]interface{}) {
+func rubyData(ctx context.Context, d map[string]interface{}) {
 	rvars := map[string]string{
 		"platform":      "RUBY_PLATFORM",
 		"version":       "RUBY_VERSION",
@@ -87,27 +109,31 @@ func rubyData(d map[string]interface{}) {
 		"bin_dir":       "RbConfig::CONFIG['bindir']",
 		"ruby_bin":      "::File.join(RbConfig::CONFIG['bindir'], RbConfig::CONFIG['ruby_install_name'])",
 	}
-	d["ruby"] = make(map[string]string)
-	m := d["ruby"].(map[string]string)
+	m := make(map[string]string)
 	for k, v := range rvars {
 		t := fmt.Sprintf(`require "rbconfig"; puts %s`, v)
-		if out, err := exec.Command("ruby", "-e", t).Output(); err == nil {
+		if out, err := host.Command(ctx, "ruby", "-e", t); err == nil {
 			m[k] = strings.TrimSpace(string(out))
 		}
 	}
-	if out, err := exec.Command("ruby", "-e", `require "rubygems"; puts Gem::default_exec_format % "gem"`).Output(); err == nil {
+	if len(m) == 0 {
+		// ruby isn't installed.
+		return
+	}
+	d["ruby"] = m
+	if out, err := host.Command(ctx, "ruby", "-e", `require "rubygems"; puts Gem::default_exec_format % "gem"`); err == nil {
 		g := strings.TrimSpace(string(out))
 		var gemBin string
-		if p := filepath.Join(m["bin_dir"], g); fileutil.Exists(p) {
+		if p := filepath.Join(m["bin_dir"], g); host.Exists(p) {
 			gemBin = p
-		} else if p := filepath.Join(m["bin_dir"], "gem"); fileutil.Exists(p) {
+		} else if p := filepath.Join(m["bin_dir"], "gem"); host.Exists(p) {
 			gemBin = p
 		}
 		if gemBin != "" {
 			m["gem_bin"] = gemBin
 
 			// TODO: A bit of a doubt. Check gems_dir once.
-			if out, err := exec.Command(m["ruby_bin"], gemBin, "env", "gemdir").Output(); err == nil {
+			if out, err := host.Command(ctx, m["ruby_bin"], gemBin, "env", "gemdir"); err == nil {
 				m["gems_dir"] = strings.TrimSpace(string(out))
 			}
 		}
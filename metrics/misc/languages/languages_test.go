@@ -0,0 +1,75 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package languages
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Data
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			want: Data{
+				"go": map[string]string{"version": "1.4.2"},
+				"perl": map[string]string{
+					"version":  "5.18.2",
+					"archname": "x86_64-linux-gnu-thread-multi",
+				},
+				"python": map[string]string{
+					"version":   "2.7.6",
+					"builddate": "Mar 22 2014, 22:59:56",
+				},
+				"ruby": map[string]string{
+					"version":  "1.9.3",
+					"bin_dir":  "/usr/bin",
+					"ruby_bin": "/usr/bin/ruby1.9.1",
+					"gem_bin":  "/usr/bin/gem",
+					"gems_dir": "/var/lib/gems/1.9.1",
+				},
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			want: Data{
+				"perl": map[string]string{
+					"version":  "5.34.0",
+					"archname": "x86_64-linux-gnu-thread-multi",
+				},
+				"python": map[string]string{
+					"version":   "3.10.12",
+					"builddate": "Nov 20 2023, 15:14:05",
+				},
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			want:    Data{},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, tt.want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.fixture, d, tt.want)
+		}
+	}
+}
@@ -9,21 +9,27 @@ package lsb
 import (
 	"bufio"
 	"errors"
-	"os"
-	"os/exec"
 	"strings"
 
-	"github.com/codeignition/recon/internal/fileutil"
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("lsb", "distribution identification from Linux Standard Base", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 // Data represents the lsb data.
 type Data map[string]string
 
 // CollectData collects the data and returns an error if any.
-func CollectData() (Data, error) {
+func CollectData(ctx context.Context) (Data, error) {
 	d := make(Data)
-	if fileutil.Exists("/etc/lsb-release") {
-		f, err := os.Open("/etc/lsb-release")
+	if host.Exists("/etc/lsb-release") {
+		f, err := host.Open("/etc/lsb-release")
 		if err != nil {
 			return d, err
 		}
@@ -51,8 +57,8 @@ func CollectData() (Data, error) {
 		return d, nil
 	}
 
-	if fileutil.Exists("/usr/bin/lsb_release") {
-		out, err := exec.Command("/usr/bin/lsb_release", "-a").Output()
+	if host.Exists("/usr/bin/lsb_release") {
+		out, err := host.Command(ctx, "/usr/bin/lsb_release", "-a")
 		if err != nil {
 			return d, err
 		}
@@ -76,5 +82,36 @@ func CollectData() (Data, error) {
 		return d, nil
 	}
 
-	return nil, errors.New("cannot find /etc/lsb-release or /usr/bin/lsb_release")
+	// Distributions like Alpine have neither, but
+	// do have the systemd /etc/os-release file.
+	if host.Exists("/etc/os-release") {
+		f, err := host.Open("/etc/os-release")
+		if err != nil {
+			return d, err
+		}
+		defer f.Close()
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			l := strings.SplitN(s.Text(), "=", 2)
+			if len(l) == 2 {
+				k, v := l[0], strings.Trim(l[1], `"'`)
+				switch k {
+				case "ID":
+					d["id"] = v
+				case "VERSION_ID":
+					d["release"] = v
+				case "VERSION_CODENAME":
+					d["codename"] = v
+				case "PRETTY_NAME":
+					d["description"] = v
+				}
+			}
+		}
+		if err := s.Err(); err != nil {
+			return d, err
+		}
+		return d, nil
+	}
+
+	return nil, errors.New("cannot find /etc/lsb-release, /usr/bin/lsb_release or /etc/os-release")
 }
@@ -0,0 +1,73 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package lsb
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Data
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			want: Data{
+				"id":          "Ubuntu",
+				"release":     "14.04",
+				"codename":    "trusty",
+				"description": "Ubuntu 14.04.2 LTS",
+			},
+		},
+		{
+			// /etc/lsb-release takes precedence over /etc/os-release
+			fixture: "ubuntu-22.04",
+			want: Data{
+				"id":          "Ubuntu",
+				"release":     "22.04",
+				"codename":    "jammy",
+				"description": "Ubuntu 22.04.3 LTS",
+			},
+		},
+		{
+			fixture: "debian-8",
+			want: Data{
+				"id":          "Debian",
+				"release":     "8.11",
+				"codename":    "jessie",
+				"description": "Debian GNU/Linux 8.11 (jessie)",
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			want: Data{
+				"id":          "alpine",
+				"release":     "3.18.4",
+				"description": "Alpine Linux v3.18",
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, tt.want) {
+			t.Errorf("%s: got %v; want %v", tt.fixture, d, tt.want)
+		}
+	}
+}
@@ -7,86 +7,93 @@ package memory
 
 import (
 	"bufio"
-	"os"
+	"fmt"
 	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("memory", "memory and swap usage from /proc/meminfo", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 // Data represents the memory data.
+// The values are in bytes.
 type Data map[string]interface{}
 
-// CollectData collects the data and returns an error if any.
-func CollectData() (Data, error) {
+// keys maps the fields of /proc/meminfo to the keys of the data.
+var keys = map[string]string{
+	"MemTotal":     "total",
+	"MemFree":      "free",
+	"MemAvailable": "available",
+	"Buffers":      "buffers",
+	"Cached":       "cached",
+	"Active":       "active",
+	"Inactive":     "inactive",
+	"Dirty":        "dirty",
+	"Writeback":    "writeback",
+	"AnonPages":    "anon_pages",
+	"Mapped":       "mapped",
+	"Slab":         "slab",
+	"SReclaimable": "slab_reclaimable",
+	"SUnreclaim":   "slab_unreclaim",
+	"PageTables":   "page_tables",
+	"NFS_Unstable": "nfs_unstable",
+	"Bounce":       "bounce",
+	"CommitLimit":  "commit_limit",
+	"Committed_AS": "committed_as",
+	"VmallocTotal": "vmalloc_total",
+	"VmallocUsed":  "vmalloc_used",
+	"VmallocChunk": "vmalloc_chunk",
+}
 
-	// allocate memory
-	d := make(Data)
-	d["swap"] = make(map[string]string)
+// swapKeys maps the swap fields of /proc/meminfo
+// to the keys of the swap data.
+var swapKeys = map[string]string{
+	"SwapCached": "cached",
+	"SwapTotal":  "total",
+	"SwapFree":   "free",
+}
 
-	// cast interface{} to a map
-	swap := d["swap"].(map[string]string)
+// CollectData collects the data and returns an error if any.
+func CollectData(ctx context.Context) (Data, error) {
+	d := make(Data)
+	swap := make(map[string]interface{})
+	d["swap"] = swap
 
-	f, err := os.Open("/proc/meminfo")
+	f, err := host.Open("/proc/meminfo")
 	if err != nil {
 		return d, err
 	}
 	defer f.Close()
 	s := bufio.NewScanner(f)
 	for s.Scan() {
+		// MemTotal:        8048832 kB
 		l := strings.Split(s.Text(), ":")
-		if len(l) == 2 {
-			k, v := l[0], strings.TrimSpace(l[1])
-			switch k {
-			case "MemTotal":
-				d["total"] = v
-			case "MemFree":
-				d["free"] = v
-			case "MemAvailable":
-				d["available"] = v
-			case "Buffers":
-				d["buffers"] = v
-			case "Cached":
-				d["cached"] = v
-			case "SwapCached":
-				swap["cached"] = v
-			case "Active":
-				d["active"] = v
-			case "Inactive":
-				d["inactive"] = v
-			case "SwapTotal":
-				swap["total"] = v
-			case "SwapFree":
-				swap["free"] = v
-			case "Dirty":
-				d["dirty"] = v
-			case "Writeback":
-				d["writeback"] = v
-			case "AnonPages":
-				d["anon_pages"] = v
-			case "Mapped":
-				d["mapped"] = v
-			case "Slab":
-				d["slab"] = v
-			case "SReclaimable":
-				d["slab_reclaimable"] = v
-			case "SUnreclaim":
-				d["slab_unreclaim"] = v
-			case "PageTables":
-				d["page_tables"] = v
-			case "NFS_Unstable":
-				d["nfs_unstable"] = v
-			case "Bounce":
-				d["bounce"] = v
-			case "CommitLimit":
-				d["commit_limit"] = v
-			case "Committed_AS":
-				d["committed_as"] = v
-			case "VmallocTotal":
-				d["vmalloc_total"] = v
-			case "VmallocUsed":
-				d["vmalloc_used"] = v
-			case "VmallocChunk":
-				d["vmalloc_chunk"] = v
-			}
+		if len(l) != 2 {
+			continue
+		}
+		m, k := map[string]interface{}(d), keys[l[0]]
+		if sk, ok := swapKeys[l[0]]; ok {
+			m, k = swap, sk
+		}
+		if k == "" {
+			continue
+		}
+		v := strings.TrimSpace(l[1])
+		if metrics.LegacyUnits {
+			m[k] = v
+			continue
+		}
+		n, err := metrics.ParseBytes(v)
+		if err != nil {
+			return d, fmt.Errorf("memory: %s: %v", l[0], err)
 		}
+		m[k] = n
 	}
 	if err := s.Err(); err != nil {
 		return d, err
@@ -0,0 +1,104 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    map[string]interface{} // subset of the data
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			want: map[string]interface{}{
+				"total":        uint64(8242003968),
+				"free":         uint64(324825088),
+				"buffers":      uint64(363880448),
+				"cached":       uint64(4562165760),
+				"commit_limit": uint64(12583006208),
+				"swap": map[string]interface{}{
+					"total":  uint64(8462004224),
+					"free":   uint64(8335585280),
+					"cached": uint64(12333056),
+				},
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			want: map[string]interface{}{
+				"total":     uint64(16694378496),
+				"free":      uint64(1294467072),
+				"available": uint64(10355736576),
+				"dirty":     uint64(1048576),
+				"swap": map[string]interface{}{
+					"total":  uint64(2147479552),
+					"free":   uint64(2147479552),
+					"cached": uint64(0),
+				},
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			want: map[string]interface{}{
+				"total":     uint64(2085498880),
+				"free":      uint64(419553280),
+				"available": uint64(1557344256),
+				"swap": map[string]interface{}{
+					"total":  uint64(0),
+					"free":   uint64(0),
+					"cached": uint64(0),
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		for k, want := range tt.want {
+			if got := d[k]; !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: %s: got %v; want %v", tt.fixture, k, got, want)
+			}
+		}
+	}
+}
+
+func TestCollectDataLegacyUnits(t *testing.T) {
+	restore, err := host.Fixture(filepath.Join("testdata", "ubuntu-14.04"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+	metrics.LegacyUnits = true
+	defer func() { metrics.LegacyUnits = false }()
+
+	d, err := CollectData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d["total"], "8048832 kB"; got != want {
+		t.Errorf("got total %v; want %v", got, want)
+	}
+	swap := d["swap"].(map[string]interface{})
+	if got, want := swap["free"], "8140220 kB"; got != want {
+		t.Errorf("got swap free %v; want %v", got, want)
+	}
+}
@@ -14,18 +14,28 @@
 package netstat
 
 import (
-	"os/exec"
+	"strconv"
 	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("netstat", "network connections", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 // Data represents the network statistics data.
-type Data []map[string]string
+type Data []map[string]interface{}
 
 // CollectData collects the data and returns
 // an error if any.
-func CollectData() (Data, error) {
+func CollectData(ctx context.Context) (Data, error) {
 	var d Data
-	out, err := exec.Command("netstat", "-anp").Output()
+	out, err := host.Command(ctx, "netstat", "-anp")
 	if err != nil {
 		return d, err
 	}
@@ -75,37 +85,29 @@ func internetConn(d *Data, line string) {
 
 	a := strings.Fields(line)
 
-	var (
-		state, pid, progname string
-	)
 	// return the length is less than 6,
 	// to avoid a runtime panic when we access indices > 5
 	if len(a) < 6 {
 		return
 	}
 
-	// when state is empty
-	if len(a) == 6 {
-		state = ""
-		// we ignore the case when a[5] is - , as pid and progname are
-		// empty strings (zero values during declaration) already.
-		if strings.Contains(a[5], "/") {
-			b := strings.SplitN(a[5], "/", 2)
-			pid = b[0]
-			progname = b[1]
-		}
-	}
-
-	if len(a) == 7 {
+	// The state is empty for udp and raw sockets, so the
+	// PID/Program name column, which is either "-" or contains
+	// a "/", may come right after the foreign address.
+	// The program name itself may contain spaces, e.g. "sshd: /usr/sbin".
+	var state, pid, progname string
+	prog := a[5:]
+	if a[5] != "-" && !strings.Contains(a[5], "/") {
 		state = a[5]
-		if strings.Contains(a[6], "/") {
-			b := strings.SplitN(a[6], "/", 2)
-			pid = b[0]
-			progname = b[1]
-		}
+		prog = a[6:]
+	}
+	if len(prog) > 0 && strings.Contains(prog[0], "/") {
+		b := strings.SplitN(strings.Join(prog, " "), "/", 2)
+		pid = b[0]
+		progname = b[1]
 	}
 
-	m := map[string]string{
+	m := map[string]interface{}{
 		"protocol":        a[0],
 		"local_address":   a[3],
 		"foreign_address": a[4],
@@ -113,5 +115,12 @@ func internetConn(d *Data, line string) {
 		"process_id":      pid,
 		"program_name":    progname,
 	}
+	if !metrics.LegacyUnits {
+		// The process is unknown without enough privileges.
+		delete(m, "process_id")
+		if n, err := strconv.Atoi(pid); err == nil {
+			m["process_id"] = n
+		}
+	}
 	*d = append(*d, m)
 }
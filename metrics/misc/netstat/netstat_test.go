@@ -0,0 +1,64 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package netstat
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Data
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			want: Data{
+				map[string]interface{}{"protocol": "tcp", "local_address": "127.0.1.1:53", "foreign_address": "0.0.0.0:*", "state": "LISTEN", "program_name": ""},
+				map[string]interface{}{"protocol": "tcp", "local_address": "192.168.1.119:43210", "foreign_address": "74.125.200.188:5228", "state": "ESTABLISHED", "process_id": 2112, "program_name": "chrome"},
+				map[string]interface{}{"protocol": "udp", "local_address": "0.0.0.0:631", "foreign_address": "0.0.0.0:*", "state": "", "program_name": ""},
+				map[string]interface{}{"protocol": "udp", "local_address": "0.0.0.0:5353", "foreign_address": "0.0.0.0:*", "state": "", "process_id": 2251, "program_name": "chrome"},
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			want: Data{
+				map[string]interface{}{"protocol": "tcp", "local_address": "127.0.0.53:53", "foreign_address": "0.0.0.0:*", "state": "LISTEN", "process_id": 612, "program_name": "systemd-resolve"},
+				map[string]interface{}{"protocol": "tcp", "local_address": "0.0.0.0:22", "foreign_address": "0.0.0.0:*", "state": "LISTEN", "process_id": 901, "program_name": "sshd: /usr/sbin"},
+				map[string]interface{}{"protocol": "tcp6", "local_address": ":::22", "foreign_address": ":::*", "state": "LISTEN", "process_id": 901, "program_name": "sshd: /usr/sbin"},
+				map[string]interface{}{"protocol": "udp", "local_address": "127.0.0.53:53", "foreign_address": "0.0.0.0:*", "state": "", "process_id": 612, "program_name": "systemd-resolve"},
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			want: Data{
+				map[string]interface{}{"protocol": "tcp", "local_address": "0.0.0.0:80", "foreign_address": "0.0.0.0:*", "state": "LISTEN", "process_id": 597, "program_name": "nginx.conf"},
+				map[string]interface{}{"protocol": "tcp", "local_address": "172.17.0.2:80", "foreign_address": "172.17.0.1:52344", "state": "TIME_WAIT", "program_name": ""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, tt.want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.fixture, d, tt.want)
+		}
+	}
+}
@@ -9,13 +9,20 @@ package network
 
 import (
 	"net"
-	"os/exec"
 	"strconv"
 	"strings"
 
-	"github.com/codeignition/recon/internal/fileutil"
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("network", "network interfaces, addresses, routes and neighbours", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 // Network addresses
 var (
 	IPV4Addr string
@@ -27,11 +34,11 @@ var (
 type Data map[string]interface{}
 
 // CollectData collects the data and returns an error if any.
-func CollectData() (Data, error) {
+func CollectData(ctx context.Context) (Data, error) {
 	d := make(Data)
 	d["interfaces"] = make(map[string]interface{})
 	ifaces := d["interfaces"].(map[string]interface{})
-	out, err := exec.Command("ip", "addr").Output()
+	out, err := host.Command(ctx, "ip", "addr")
 	if err != nil {
 		return d, err
 	}
@@ -46,14 +53,17 @@ func CollectData() (Data, error) {
 		if strings.ContainsAny(line, "<>") {
 			// 1: lo: <LOOPBACK,UP,LOWER_UP> mtu 65536 qdisc noqueue state UNKNOWN group default
 			a := strings.Fields(line)
+			if len(a) < 3 {
+				continue
+			}
 			name = strings.Trim(a[1], " :")
 			ifaces[name] = make(map[string]interface{})
 			iface = ifaces[name].(map[string]interface{})
 			flags := strings.Split(strings.Trim(a[2], "<> "), ",")
 			iface["flags"] = flags
-			for i := range a {
+			for i := 0; i < len(a)-1; i++ {
 				if a[i] == "mtu" {
-					iface["mtu"] = a[i+1]
+					iface["mtu"] = number(a[i+1])
 				}
 
 				if a[i] == "state" {
@@ -72,7 +82,7 @@ func CollectData() (Data, error) {
 				iface["encapsulation"] = strings.TrimPrefix(a[0], "link/")
 				if len(a) >= 4 {
 					if a[1] != "00:00:00:00:00:00" {
-						addrs[a[1]] = map[string]string{
+						addrs[a[1]] = map[string]interface{}{
 							"family": "lladdr",
 						}
 					}
@@ -90,21 +100,21 @@ func CollectData() (Data, error) {
 					}
 					ones, _ := ipnet.Mask.Size() // ignore the number of bits
 					var tempScope string
-					for i := range a {
+					for i := 0; i < len(a)-1; i++ {
 						if a[i] == "scope" {
 							tempScope = scope(a[i+1])
 						}
 					}
-					addrs[ip.String()] = make(map[string]string)
-					t := addrs[ip.String()].(map[string]string)
+					t := make(map[string]interface{})
+					addrs[ip.String()] = t
 					t["family"] = "inet"
-					t["prefixlen"] = strconv.Itoa(ones)
+					t["prefixlen"] = number(strconv.Itoa(ones))
 					t["scope"] = tempScope
 
 					// by converting into IP type, we get the string in the form a.b.c.d
 					t["netmask"] = net.IP(ipnet.Mask).String()
 
-					for i := range a {
+					for i := 0; i < len(a)-1; i++ {
 						if a[i] == "brd" {
 							t["broadcast"] = a[i+1]
 						}
@@ -125,9 +135,9 @@ func CollectData() (Data, error) {
 						return d, err
 					}
 					ones, _ := ipnet.Mask.Size() // ignore the number of bits
-					addrs[ip.String()] = map[string]string{
+					addrs[ip.String()] = map[string]interface{}{
 						"family":    "inet6",
-						"prefixlen": strconv.Itoa(ones),
+						"prefixlen": number(strconv.Itoa(ones)),
 						"scope":     scope(a[3]),
 					}
 				}
@@ -135,21 +145,22 @@ func CollectData() (Data, error) {
 		}
 	}
 
-	if err := defaultGateway(d); err != nil {
+	if err := defaultGateway(ctx, d); err != nil {
 		return d, err
 	}
 
-	if err := routes(d); err != nil {
+	fams := families()
+	if err := routes(ctx, d, fams); err != nil {
 		return d, err
 	}
 
-	if err := neighbours(d); err != nil {
+	if err := neighbours(ctx, d, fams); err != nil {
 		return d, err
 	}
 
 	// arp data is added in the neighbours function itself.
 	// If that fails, this one adds the arp data.
-	if err := arp(d); err != nil {
+	if err := arp(ctx, d); err != nil {
 		return d, err
 	}
 
@@ -159,6 +170,19 @@ func CollectData() (Data, error) {
 	return d, nil
 }
 
+// number returns s as an int, unless LegacyUnits is set
+// or s isn't a number.
+func number(s string) interface{} {
+	if metrics.LegacyUnits {
+		return s
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return s
+	}
+	return n
+}
+
 func scope(s string) string {
 	if s == "host" {
 		return "Node"
@@ -168,32 +192,38 @@ func scope(s string) string {
 
 // defaultGateway adds the default gateway and default interface
 // data to the given map.
-func defaultGateway(d Data) error {
-	out, err := exec.Command("route", "-n").Output()
+func defaultGateway(ctx context.Context, d Data) error {
+	out, err := host.Command(ctx, "route", "-n")
 	if err != nil {
 		return err
 	}
-	s := strings.Split(string(out), "\n")
-	// s[0] is the title, s[1] is the column headings. Also, we only
-	// consider s[2] for the default interface and gateway.
-	a := strings.Fields(s[2])
-	d["default_gateway"] = a[1]
-	d["default_interface"] = a[7]
-
-	out, err = exec.Command("route", "-6", "-n").Output()
+	// Kernel IP routing table
+	// Destination     Gateway         Genmask         Flags Metric Ref    Use Iface
+	// 0.0.0.0         192.168.1.1     0.0.0.0         UG    0      0        0 wlan0
+	for _, line := range strings.Split(string(out), "\n") {
+		a := strings.Fields(line)
+		if len(a) >= 8 && a[0] == "0.0.0.0" {
+			d["default_gateway"] = a[1]
+			d["default_interface"] = a[7]
+			break
+		}
+	}
+
+	// Not every route supports IPv6, e.g. the one in busybox,
+	// so the IPv6 default gateway is left out if this fails.
+	out, err = host.Command(ctx, "route", "-6", "-n")
 	if err != nil {
-		return err
+		return nil
 	}
-	lines := strings.Split(string(out), "\n")
-	// lines[0] is the title, lines[1] is the column headings.
-	for _, line := range lines[2:] {
+	// Kernel IPv6 routing table
+	// Destination                    Next Hop                   Flag Met Ref Use If
+	// ::/0                           fe80::1                    UGDAe 1024 0     0 wlan0
+	for _, line := range strings.Split(string(out), "\n") {
 		a := strings.Fields(line)
-		if len(a) >= 7 {
-			if a[1] != "::" {
-				d["default_inet6_gateway"] = a[1]
-				d["default_inet6_interface"] = a[6]
-				break
-			}
+		if len(a) >= 7 && a[0] == "::/0" && a[1] != "::" {
+			d["default_inet6_gateway"] = a[1]
+			d["default_inet6_interface"] = a[6]
+			break
 		}
 	}
 	return nil
@@ -202,12 +232,16 @@ func defaultGateway(d Data) error {
 // populateAddrs populates the address variables
 // exported by the package.
 func populateAddrs(d Data) {
-	iface := d["default_interface"].(string)
-	ifaces := d["interfaces"].(map[string]interface{})
-	ifaceMap := ifaces[iface].(map[string]interface{})
-	addresses := ifaceMap["addresses"].(map[string]interface{})
+	iface, _ := d["default_interface"].(string)
+	ifaces, _ := d["interfaces"].(map[string]interface{})
+	ifaceMap, ok := ifaces[iface].(map[string]interface{})
+	if !ok {
+		// No default route, e.g. in an isolated container.
+		return
+	}
+	addresses, _ := ifaceMap["addresses"].(map[string]interface{})
 	for k, val := range addresses {
-		v := val.(map[string]string)
+		v, _ := val.(map[string]interface{})
 		if v["family"] == "inet" {
 			IPV4Addr = k
 		}
@@ -221,78 +255,79 @@ func populateAddrs(d Data) {
 }
 
 // arp adds the arp data to the given map.
-func arp(d Data) error {
-	out, err := exec.Command("arp", "-an").Output()
+func arp(ctx context.Context, d Data) error {
+	if _, ok := d["arp"]; ok {
+		return nil
+	}
+	out, err := host.Command(ctx, "arp", "-an")
 	if err != nil {
 		return err
 	}
-	s := strings.Split(string(out), "\n")
-	for i := range s {
-		line := s[i]
+	m := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
 		// ? (192.164.1.1) at 48:f8:c3:46:03:44 [ether] on wlan
 		a := strings.Fields(line)
 		if len(a) >= 4 {
-			d["arp"] = map[string]string{
-				strings.Trim(a[1], "()"): a[3],
-			}
+			m[strings.Trim(a[1], "()")] = a[3]
 		}
 	}
+	d["arp"] = m
 	return nil
 }
 
 // ipv6Enabled returns true if IPv6 is enabled
 // on the machine.
 func ipv6Enabled() bool {
-	return fileutil.Exists("/proc/net/if_inet6")
-}
-
-// families to get default routes from.
-var families = []map[string]string{
-	{
-		"name":               "inet",
-		"defaultRoute":       "0.0.0.0/0",
-		"defaultPrefix":      "default",
-		"neighbourAttribute": "arp",
-	},
+	return host.Exists("/proc/net/if_inet6")
 }
 
-func init() {
+// families returns the families to get default routes from.
+func families() []map[string]string {
+	f := []map[string]string{
+		{
+			"name":               "inet",
+			"defaultRoute":       "0.0.0.0/0",
+			"defaultPrefix":      "default",
+			"neighbourAttribute": "arp",
+		},
+	}
 	if ipv6Enabled() {
-		families = append(families, map[string]string{
+		f = append(f, map[string]string{
 			"name":               "inet6",
 			"defaultRoute":       "::/0",
 			"defaultPrefix":      "default_inet6",
 			"neighbourAttribute": "neighbour_inet6",
 		})
 	}
+	return f
 }
 
 // neighbours adds the neighbours data of families to the given map.
-func neighbours(d Data) error {
+func neighbours(ctx context.Context, d Data, families []map[string]string) error {
 	for _, family := range families {
-		out, err := exec.Command("ip", "-f", family["name"], "neigh", "show").Output()
+		out, err := host.Command(ctx, "ip", "-f", family["name"], "neigh", "show")
 		if err != nil {
 			return err
 		}
+		m := make(map[string]string)
 		lines := strings.Split(string(out), "\n")
 		for _, line := range lines {
 			a := strings.Fields(line)
 			// 192.167.1.1 dev wlan0 lladdr 48:f8:b3:36:03:44 REACHABLE
 			// fe80::4af7:b3ff:fe36:344 dev wlan0 lladdr 48:f8:b3:36:06:44 router STALE
-			if len(a) >= 5 {
-				d[family["neighbourAttribute"]] = map[string]string{
-					a[0]: a[4],
-				}
+			if len(a) >= 5 && a[3] == "lladdr" {
+				m[a[0]] = a[4]
 			}
 		}
+		d[family["neighbourAttribute"]] = m
 	}
 	return nil
 }
 
-func routes(d Data) error {
-	var routes []map[string]string
+func routes(ctx context.Context, d Data, families []map[string]string) error {
+	var routes []map[string]interface{}
 	for _, family := range families {
-		out, err := exec.Command("ip", "-o", "-f", family["name"], "route", "show").Output()
+		out, err := host.Command(ctx, "ip", "-o", "-f", family["name"], "route", "show")
 		if err != nil {
 			return err
 		}
@@ -302,11 +337,11 @@ func routes(d Data) error {
 			// fd01:37b7:3570::/64 dev wlan0  proto kernel  metric 256  expires 6837sec mtu 1280
 			a := strings.Fields(line)
 			if len(a) >= 1 {
-				m := map[string]string{
+				m := map[string]interface{}{
 					"destination": a[0],
 					"family":      family["name"],
 				}
-				for i := range a {
+				for i := 0; i < len(a)-1; i++ {
 					switch a[i] {
 					case "via":
 						m["via"] = a[i+1]
@@ -315,7 +350,7 @@ func routes(d Data) error {
 					case "proto":
 						m["proto"] = a[i+1]
 					case "metric":
-						m["metric"] = a[i+1]
+						m["metric"] = number(a[i+1])
 					case "scope":
 						m["scope"] = a[i+1]
 
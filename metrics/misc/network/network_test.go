@@ -0,0 +1,152 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package network
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture            string
+		want               Data
+		ipv4, ipv6, macAdr string
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			want: Data{
+				"interfaces": map[string]interface{}{
+					"lo": map[string]interface{}{
+						"flags":         []string{"LOOPBACK", "UP", "LOWER_UP"},
+						"mtu":           65536,
+						"state":         "unknown",
+						"encapsulation": "loopback",
+						"addresses": map[string]interface{}{
+							"127.0.0.1": map[string]interface{}{"family": "inet", "prefixlen": 8, "scope": "Node", "netmask": "255.0.0.0"},
+							"::1":       map[string]interface{}{"family": "inet6", "prefixlen": 128, "scope": "Node"},
+						},
+					},
+					"wlan0": map[string]interface{}{
+						"flags":         []string{"BROADCAST", "MULTICAST", "UP", "LOWER_UP"},
+						"mtu":           1500,
+						"state":         "up",
+						"encapsulation": "ether",
+						"addresses": map[string]interface{}{
+							"4c:eb:42:0f:8e:99":         map[string]interface{}{"family": "lladdr"},
+							"192.168.1.119":             map[string]interface{}{"family": "inet", "prefixlen": 24, "scope": "Global", "netmask": "255.255.255.0", "broadcast": "192.168.1.255"},
+							"fe80::4eeb:42ff:fe0f:8e99": map[string]interface{}{"family": "inet6", "prefixlen": 64, "scope": "Link"},
+						},
+					},
+				},
+				"default_gateway":         "192.168.1.1",
+				"default_interface":       "wlan0",
+				"default_inet6_gateway":   "fe80::4af8:b3ff:fe36:344",
+				"default_inet6_interface": "wlan0",
+				"routes": []map[string]interface{}{
+					{"destination": "default", "family": "inet", "via": "192.168.1.1", "proto": "static"},
+					{"destination": "192.168.1.0/24", "family": "inet", "proto": "kernel", "scope": "link", "src": "192.168.1.119", "metric": 9},
+					{"destination": "fe80::/64", "family": "inet6", "proto": "kernel", "metric": 256},
+					{"destination": "default", "family": "inet6", "via": "fe80::4af8:b3ff:fe36:344", "proto": "ra", "metric": 1024},
+				},
+				"arp": map[string]string{
+					"192.168.1.1": "48:f8:b3:36:03:44",
+					"192.168.1.7": "10:bf:48:d1:2c:6a",
+				},
+				"neighbour_inet6": map[string]string{
+					"fe80::4af8:b3ff:fe36:344": "48:f8:b3:36:03:44",
+				},
+			},
+			ipv4:   "192.168.1.119",
+			ipv6:   "fe80::4eeb:42ff:fe0f:8e99",
+			macAdr: "4c:eb:42:0f:8e:99",
+		},
+		{
+			fixture: "ubuntu-22.04",
+			want: Data{
+				"interfaces": map[string]interface{}{
+					"lo": map[string]interface{}{
+						"flags":         []string{"LOOPBACK", "UP", "LOWER_UP"},
+						"mtu":           65536,
+						"state":         "unknown",
+						"encapsulation": "loopback",
+						"addresses": map[string]interface{}{
+							"127.0.0.1": map[string]interface{}{"family": "inet", "prefixlen": 8, "scope": "Node", "netmask": "255.0.0.0"},
+							"::1":       map[string]interface{}{"family": "inet6", "prefixlen": 128, "scope": "Node"},
+						},
+					},
+					"ens5": map[string]interface{}{
+						"flags":         []string{"BROADCAST", "MULTICAST", "UP", "LOWER_UP"},
+						"mtu":           9001,
+						"state":         "up",
+						"encapsulation": "ether",
+						"addresses": map[string]interface{}{
+							"0a:3c:5e:11:22:33":        map[string]interface{}{"family": "lladdr"},
+							"172.31.10.20":             map[string]interface{}{"family": "inet", "prefixlen": 20, "scope": "Global", "netmask": "255.255.240.0", "broadcast": "172.31.15.255"},
+							"fe80::83c:5eff:fe11:2233": map[string]interface{}{"family": "inet6", "prefixlen": 64, "scope": "Link"},
+						},
+					},
+				},
+				"default_gateway":   "172.31.0.1",
+				"default_interface": "ens5",
+				"routes": []map[string]interface{}{
+					{"destination": "default", "family": "inet", "via": "172.31.0.1", "proto": "dhcp", "src": "172.31.10.20", "metric": 100},
+					{"destination": "172.31.0.0/20", "family": "inet", "proto": "kernel", "scope": "link", "src": "172.31.10.20", "metric": 100},
+					{"destination": "fe80::/64", "family": "inet6", "proto": "kernel", "metric": 256},
+				},
+				"arp": map[string]string{
+					"172.31.0.1": "0a:e7:aa:bb:cc:01",
+				},
+				"neighbour_inet6": map[string]string{},
+			},
+			ipv4:   "172.31.10.20",
+			ipv6:   "fe80::83c:5eff:fe11:2233",
+			macAdr: "0a:3c:5e:11:22:33",
+		},
+		{
+			fixture: "alpine-3.18",
+			want: Data{
+				"interfaces": map[string]interface{}{
+					"lo": map[string]interface{}{
+						"flags":         []string{"LOOPBACK", "UP", "LOWER_UP"},
+						"mtu":           65536,
+						"state":         "unknown",
+						"encapsulation": "loopback",
+						"addresses": map[string]interface{}{
+							"127.0.0.1": map[string]interface{}{"family": "inet", "prefixlen": 8, "scope": "Node", "netmask": "255.0.0.0"},
+						},
+					},
+				},
+				"routes": []map[string]interface{}(nil),
+				"arp":    map[string]string{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		IPV4Addr, IPV6Addr, MacAddr = "", "", ""
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, tt.want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.fixture, d, tt.want)
+		}
+		if IPV4Addr != tt.ipv4 || IPV6Addr != tt.ipv6 || MacAddr != tt.macAdr {
+			t.Errorf("%s: got addresses %q, %q, %q; want %q, %q, %q", tt.fixture, IPV4Addr, IPV6Addr, MacAddr, tt.ipv4, tt.ipv6, tt.macAdr)
+		}
+	}
+}
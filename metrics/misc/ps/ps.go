@@ -12,18 +12,53 @@
 package ps
 
 import (
-	"os/exec"
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("ps", "running processes", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 // Data represents processes data.
-type Data []map[string]string
+type Data []map[string]interface{}
+
+// column describes a column of ps.
+type column struct {
+	key    string // key of the value in the data
+	legacy string // key of the value with LegacyUnits
+	// parse converts the value, if not nil.
+	parse func(string) (interface{}, error)
+}
+
+// columns maps the column headings of ps to their description.
+var columns = map[string]column{
+	"USER":    {"user", "user", nil},
+	"PID":     {"process_id", "process_id", parseInt},
+	"%CPU":    {"cpu_used_ratio", "percentage_cpu_used", parseRatio},
+	"%MEM":    {"memory_used_ratio", "percentage_mem_used", parseRatio},
+	"VSZ":     {"virtual_memory_used", "virtual_memory_used", parseKiB},
+	"RSS":     {"real_memory_used", "real_memory_used", parseKiB},
+	"TTY":     {"terminal", "terminal", nil},
+	"STAT":    {"status_code", "status_code", nil},
+	"START":   {"start_time", "start_time", nil},
+	"TIME":    {"total_cpu_utilization_time", "total_cpu_utilization_time", parseCPUTime},
+	"COMMAND": {"command", "command", nil},
+}
 
 // CollectData collects the data and returns
 // an error if any.
-func CollectData() (Data, error) {
+func CollectData(ctx context.Context) (Data, error) {
 	var d Data
-	out, err := exec.Command("ps", "aux").Output()
+	out, err := host.Command(ctx, "ps", "aux")
 	if err != nil {
 		return d, err
 	}
@@ -32,26 +67,79 @@ func CollectData() (Data, error) {
 	// lines[0] is the column headings
 	// USER       PID %CPU %MEM    VSZ   RSS TTY      STAT START   TIME COMMAND
 	// root         1  0.0  0.0  33776  4264 ?        Ss   10:07   0:01 /sbin/init
+	//
+	// busybox ignores the arguments and has fewer columns
+	// PID   USER     TIME  COMMAND
+	//     1 root      0:00 /sbin/init
+	headings := strings.Fields(lines[0])
+	if len(headings) == 0 || headings[len(headings)-1] != "COMMAND" {
+		return d, errors.New("ps: unexpected column headings")
+	}
 
 	for _, line := range lines[1:] {
 		a := strings.Fields(line)
-		if len(a) >= 10 {
-			m := map[string]string{
-				"user":                       a[0],
-				"process_id":                 a[1],
-				"percentage_cpu_used":        a[2],
-				"percentage_mem_used":        a[3],
-				"virtual_memory_used":        a[4],
-				"real_memory_used":           a[5],
-				"terminal":                   a[6],
-				"status_code":                a[7],
-				"start_time":                 a[8],
-				"total_cpu_utilization_time": a[9],
-				"command":                    strings.Join(a[10:], " "),
+		if len(a) < len(headings) {
+			continue
+		}
+		m := make(map[string]interface{})
+		for i, h := range headings {
+			c, ok := columns[h]
+			if !ok {
+				continue
+			}
+			v := a[i]
+			if h == "COMMAND" {
+				v = strings.Join(a[i:], " ")
+			}
+			switch {
+			case metrics.LegacyUnits:
+				m[c.legacy] = v
+			case c.parse == nil:
+				m[c.key] = v
+			default:
+				x, err := c.parse(v)
+				if err != nil {
+					return d, fmt.Errorf("ps: %s: %v", h, err)
+				}
+				m[c.key] = x
 			}
-			d = append(d, m)
 		}
+		d = append(d, m)
 	}
 
 	return d, nil
 }
+
+func parseInt(s string) (interface{}, error) {
+	return strconv.Atoi(s)
+}
+
+func parseRatio(s string) (interface{}, error) {
+	return metrics.ParseRatio(s)
+}
+
+// parseKiB parses a size in KiB and returns it in bytes.
+func parseKiB(s string) (interface{}, error) {
+	return metrics.ParseBytes(s + "k")
+}
+
+// parseCPUTime parses the cumulative CPU time, [dd-][hh:]mm:ss,
+// and returns it in seconds.
+func parseCPUTime(s string) (interface{}, error) {
+	var days, secs int
+	if i := strings.Index(s, "-"); i != -1 {
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q", s)
+		}
+		days, s = n, s[i+1:]
+	}
+	for _, f := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q", s)
+		}
+		secs = secs*60 + n
+	}
+	return days*24*60*60 + secs, nil
+}
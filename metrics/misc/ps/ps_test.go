@@ -0,0 +1,115 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package ps
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		count   int
+		last    map[string]interface{}
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			count:   3,
+			last: map[string]interface{}{
+				"user":                       "alice",
+				"process_id":                 2112,
+				"cpu_used_ratio":             0.066,
+				"memory_used_ratio":          0.025,
+				"virtual_memory_used":        uint64(1123456 * 1024),
+				"real_memory_used":           uint64(201234 * 1024),
+				"terminal":                   "?",
+				"status_code":                "Sl",
+				"start_time":                 "10:09",
+				"total_cpu_utilization_time": 723,
+				"command":                    "/opt/google/chrome/chrome --type=renderer",
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			count:   2,
+			last: map[string]interface{}{
+				"user":                       "bob",
+				"process_id":                 1843,
+				"cpu_used_ratio":             0.033,
+				"memory_used_ratio":          0.025,
+				"virtual_memory_used":        uint64(4231232 * 1024),
+				"real_memory_used":           uint64(412344 * 1024),
+				"terminal":                   "?",
+				"status_code":                "Sl",
+				"start_time":                 "09:00",
+				"total_cpu_utilization_time": 41,
+				"command":                    "/snap/firefox/3358/usr/lib/firefox/firefox",
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			count:   3,
+			last: map[string]interface{}{
+				"user":                       "nginx",
+				"process_id":                 598,
+				"total_cpu_utilization_time": 3,
+				"command":                    "nginx: worker process",
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if len(d) != tt.count {
+			t.Errorf("%s: got %d processes; want %d", tt.fixture, len(d), tt.count)
+			continue
+		}
+		if got := d[len(d)-1]; !reflect.DeepEqual(got, tt.last) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.fixture, got, tt.last)
+		}
+	}
+}
+
+func TestCollectDataLegacyUnits(t *testing.T) {
+	restore, err := host.Fixture(filepath.Join("testdata", "ubuntu-14.04"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+	metrics.LegacyUnits = true
+	defer func() { metrics.LegacyUnits = false }()
+
+	d, err := CollectData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := d[len(d)-1]
+	for k, want := range map[string]string{
+		"process_id":                 "2112",
+		"percentage_cpu_used":        "6.6",
+		"virtual_memory_used":        "1123456",
+		"total_cpu_utilization_time": "12:03",
+	} {
+		if got := last[k]; got != want {
+			t.Errorf("%s: got %v; want %v", k, got, want)
+		}
+	}
+}
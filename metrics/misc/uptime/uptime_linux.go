@@ -6,42 +6,55 @@
 package uptime
 
 import (
-	"io/ioutil"
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
-// Data holds uptime and idletime data.
+func init() {
+	metrics.Register(metrics.NewCollector("uptime", "uptime and idle time from /proc/uptime", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
+// Data holds uptime and idletime data in seconds.
 type Data map[string]interface{}
 
 // CollectData collects the data and returns an error if any.
-func CollectData() (Data, error) {
+func CollectData(ctx context.Context) (Data, error) {
 	d := make(Data)
-	b, err := ioutil.ReadFile("/proc/uptime")
+	b, err := host.ReadFile("/proc/uptime")
 	if err != nil {
 		log.Println("uptime: ", err)
 		return nil, err
 	}
-	s := strings.Split(strings.Trim(string(b), "\n"), " ")
-	if len(s) == 2 {
-		us, err := strconv.ParseFloat(s[0], 32)
-		if err != nil {
-			return nil, err
-		}
-		d["uptime_seconds"] = us
-		dur := time.Duration(us) * time.Second
-		d["uptime"] = dur.String()
-
-		is, err := strconv.ParseFloat(s[1], 32)
-		if err != nil {
-			return nil, err
-		}
-		d["idletime_seconds"] = is
-		dur = time.Duration(is) * time.Second
-		d["idletime"] = dur.String()
-
+	// 267313.71 1021478.35
+	s := strings.Fields(string(b))
+	if len(s) != 2 {
+		return nil, fmt.Errorf("uptime: unexpected content %q", b)
+	}
+	us, err := strconv.ParseFloat(s[0], 64)
+	if err != nil {
+		return nil, err
+	}
+	is, err := strconv.ParseFloat(s[1], 64)
+	if err != nil {
+		return nil, err
+	}
+	if metrics.LegacyUnits {
+		d["uptime_seconds"] = float64(float32(us))
+		d["uptime"] = (time.Duration(us) * time.Second).String()
+		d["idletime_seconds"] = float64(float32(is))
+		d["idletime"] = (time.Duration(is) * time.Second).String()
+		return d, nil
 	}
+	d["uptime"] = us
+	d["idletime"] = is
 	return d, nil
 }
@@ -0,0 +1,64 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package uptime
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture          string
+		uptime, idletime float64
+	}{
+		{"ubuntu-14.04", 267313.95, 1021478.83},
+		{"ubuntu-22.04", 912.04, 13987.51},
+		{"alpine-3.18", 59.82, 110.36},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if d["uptime"] != tt.uptime {
+			t.Errorf("%s: got uptime %v; want %v", tt.fixture, d["uptime"], tt.uptime)
+		}
+		if d["idletime"] != tt.idletime {
+			t.Errorf("%s: got idletime %v; want %v", tt.fixture, d["idletime"], tt.idletime)
+		}
+	}
+}
+
+func TestCollectDataLegacyUnits(t *testing.T) {
+	restore, err := host.Fixture(filepath.Join("testdata", "ubuntu-14.04"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+	metrics.LegacyUnits = true
+	defer func() { metrics.LegacyUnits = false }()
+
+	d, err := CollectData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d["uptime"], "74h15m13s"; got != want {
+		t.Errorf("got uptime %v; want %v", got, want)
+	}
+	if got, want := d["idletime"], "283h44m38s"; got != want {
+		t.Errorf("got idletime %v; want %v", got, want)
+	}
+}
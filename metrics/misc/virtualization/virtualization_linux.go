@@ -0,0 +1,145 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// Package virtualization detects whether the host is a virtual
+// machine or a container, and of which kind.
+package virtualization
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	metrics.Register(metrics.NewCollector("virtualization", "virtual machine or container the host runs in", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
+// Data represents the virtualization data. The "system" is e.g.
+// "kvm" or "docker", or "none" on bare metal, and the "role" is
+// "guest", unless the system is "none". A container on a virtual
+// machine is reported as the container.
+type Data map[string]string
+
+// vendors maps the DMI system vendors and product names
+// of the virtual machines to their systems.
+var vendors = []struct {
+	prefix, system string
+}{
+	{"QEMU", "kvm"},
+	{"KVM", "kvm"},
+	{"Amazon EC2", "kvm"},
+	{"Google", "kvm"},
+	{"OpenStack", "kvm"},
+	{"VMware", "vmware"},
+	{"innotek GmbH", "virtualbox"},
+	{"VirtualBox", "virtualbox"},
+	{"Xen", "xen"},
+	{"Parallels", "parallels"},
+	{"Bochs", "bochs"},
+}
+
+// cgroupSystems maps the substrings of the cgroups
+// of init to the containers they denote.
+var cgroupSystems = []struct {
+	substr, system string
+}{
+	{"/kubepods", "kubernetes"},
+	{"/docker", "docker"},
+	{"/lxc", "lxc"},
+	{"/machine.slice", "systemd-nspawn"},
+}
+
+// CollectData collects the data and returns an error if any.
+func CollectData(ctx context.Context) (Data, error) {
+	if s := container(); s != "" {
+		return Data{"system": s, "role": "guest"}, nil
+	}
+	s, err := virtualMachine()
+	if err != nil {
+		return nil, err
+	}
+	if s == "" {
+		return Data{"system": "none"}, nil
+	}
+	return Data{"system": s, "role": "guest"}, nil
+}
+
+// container returns the container system the host runs in, if any.
+func container() string {
+	switch {
+	case host.Exists("/.dockerenv"):
+		return "docker"
+	case host.Exists("/run/.containerenv"):
+		return "podman"
+	}
+	// systemd and the other container managers
+	// set the container variable of init.
+	if b, err := host.ReadFile("/proc/1/environ"); err == nil {
+		for _, v := range bytes.Split(b, []byte{0}) {
+			if bytes.HasPrefix(v, []byte("container=")) {
+				return string(v[len("container="):])
+			}
+		}
+	}
+	if b, err := host.ReadFile("/proc/1/cgroup"); err == nil {
+		for _, s := range cgroupSystems {
+			if bytes.Contains(b, []byte(s.substr)) {
+				return s.system
+			}
+		}
+	}
+	return ""
+}
+
+// virtualMachine returns the hypervisor of the
+// virtual machine the host runs in, if any.
+func virtualMachine() (string, error) {
+	for _, name := range []string{"/sys/class/dmi/id/sys_vendor", "/sys/class/dmi/id/product_name"} {
+		b, err := host.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		v := strings.TrimSpace(string(b))
+		for _, s := range vendors {
+			if strings.HasPrefix(v, s.prefix) {
+				return s.system, nil
+			}
+		}
+		if strings.HasPrefix(v, "Microsoft Corporation") || v == "Virtual Machine" {
+			return "hyperv", nil
+		}
+	}
+	if host.Exists("/proc/xen") {
+		return "xen", nil
+	}
+
+	// The hypervisor is unknown, but the
+	// CPU tells that there is one.
+	f, err := host.Open("/proc/cpuinfo")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		l := s.Text()
+		if !strings.HasPrefix(l, "flags") {
+			continue
+		}
+		for _, flag := range strings.Fields(l) {
+			if flag == "hypervisor" {
+				return "unknown", nil
+			}
+		}
+		break
+	}
+	return "", s.Err()
+}
@@ -0,0 +1,581 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+// Package cgroup provides the resource usage of the control groups,
+// i.e. of the services, containers and user sessions, so that the one
+// misbehaving can be told apart from the numbers of the whole host.
+//
+// It understands both the unified hierarchy of cgroup v2 and the
+// cpu, cpuacct, memory and blkio hierarchies of cgroup v1. The CPU
+// times are in seconds, the CPU limit is in CPUs, and the memory and
+// IO sizes are in bytes. On cgroup v2, the cgroups without any process
+// in them are left out.
+package cgroup
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	metrics.Register(metrics.NewCollector("cgroup", "CPU, memory and IO usage of the cgroups", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
+// Data represents the cgroup data, i.e. the cgroup version and
+// the data of the cgroups keyed by their path, e.g.
+// "/system.slice/nginx.service".
+type Data map[string]interface{}
+
+// v1Controllers are the cgroup v1 controllers whose
+// hierarchies are read, in the order they are read.
+var v1Controllers = []string{"cpuacct", "cpu", "memory", "blkio"}
+
+// CollectData collects the data and returns an error if any.
+func CollectData(ctx context.Context) (Data, error) {
+	unified, v1, err := mounts()
+	if err != nil {
+		return nil, err
+	}
+	cgroups := make(map[string]map[string]interface{})
+	d := Data{"cgroups": cgroups}
+
+	switch {
+	case unified != "" && len(v1) == 0:
+		d["version"] = 2
+		err = walk(ctx, unified, "/", func(dir, p string) error {
+			return v2Stats(cgroups, dir, p)
+		})
+	case len(v1) > 0:
+		d["version"] = 1
+		for _, c := range v1Controllers {
+			dir, ok := v1[c]
+			if !ok {
+				continue
+			}
+			err = walk(ctx, dir, "/", func(dir, p string) error {
+				return v1Stats(cgroups, c, dir, p)
+			})
+			if err != nil {
+				break
+			}
+		}
+	default:
+		return nil, errors.New("cgroup: no cgroup hierarchy is mounted")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for p, m := range cgroups {
+		for k, v := range names(p) {
+			m[k] = v
+		}
+	}
+	return d, nil
+}
+
+// mounts returns the mount point of the unified hierarchy, and the
+// mount points of the v1 hierarchies keyed by their controllers.
+// The unified hierarchy is only returned if it has any controllers,
+// i.e. not in the hybrid mode of systemd.
+func mounts() (unified string, v1 map[string]string, err error) {
+	f, err := host.Open("/proc/mounts")
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	v1 = make(map[string]string)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// cgroup2 /sys/fs/cgroup cgroup2 rw,nosuid,nodev,noexec,relatime 0 0
+		// cgroup /sys/fs/cgroup/cpu,cpuacct cgroup rw,nosuid,nodev,noexec,relatime,cpu,cpuacct 0 0
+		a := strings.Fields(s.Text())
+		if len(a) < 4 {
+			continue
+		}
+		switch a[2] {
+		case "cgroup2":
+			b, err := host.ReadFile(filepath.Join(a[1], "cgroup.controllers"))
+			if err == nil && len(strings.TrimSpace(string(b))) > 0 {
+				unified = a[1]
+			}
+		case "cgroup":
+			for _, opt := range strings.Split(a[3], ",") {
+				for _, c := range v1Controllers {
+					if opt == c {
+						v1[c] = a[1]
+					}
+				}
+			}
+		}
+	}
+	return unified, v1, s.Err()
+}
+
+// walk calls f for every cgroup below the directory dir, which is
+// relative to Root, with the directory of the cgroup and its path.
+// The cgroup of dir itself, whose path is p, is left out as it is
+// the root of the hierarchy.
+func walk(ctx context.Context, dir, p string, f func(dir, p string) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	names, err := host.ReadDir(dir)
+	if err != nil {
+		// The cgroup was removed while walking.
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sub := filepath.Join(dir, name)
+		fi, err := host.Stat(sub)
+		if err != nil || !fi.IsDir() {
+			continue
+		}
+		if err := f(sub, path.Join(p, name)); err != nil {
+			return err
+		}
+		if err := walk(ctx, sub, path.Join(p, name), f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// section returns the named section of the data of the cgroup p,
+// adding the cgroup and the section if they don't exist.
+func section(cgroups map[string]map[string]interface{}, p, name string) map[string]interface{} {
+	m, ok := cgroups[p]
+	if !ok {
+		m = make(map[string]interface{})
+		cgroups[p] = m
+	}
+	s, ok := m[name].(map[string]interface{})
+	if !ok {
+		s = make(map[string]interface{})
+		m[name] = s
+	}
+	return s
+}
+
+func v2Stats(cgroups map[string]map[string]interface{}, dir, p string) error {
+	ev, err := readKeyValues(dir, "cgroup.events")
+	if err != nil {
+		return err
+	}
+	if ev["populated"] == 0 {
+		return nil
+	}
+
+	cpu := section(cgroups, p, "cpu")
+	st, err := readKeyValues(dir, "cpu.stat")
+	if err != nil {
+		return err
+	}
+	for k, v := range st {
+		switch k {
+		case "usage_usec", "user_usec", "system_usec", "throttled_usec":
+			cpu[strings.TrimSuffix(k, "_usec")] = float64(v) / 1e6
+		case "nr_periods":
+			cpu["periods"] = v
+		case "nr_throttled":
+			cpu["throttled_periods"] = v
+		}
+	}
+	// 50000 100000
+	if a, err := readFields(dir, "cpu.max"); err != nil {
+		return err
+	} else if len(a) == 2 && a[0] != "max" {
+		if err := cpuLimit(cpu, a[0], a[1]); err != nil {
+			return fmt.Errorf("cgroup: %s: %v", p, err)
+		}
+	}
+
+	if n, ok, err := readUint(dir, "memory.current"); err != nil {
+		return err
+	} else if ok {
+		mem := section(cgroups, p, "memory")
+		mem["usage"] = n
+		if n, ok, err := readUint(dir, "memory.max"); err != nil {
+			return err
+		} else if ok {
+			mem["limit"] = n
+		}
+		ev, err := readKeyValues(dir, "memory.events")
+		if err != nil {
+			return err
+		}
+		if ev != nil {
+			mem["oom_events"] = ev["oom"]
+			mem["oom_kills"] = ev["oom_kill"]
+		}
+	}
+
+	// 8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+	b, err := host.ReadFile(filepath.Join(dir, "io.stat"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	io := section(cgroups, p, "io")
+	keys := map[string]string{"rbytes": "read_bytes", "wbytes": "write_bytes", "rios": "reads", "wios": "writes"}
+	for _, k := range keys {
+		io[k] = uint64(0)
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		for _, f := range strings.Fields(line) {
+			kv := strings.SplitN(f, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			k, ok := keys[kv[0]]
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("cgroup: %s: invalid %s %q", p, kv[0], kv[1])
+			}
+			io[k] = io[k].(uint64) + n
+		}
+	}
+	return nil
+}
+
+// userHZ is the unit of the times in cpuacct.stat, which
+// is 1/100 of a second on all the architectures that matter.
+const userHZ = 100
+
+// unlimited is the least value of memory.limit_in_bytes which
+// means that the memory isn't limited. The actual value depends
+// on the page size.
+const unlimited = 1 << 62
+
+func v1Stats(cgroups map[string]map[string]interface{}, controller, dir, p string) error {
+	switch controller {
+	case "cpuacct":
+		cpu := section(cgroups, p, "cpu")
+		if n, ok, err := readUint(dir, "cpuacct.usage"); err != nil {
+			return err
+		} else if ok {
+			cpu["usage"] = float64(n) / 1e9
+		}
+		st, err := readKeyValues(dir, "cpuacct.stat")
+		if err != nil {
+			return err
+		}
+		for k, v := range st {
+			cpu[k] = float64(v) / userHZ
+		}
+	case "cpu":
+		cpu := section(cgroups, p, "cpu")
+		st, err := readKeyValues(dir, "cpu.stat")
+		if err != nil {
+			return err
+		}
+		for k, v := range st {
+			switch k {
+			case "nr_periods":
+				cpu["periods"] = v
+			case "nr_throttled":
+				cpu["throttled_periods"] = v
+			case "throttled_time":
+				cpu["throttled"] = float64(v) / 1e9
+			}
+		}
+		quota, err := readFields(dir, "cpu.cfs_quota_us")
+		if err != nil {
+			return err
+		}
+		period, err := readFields(dir, "cpu.cfs_period_us")
+		if err != nil {
+			return err
+		}
+		// The quota is -1 without a limit.
+		if len(quota) == 1 && len(period) == 1 && quota[0] != "-1" {
+			if err := cpuLimit(cpu, quota[0], period[0]); err != nil {
+				return fmt.Errorf("cgroup: %s: %v", p, err)
+			}
+		}
+	case "memory":
+		n, ok, err := readUint(dir, "memory.usage_in_bytes")
+		if err != nil || !ok {
+			return err
+		}
+		mem := section(cgroups, p, "memory")
+		mem["usage"] = n
+		if n, ok, err := readUint(dir, "memory.limit_in_bytes"); err != nil {
+			return err
+		} else if ok && n < unlimited {
+			mem["limit"] = n
+		}
+		// oom_kill is missing before Linux 4.13.
+		oc, err := readKeyValues(dir, "memory.oom_control")
+		if err != nil {
+			return err
+		}
+		if n, ok := oc["oom_kill"]; ok {
+			mem["oom_kills"] = n
+		}
+	case "blkio":
+		io := make(map[string]interface{})
+		for _, f := range []struct {
+			file        string
+			read, write string
+		}{
+			{"blkio.throttle.io_service_bytes", "read_bytes", "write_bytes"},
+			{"blkio.throttle.io_serviced", "reads", "writes"},
+		} {
+			r, w, ok, err := readBlkio(dir, f.file)
+			if err != nil {
+				return fmt.Errorf("cgroup: %s: %v", p, err)
+			}
+			if ok {
+				io[f.read] = r
+				io[f.write] = w
+			}
+		}
+		if len(io) > 0 {
+			s := section(cgroups, p, "io")
+			for k, v := range io {
+				s[k] = v
+			}
+		}
+	}
+	return nil
+}
+
+// cpuLimit adds the number of CPUs the cgroup is limited to, which
+// is the quota of CPU time it may use in every period.
+func cpuLimit(cpu map[string]interface{}, quota, period string) error {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil {
+		return fmt.Errorf("invalid CPU quota %q", quota)
+	}
+	pd, err := strconv.ParseFloat(period, 64)
+	if err != nil || pd == 0 {
+		return fmt.Errorf("invalid CPU period %q", period)
+	}
+	cpu["limit"] = q / pd
+	return nil
+}
+
+// readBlkio returns the sums of the reads and writes of all the
+// devices in the named blkio file.
+func readBlkio(dir, name string) (read, write uint64, ok bool, err error) {
+	b, err := host.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, false, nil
+		}
+		return 0, 0, false, err
+	}
+	// 8:0 Read 1459200
+	// 8:0 Write 314773504
+	// Total 316232704
+	for _, line := range strings.Split(string(b), "\n") {
+		a := strings.Fields(line)
+		if len(a) != 3 {
+			continue
+		}
+		n, err := strconv.ParseUint(a[2], 10, 64)
+		if err != nil {
+			return 0, 0, false, fmt.Errorf("invalid %s %q", name, line)
+		}
+		switch a[1] {
+		case "Read":
+			read += n
+		case "Write":
+			write += n
+		}
+	}
+	return read, write, true, nil
+}
+
+// readFields returns the fields of the named file in dir, or
+// nil if the file doesn't exist, e.g. because the controller
+// isn't enabled for the cgroup.
+func readFields(dir, name string) ([]string, error) {
+	b, err := host.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return strings.Fields(string(b)), nil
+}
+
+// readUint returns the number in the named file in dir. It returns
+// false if the file doesn't exist or holds "max", i.e. no limit.
+func readUint(dir, name string) (uint64, bool, error) {
+	a, err := readFields(dir, name)
+	if err != nil || len(a) != 1 || a[0] == "max" {
+		return 0, false, err
+	}
+	n, err := strconv.ParseUint(a[0], 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("cgroup: invalid %s %q", filepath.Join(dir, name), a[0])
+	}
+	return n, true, nil
+}
+
+// readKeyValues returns the numbers in the named file in dir,
+// which has a key and a number in each line, keyed by the keys.
+// It returns nil if the file doesn't exist.
+func readKeyValues(dir, name string) (map[string]uint64, error) {
+	b, err := host.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	m := make(map[string]uint64)
+	for _, line := range strings.Split(string(b), "\n") {
+		a := strings.Fields(line)
+		if len(a) != 2 {
+			continue
+		}
+		n, err := strconv.ParseUint(a[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cgroup: invalid %s in %s: %q", a[0], filepath.Join(dir, name), a[1])
+		}
+		m[a[0]] = n
+	}
+	return m, nil
+}
+
+// runtimes maps the prefixes of the systemd scopes of the
+// containers to the container runtimes.
+var runtimes = []struct {
+	prefix, runtime string
+}{
+	{"docker-", "docker"},
+	{"cri-containerd-", "containerd"},
+	{"crio-", "cri-o"},
+	{"libpod-", "podman"},
+}
+
+// names returns the friendly name of the cgroup p. For a container,
+// it also returns its id and runtime, and the uid of its pod if it
+// is run by Kubernetes.
+func names(p string) map[string]interface{} {
+	base := path.Base(p)
+	parent := path.Base(path.Dir(p))
+	m := map[string]interface{}{"name": base}
+
+	var id, runtime string
+	for _, r := range runtimes {
+		// /system.slice/docker-4f3c....scope
+		if strings.HasPrefix(base, r.prefix) && strings.HasSuffix(base, ".scope") {
+			if s := strings.TrimSuffix(strings.TrimPrefix(base, r.prefix), ".scope"); isContainerID(s) {
+				id, runtime = s, r.runtime
+			}
+		}
+	}
+	switch {
+	case id != "":
+	case parent == "docker" && isContainerID(base):
+		// /docker/4f3c...
+		id, runtime = base, "docker"
+	case strings.HasPrefix(parent, "pod") && isContainerID(base):
+		// /kubepods/burstable/pod0f1e.../4f3c...
+		id = base
+	case parent == "lxc":
+		// /lxc/web
+		m["runtime"] = "lxc"
+		return m
+	case strings.HasPrefix(base, "lxc.payload."):
+		m["name"] = strings.TrimPrefix(base, "lxc.payload.")
+		m["runtime"] = "lxc"
+		return m
+	default:
+		return m
+	}
+
+	m["container_id"] = id
+	m["name"] = id[:12]
+	if runtime != "" {
+		m["runtime"] = runtime
+	}
+	if runtime == "docker" {
+		if name := dockerName(id); name != "" {
+			m["name"] = name
+		}
+	}
+	if uid := podUID(p); uid != "" {
+		m["pod_uid"] = uid
+	}
+	return m
+}
+
+// isContainerID returns true if s looks like the id of a container.
+func isContainerID(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// podUID returns the uid of the Kubernetes pod of the cgroup p,
+// which is either named pod<uid> or, with the systemd driver,
+// kubepods-<qos>-pod<uid>.slice with the dashes of the uid
+// replaced by underscores.
+func podUID(p string) string {
+	for _, e := range strings.Split(p, "/") {
+		i := strings.LastIndex(e, "pod")
+		if i == -1 || !strings.HasPrefix(p, "/kubepods") {
+			continue
+		}
+		uid := strings.TrimSuffix(e[i+len("pod"):], ".slice")
+		if len(uid) == 36 {
+			return strings.Replace(uid, "_", "-", -1)
+		}
+	}
+	return ""
+}
+
+// dockerName returns the name of the docker container with the
+// given id from its configuration, or "" if it can't be read.
+func dockerName(id string) string {
+	b, err := host.ReadFile(filepath.Join("/var/lib/docker/containers", id, "config.v2.json"))
+	if err != nil {
+		return ""
+	}
+	var c struct {
+		Name string
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(c.Name, "/")
+}
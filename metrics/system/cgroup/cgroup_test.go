@@ -0,0 +1,152 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package cgroup
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+const (
+	dockerID   = "4f3c2a1b9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a"
+	k8sID      = "9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b"
+	testPodUID = "0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		version int
+		want    map[string]map[string]interface{} // subset of the cgroups
+		count   int                               // number of cgroups
+	}{
+		{
+			fixture: "ubuntu-22.04",
+			version: 2,
+			count:   4,
+			want: map[string]map[string]interface{}{
+				"/system.slice/nginx.service": {
+					"name":   "nginx.service",
+					"cpu":    map[string]interface{}{"usage": 12.5, "user": 10.0, "system": 2.5, "periods": uint64(0), "throttled_periods": uint64(0), "throttled": 0.0},
+					"memory": map[string]interface{}{"usage": uint64(52428800), "oom_events": uint64(0), "oom_kills": uint64(0)},
+					"io":     map[string]interface{}{"read_bytes": uint64(1459200), "write_bytes": uint64(4096), "reads": uint64(192), "writes": uint64(1)},
+				},
+				"/system.slice/docker-" + dockerID + ".scope": {
+					"name":         "web",
+					"container_id": dockerID,
+					"runtime":      "docker",
+					"cpu":          map[string]interface{}{"usage": 98.765432, "user": 88.765432, "system": 10.0, "periods": uint64(1200), "throttled_periods": uint64(37), "throttled": 2.5, "limit": 0.5},
+					"memory":       map[string]interface{}{"usage": uint64(402653184), "limit": uint64(536870912), "oom_events": uint64(3), "oom_kills": uint64(1)},
+					"io":           map[string]interface{}{"read_bytes": uint64(1500), "write_bytes": uint64(2000), "reads": uint64(15), "writes": uint64(20)},
+				},
+				"/user.slice": {
+					"name": "user.slice",
+					"cpu":  map[string]interface{}{"usage": 300.0, "user": 250.0, "system": 50.0},
+				},
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			version: 2,
+			count:   4,
+			want: map[string]map[string]interface{}{
+				"/kubepods/burstable/pod" + testPodUID + "/" + k8sID: {
+					"name":         k8sID[:12],
+					"container_id": k8sID,
+					"pod_uid":      testPodUID,
+					"cpu":          map[string]interface{}{"usage": 2.0, "user": 1.5, "system": 0.5, "periods": uint64(10), "throttled_periods": uint64(0), "throttled": 0.0, "limit": 2.0},
+					"memory":       map[string]interface{}{"usage": uint64(104857600), "limit": uint64(209715200), "oom_events": uint64(0), "oom_kills": uint64(0)},
+				},
+			},
+		},
+		{
+			fixture: "ubuntu-14.04",
+			version: 1,
+			count:   2,
+			want: map[string]map[string]interface{}{
+				"/docker": {
+					"name":   "docker",
+					"cpu":    map[string]interface{}{"usage": 3.0, "user": 2.5, "system": 0.5, "periods": uint64(0), "throttled_periods": uint64(0), "throttled": 0.0},
+					"memory": map[string]interface{}{"usage": uint64(734003200)},
+				},
+				"/docker/" + dockerID: {
+					"name":         "db",
+					"container_id": dockerID,
+					"runtime":      "docker",
+					"cpu":          map[string]interface{}{"usage": 1.5, "user": 1.2, "system": 0.3, "periods": uint64(500), "throttled_periods": uint64(20), "throttled": 1.5, "limit": 1.5},
+					"memory":       map[string]interface{}{"usage": uint64(268435456), "limit": uint64(536870912)},
+					"io":           map[string]interface{}{"read_bytes": uint64(4096), "write_bytes": uint64(8192), "reads": uint64(1), "writes": uint64(2)},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if d["version"] != tt.version {
+			t.Errorf("%s: got version %v; want %d", tt.fixture, d["version"], tt.version)
+		}
+		cgroups := d["cgroups"].(map[string]map[string]interface{})
+		if len(cgroups) != tt.count {
+			t.Errorf("%s: got %d cgroups; want %d", tt.fixture, len(cgroups), tt.count)
+		}
+		for p, want := range tt.want {
+			if got := cgroups[p]; !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: %s:\ngot  %v\nwant %v", tt.fixture, p, got, want)
+			}
+		}
+	}
+}
+
+func TestCollectDataWithoutCgroups(t *testing.T) {
+	restore, err := host.Fixture(filepath.Join("testdata", "none"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+	if _, err := CollectData(context.Background()); err == nil {
+		t.Error("want error when no cgroup hierarchy is mounted")
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		path string
+		want map[string]interface{}
+	}{
+		{
+			path: "/system.slice/crio-" + k8sID + ".scope",
+			want: map[string]interface{}{"name": k8sID[:12], "container_id": k8sID, "runtime": "cri-o"},
+		},
+		// units which merely look like those of the containers
+		{
+			path: "/system.slice/docker-abc.scope",
+			want: map[string]interface{}{"name": "docker-abc.scope"},
+		},
+		{
+			path: "/machine.slice/libpod-x.scope",
+			want: map[string]interface{}{"name": "libpod-x.scope"},
+		},
+	}
+	for _, tt := range tests {
+		if got := names(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v; want %v", tt.path, got, tt.want)
+		}
+	}
+}
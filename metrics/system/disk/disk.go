@@ -8,45 +8,52 @@
 package disk
 
 import (
-	"os/exec"
 	"strings"
+
+	"github.com/codeignition/recon/internal/mount"
+	"github.com/codeignition/recon/metrics"
+	"github.com/codeignition/recon/metrics/misc/filesystem"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("disk", "disk usage of block devices", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
+// Data represents the disk data. The sizes are in bytes.
 type Data map[string]interface{}
 
-func CollectData() (Data, error) {
+// CollectData collects the disk usage of the filesystems
+// on block devices and returns an error if any.
+func CollectData(ctx context.Context) (Data, error) {
 	d := make(Data)
 	d["disk"] = make(Data)
 	disk := d["disk"].(Data)
-	if err := sizeData(disk); err != nil {
-		return d, err
-	}
-	return d, nil
-}
 
-func sizeData(d Data) error {
-	out, err := exec.Command("df", "-P").Output()
+	mounts, err := mount.List(mount.DefaultFilter)
 	if err != nil {
-		return err
+		return d, err
 	}
-
-	lines := strings.Split(string(out), "\n")
-
-	// lines[0] contains the column headings
-	// Filesystem     1024-blocks     Used Available Capacity Mounted on
-	for _, line := range lines[1:] {
-		a := strings.Fields(line)
-		if len(a) >= 6 {
-			if strings.HasPrefix(a[0], "/dev/") {
-				d[a[0]] = map[string]interface{}{
-					"kb_size":         a[1],
-					"kb_used":         a[2],
-					"kb_available":    a[3],
-					"percentage_used": a[4],
-					"mounted_on":      a[5],
-				}
+	for _, m := range mounts {
+		if !strings.HasPrefix(m.Device, "/dev/") {
+			continue
+		}
+		if _, ok := disk[m.Device]; ok {
+			continue
+		}
+		u, err := mount.Stat(ctx, m.Point)
+		if err != nil {
+			disk[m.Device] = map[string]interface{}{
+				"mounted_on": m.Point,
+				"error":      err.Error(),
 			}
+			continue
 		}
+		s := filesystem.Sizes(u)
+		s["mounted_on"] = m.Point
+		disk[m.Device] = s
 	}
-	return nil
+	return d, nil
 }
@@ -0,0 +1,122 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package disk
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/mount"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Data
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			want: Data{
+				"/dev/sda1": map[string]interface{}{
+					"size":       uint64(245998739456),
+					"used":       uint64(100467040256),
+					"available":  uint64(133009924096),
+					"used_ratio": 0.4303,
+					"mounted_on": "/",
+				},
+				"/dev/sdb1": map[string]interface{}{
+					"size":       uint64(984373075968),
+					"used":       uint64(530854240256),
+					"available":  uint64(403491635200),
+					"used_ratio": 0.5682,
+					"mounted_on": "/media/alice/backup",
+				},
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			want: Data{
+				"/dev/mapper/vgubuntu-root": map[string]interface{}{
+					"size":       uint64(501390876672),
+					"used":       uint64(125833441280),
+					"available":  uint64(349963030528),
+					"used_ratio": 0.2645,
+					"mounted_on": "/",
+				},
+				"/dev/nvme0n1p2": map[string]interface{}{
+					"size":       uint64(536834048),
+					"used":       uint64(6365184),
+					"available":  uint64(530468864),
+					"used_ratio": 0.0119,
+					"mounted_on": "/boot/efi",
+				},
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			want: Data{
+				"/dev/vda3": map[string]interface{}{
+					"size":       uint64(19992276992),
+					"used":       uint64(1457254400),
+					"available":  uint64(17494355968),
+					"used_ratio": 0.0769,
+					"mounted_on": "/",
+				},
+				"/dev/vda1": map[string]interface{}{
+					"size":       uint64(97033216),
+					"used":       uint64(23697408),
+					"available":  uint64(66189312),
+					"used_ratio": 0.2636,
+					"mounted_on": "/boot",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := mount.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if got := d["disk"]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.fixture, got, tt.want)
+		}
+	}
+}
+
+func TestCollectDataLegacyUnits(t *testing.T) {
+	restore, err := mount.Fixture(filepath.Join("testdata", "ubuntu-14.04"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+	metrics.LegacyUnits = true
+	defer func() { metrics.LegacyUnits = false }()
+
+	d, err := CollectData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"kb_size":         "240233144",
+		"kb_used":         "98112344",
+		"kb_available":    "129892504",
+		"percentage_used": "44%",
+		"mounted_on":      "/",
+	}
+	if got := d["disk"].(Data)["/dev/sda1"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,399 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+// Package lvm provides the LVM volume groups and logical volumes,
+// along with the usage of the thin pools and snapshots.
+//
+// The active logical volumes are found in /sys/block/dm-*, which
+// is all there is without the LVM tools or the privileges to run
+// them. When lvs and vgs can be run, they add the inactive volumes,
+// the sizes of the volume groups and the data and metadata usage.
+//
+// Each volume and volume group has a status, "ok", "warning" or
+// "critical", and the worst of them is the lvm_status.
+package lvm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"github.com/codeignition/recon/policy"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	metrics.Register(metrics.NewCollector("lvm", "LVM volume groups, logical volumes and thin pool usage", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
+// Data represents the LVM data.
+type Data map[string]interface{}
+
+// The usage of a thin pool or a snapshot, as a ratio, above
+// which it is a warning and critical. A full thin pool fails
+// its writes and a full snapshot is invalidated.
+var (
+	UsageWarning  = 0.8
+	UsageCritical = 0.95
+)
+
+// CommandTTL is how long the outputs of lvs and vgs are reused, so
+// that they aren't run on every collection, e.g. every 5 seconds of
+// the system data. The active volumes in /sys/block are read anew.
+var CommandTTL = time.Minute
+
+// outputs are the last outputs of the commands, keyed by command line.
+var outputs = struct {
+	sync.Mutex
+	m map[string]output
+}{
+	m: make(map[string]output),
+}
+
+type output struct {
+	out []byte
+	err error
+	t   time.Time
+}
+
+// command runs the command, unless it has been run within CommandTTL,
+// and returns its output. Its failures, e.g. without the LVM tools,
+// are reused as well, but not those of the cancelled contexts.
+func command(ctx context.Context, name string, arg ...string) ([]byte, error) {
+	key := strings.Join(append([]string{name}, arg...), " ")
+	outputs.Lock()
+	defer outputs.Unlock()
+	if o, ok := outputs.m[key]; ok && time.Since(o.t) < CommandTTL {
+		return o.out, o.err
+	}
+	out, err := host.Command(ctx, name, arg...)
+	if ctx.Err() == nil {
+		outputs.m[key] = output{out, err, time.Now()}
+	}
+	return out, err
+}
+
+// CollectData collects the data and returns an error if any.
+// A machine without LVM has no volumes, which isn't an error.
+func CollectData(ctx context.Context) (Data, error) {
+	lvs, err := devices()
+	if err != nil {
+		return nil, err
+	}
+	// lvs and vgs are missing without the LVM tools,
+	// and fail without the privileges to run them.
+	if out, err := command(ctx, "lvs", "--noheadings", "--nosuffix", "--units", "b", "--separator", "|",
+		"-o", "vg_name,lv_name,lv_attr,lv_size,pool_lv,data_percent,metadata_percent"); err == nil {
+		if err := parseLVs(lvs, string(out)); err != nil {
+			return nil, err
+		}
+	}
+	vgs := make(map[string]map[string]interface{})
+	if out, err := command(ctx, "vgs", "--noheadings", "--nosuffix", "--units", "b", "--separator", "|",
+		"-o", "vg_name,vg_attr,vg_size,vg_free,pv_count"); err == nil {
+		if vgs, err = parseVGs(string(out)); err != nil {
+			return nil, err
+		}
+	}
+
+	status := policy.StatusOK
+	for _, lv := range lvs {
+		lv["status"] = lvStatus(lv)
+		vg, ok := vgs[lv["vg"].(string)]
+		if !ok {
+			vg = map[string]interface{}{"status": policy.StatusOK}
+			vgs[lv["vg"].(string)] = vg
+		}
+		n, _ := vg["logical_volumes"].(int)
+		vg["logical_volumes"] = n + 1
+		vg["status"] = policy.Worse(vg["status"].(string), lv["status"].(string))
+	}
+	for _, vg := range vgs {
+		if _, ok := vg["logical_volumes"]; !ok {
+			vg["logical_volumes"] = 0
+		}
+		status = policy.Worse(status, vg["status"].(string))
+	}
+	return Data{
+		"volume_groups":   vgs,
+		"logical_volumes": lvs,
+		"lvm_status":      status,
+	}, nil
+}
+
+// hidden are the infixes of the names of the volumes which
+// LVM creates for its own use, e.g. the data of a thin pool
+// or the images of a mirror, and doesn't show.
+var hidden = []string{
+	"_tdata", "_tmeta", "_pmspare",
+	"_rimage_", "_rmeta_", "_mimage_", "_mlog",
+	"_cdata", "_cmeta", "_corig", "_cpool", "_cvol",
+	"_vorigin", "_vdata",
+}
+
+// devices returns the active logical volumes in /sys/block,
+// keyed by their volume group and name, e.g. "vg0/root".
+func devices() (map[string]map[string]interface{}, error) {
+	names, err := host.ReadDir("/sys/block")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]map[string]interface{}), nil
+		}
+		return nil, err
+	}
+	sort.Strings(names)
+
+	lvs := make(map[string]map[string]interface{})
+	for _, dev := range names {
+		if !strings.HasPrefix(dev, "dm-") {
+			continue
+		}
+		dir := filepath.Join("/sys/block", dev)
+		// LVM-<VG UUID><LV UUID>, followed by -tpool, -real, -cow
+		// and such for the layers of the volume.
+		uuid := readString(dir, "dm/uuid")
+		if !strings.HasPrefix(uuid, "LVM-") {
+			continue
+		}
+		name := readString(dir, "dm/name")
+		if i := strings.Index(uuid[len("LVM-"):], "-"); i != -1 {
+			name = strings.TrimSuffix(name, uuid[len("LVM-")+i:])
+		}
+		vg, lv := splitName(name)
+		if vg == "" || isHidden(lv) {
+			continue
+		}
+		key := vg + "/" + lv
+		if _, ok := lvs[key]; ok {
+			continue
+		}
+		m := map[string]interface{}{
+			"vg":        vg,
+			"name":      lv,
+			"device":    dev,
+			"active":    true,
+			"suspended": readString(dir, "dm/suspended") == "1",
+		}
+		if n, err := strconv.ParseUint(readString(dir, "size"), 10, 64); err == nil {
+			m["size"] = n * 512 // in sectors
+		}
+		lvs[key] = m
+	}
+	return lvs, nil
+}
+
+// splitName splits the device mapper name of a logical volume into
+// its volume group and name, e.g. "vg--data-lv--home" into "vg-data"
+// and "lv-home". The dashes in the names are doubled.
+func splitName(s string) (vg, lv string) {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '-' {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '-' {
+			i++
+			continue
+		}
+		unescape := func(s string) string { return strings.Replace(s, "--", "-", -1) }
+		return unescape(s[:i]), unescape(s[i+1:])
+	}
+	return "", ""
+}
+
+// isHidden reports whether the logical volume lv is used by LVM itself.
+func isHidden(lv string) bool {
+	for _, h := range hidden {
+		if strings.Contains(lv, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// lvTypes maps the first character of lv_attr to the type of the volume.
+var lvTypes = map[byte]string{
+	'-': "linear",
+	'o': "linear", // origin of snapshots
+	'O': "linear",
+	'C': "cache",
+	'm': "mirror",
+	'M': "mirror",
+	'r': "raid",
+	'R': "raid",
+	's': "snapshot",
+	'S': "snapshot",
+	't': "thin-pool",
+	'V': "thin",
+	'v': "virtual",
+	'p': "pvmove",
+}
+
+// lvHealth maps the ninth character of lv_attr to the health
+// of the volume. The others are healthy.
+var lvHealth = map[byte]string{
+	'p': "partial",
+	'F': "failed",
+	'D': "out_of_data_space",
+	'M': "metadata_read_only",
+	'r': "refresh_needed",
+	'm': "mismatches",
+	'X': "unknown",
+}
+
+// healthStatus maps the unhealthy states of the volumes to their status.
+var healthStatus = map[string]string{
+	"invalid":            policy.StatusCritical, // a full snapshot
+	"partial":            policy.StatusCritical,
+	"failed":             policy.StatusCritical,
+	"out_of_data_space":  policy.StatusCritical,
+	"metadata_read_only": policy.StatusCritical,
+	"refresh_needed":     policy.StatusWarning,
+	"mismatches":         policy.StatusWarning,
+	"unknown":            policy.StatusWarning,
+}
+
+// parseLVs parses the output of lvs and adds the volumes to lvs, e.g.
+//
+//	vg0|pool0|twi-aotz--|107374182400||12.50|4.21
+//	vg0|data|Vwi-aotz--|53687091200|pool0|24.99|
+func parseLVs(lvs map[string]map[string]interface{}, out string) error {
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		f := strings.Split(line, "|")
+		if len(f) != 7 || len(f[2]) < 5 {
+			return fmt.Errorf("lvm: unexpected lvs output %q", line)
+		}
+		key := f[0] + "/" + f[1]
+		lv, ok := lvs[key]
+		if !ok {
+			lv = map[string]interface{}{"vg": f[0], "name": f[1], "active": false}
+			lvs[key] = lv
+		}
+		attr := f[2]
+		if t, ok := lvTypes[attr[0]]; ok {
+			lv["type"] = t
+		}
+		// The state, e.g. "a" for active, "s" for suspended and
+		// "I" for an invalid snapshot.
+		switch attr[4] {
+		case 'a':
+			lv["active"] = true
+		case 'I', 'S':
+			lv["health"] = "invalid"
+		}
+		if len(attr) > 8 {
+			if h, ok := lvHealth[attr[8]]; ok {
+				lv["health"] = h
+			}
+		}
+		if _, ok := lv["health"]; !ok {
+			lv["health"] = "ok"
+		}
+		if n, err := strconv.ParseUint(f[3], 10, 64); err == nil {
+			lv["size"] = n
+		}
+		if f[4] != "" {
+			lv["pool"] = f[4]
+		}
+		for i, k := range [...]string{"data_usage", "metadata_usage"} {
+			if f[5+i] == "" {
+				continue
+			}
+			r, err := metrics.ParseRatio(f[5+i])
+			if err != nil {
+				return fmt.Errorf("lvm: unexpected lvs output %q", line)
+			}
+			lv[k] = r
+		}
+	}
+	return nil
+}
+
+// lvStatus returns the status of the logical volume lv. The usage
+// only matters for the thin pools and the snapshots; a thin volume
+// is as full as the filesystem on it.
+func lvStatus(lv map[string]interface{}) string {
+	status := policy.StatusOK
+	if lv["suspended"] == true {
+		status = policy.StatusWarning
+	}
+	if h, ok := lv["health"].(string); ok {
+		if s, ok := healthStatus[h]; ok {
+			status = policy.Worse(status, s)
+		}
+	}
+	if t := lv["type"]; t != "thin-pool" && t != "snapshot" {
+		return status
+	}
+	for _, k := range [...]string{"data_usage", "metadata_usage"} {
+		r, ok := lv[k].(float64)
+		switch {
+		case !ok:
+		case r >= UsageCritical:
+			status = policy.StatusCritical
+		case r >= UsageWarning:
+			status = policy.Worse(status, policy.StatusWarning)
+		}
+	}
+	return status
+}
+
+// parseVGs parses the output of vgs and returns the volume groups, e.g.
+//
+//	vg0|wz--n-|499826819072|21474836480|2
+func parseVGs(out string) (map[string]map[string]interface{}, error) {
+	vgs := make(map[string]map[string]interface{})
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		f := strings.Split(line, "|")
+		if len(f) != 5 || len(f[1]) < 4 {
+			return nil, fmt.Errorf("lvm: unexpected vgs output %q", line)
+		}
+		size, err1 := strconv.ParseUint(f[2], 10, 64)
+		free, err2 := strconv.ParseUint(f[3], 10, 64)
+		pvs, err3 := strconv.Atoi(f[4])
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("lvm: unexpected vgs output %q", line)
+		}
+		vg := map[string]interface{}{
+			"size":             size,
+			"free":             free,
+			"physical_volumes": pvs,
+			"status":           policy.StatusOK,
+		}
+		// A partial volume group is missing physical volumes.
+		if f[1][3] == 'p' {
+			vg["status"] = policy.StatusCritical
+		}
+		vgs[f[0]] = vg
+	}
+	return vgs, nil
+}
+
+// readString returns the trimmed content of the named file in dir,
+// or "" if it can't be read.
+func readString(dir, name string) string {
+	b, err := host.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
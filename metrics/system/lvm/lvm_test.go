@@ -0,0 +1,134 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package lvm
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/policy"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Data
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			want: Data{
+				"volume_groups": map[string]map[string]interface{}{
+					"vg0": {"size": uint64(499826819072), "free": uint64(468151435264), "physical_volumes": 1, "logical_volumes": 3, "status": policy.StatusWarning},
+				},
+				"logical_volumes": map[string]map[string]interface{}{
+					"vg0/root":   {"vg": "vg0", "name": "root", "device": "dm-0", "active": true, "suspended": false, "size": uint64(21474836480), "type": "linear", "health": "ok", "status": policy.StatusOK},
+					"vg0/snap":   {"vg": "vg0", "name": "snap", "device": "dm-3", "active": true, "suspended": false, "size": uint64(5368709120), "type": "snapshot", "health": "ok", "data_usage": 0.872, "status": policy.StatusWarning},
+					"vg0/swap_1": {"vg": "vg0", "name": "swap_1", "device": "dm-1", "active": true, "suspended": false, "size": uint64(4294967296), "type": "linear", "health": "ok", "status": policy.StatusOK},
+				},
+				"lvm_status": policy.StatusWarning,
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			want: Data{
+				"volume_groups": map[string]map[string]interface{}{
+					"data":      {"size": uint64(499826819072), "free": uint64(64424509440), "physical_volumes": 2, "logical_volumes": 5, "status": policy.StatusCritical},
+					"ubuntu-vg": {"size": uint64(510363451392), "free": uint64(403726729216), "physical_volumes": 1, "logical_volumes": 1, "status": policy.StatusOK},
+				},
+				"logical_volumes": map[string]map[string]interface{}{
+					"ubuntu-vg/ubuntu-lv": {"vg": "ubuntu-vg", "name": "ubuntu-lv", "device": "dm-0", "active": true, "suspended": false, "size": uint64(107374182400), "type": "linear", "health": "ok", "status": policy.StatusOK},
+					"data/pool0":          {"vg": "data", "name": "pool0", "device": "dm-3", "active": true, "suspended": false, "size": uint64(214748364800), "type": "thin-pool", "health": "ok", "data_usage": 0.9642, "metadata_usage": 0.1208, "status": policy.StatusCritical},
+					"data/vm1":            {"vg": "data", "name": "vm1", "device": "dm-5", "active": true, "suspended": false, "size": uint64(53687091200), "type": "thin", "health": "ok", "pool": "pool0", "data_usage": 0.8, "status": policy.StatusOK},
+					"data/vm2":            {"vg": "data", "name": "vm2", "device": "dm-6", "active": true, "suspended": false, "size": uint64(107374182400), "type": "thin", "health": "ok", "pool": "pool0", "data_usage": 0.9501, "status": policy.StatusOK},
+					"data/backup":         {"vg": "data", "name": "backup", "device": "dm-7", "active": true, "suspended": true, "size": uint64(10737418240), "type": "linear", "health": "ok", "status": policy.StatusWarning},
+					"data/old":            {"vg": "data", "name": "old", "active": false, "size": uint64(1073741824), "type": "linear", "health": "ok", "status": policy.StatusOK},
+				},
+				"lvm_status": policy.StatusCritical,
+			},
+		},
+		{
+			// Without the LVM tools.
+			fixture: "alpine-3.18",
+			want: Data{
+				"volume_groups": map[string]map[string]interface{}{
+					"vg0": {"logical_volumes": 2, "status": policy.StatusOK},
+				},
+				"logical_volumes": map[string]map[string]interface{}{
+					"vg0/lv_root": {"vg": "vg0", "name": "lv_root", "device": "dm-0", "active": true, "suspended": false, "size": uint64(18253611008), "status": policy.StatusOK},
+					"vg0/lv_swap": {"vg": "vg0", "name": "lv_swap", "device": "dm-1", "active": true, "suspended": false, "size": uint64(2147483648), "status": policy.StatusOK},
+				},
+				"lvm_status": policy.StatusOK,
+			},
+		},
+	}
+	// The fixtures have different outputs of the same commands.
+	defer func(ttl time.Duration) { CommandTTL = ttl }(CommandTTL)
+	CommandTTL = 0
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: CollectData() error = %v", tt.fixture, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: CollectData() = %v, want %v", tt.fixture, got, tt.want)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	defer func(ttl time.Duration) { CommandTTL = ttl }(CommandTTL)
+	CommandTTL = time.Hour
+	outputs.m = make(map[string]output) // of the other tests
+	restore, err := host.Fixture(filepath.Join("testdata", "ubuntu-14.04"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+	ctx := context.Background()
+	args := []string{"--noheadings", "--nosuffix", "--units", "b", "--separator", "|", "-o", "vg_name,vg_attr,vg_size,vg_free,pv_count"}
+	want, err := command(ctx, "vgs", args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The output is reused, so vgs isn't run again.
+	host.Exec = host.Transcript{}
+	if out, err := command(ctx, "vgs", args...); err != nil || string(out) != string(want) {
+		t.Errorf("got %q, %v; want the output of the first run %q", out, err, want)
+	}
+	CommandTTL = 0
+	if _, err := command(ctx, "vgs", args...); err == nil {
+		t.Error("want vgs run again once the output is stale")
+	}
+}
+
+func TestSplitName(t *testing.T) {
+	tests := []struct {
+		name, vg, lv string
+	}{
+		{"vg0-root", "vg0", "root"},
+		{"ubuntu--vg-ubuntu--lv", "ubuntu-vg", "ubuntu-lv"},
+		{"vg0-lv--", "vg0", "lv-"},
+		{"luks", "", ""},
+	}
+	for _, tt := range tests {
+		vg, lv := splitName(tt.name)
+		if vg != tt.vg || lv != tt.lv {
+			t.Errorf("splitName(%q) = %q, %q, want %q, %q", tt.name, vg, lv, tt.vg, tt.lv)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+// Package pressure provides the pressure stall information (PSI)
+// of the CPU, memory and IO, and the paging and OOM kill counters.
+//
+// Unlike the used and free memory, which count the page cache,
+// PSI tells the share of time in which the tasks were stalled
+// waiting for a resource, i.e. whether the system is suffering.
+// It needs Linux 4.20 or later built with CONFIG_PSI; without it
+// only the counters from /proc/vmstat are collected.
+package pressure
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	metrics.Register(metrics.NewCollector("pressure", "pressure stall information and paging counters", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
+// Data represents the pressure data.
+type Data map[string]interface{}
+
+// resources are the resources whose pressure is reported
+// in the files of the same name under /proc/pressure.
+var resources = []string{"cpu", "memory", "io"}
+
+// CollectData collects the data and returns an error if any.
+func CollectData(ctx context.Context) (Data, error) {
+	d := make(Data)
+	p := make(map[string]interface{})
+	for _, r := range resources {
+		// The files don't exist without PSI, and can't
+		// be read when it is disabled with psi=0.
+		b, err := host.ReadFile("/proc/pressure/" + r)
+		if err != nil {
+			continue
+		}
+		m, err := parsePressure(string(b))
+		if err != nil {
+			return d, fmt.Errorf("pressure: %s: %v", r, err)
+		}
+		p[r] = m
+	}
+	if len(p) > 0 {
+		d["pressure"] = p
+	}
+
+	v, err := vmstat()
+	if err != nil {
+		return d, err
+	}
+	d["vmstat"] = v
+	return d, nil
+}
+
+// parsePressure parses the content of a file in /proc/pressure.
+// The averages are converted to ratios and the total stall time,
+// which is in microseconds, to seconds.
+func parsePressure(s string) (map[string]interface{}, error) {
+	// some avg10=0.12 avg60=0.05 avg300=0.01 total=1234567
+	// full avg10=0.00 avg60=0.00 avg300=0.00 total=23456
+	//
+	// The full line is missing for the CPU before Linux 5.13.
+	p := make(map[string]interface{})
+	for _, line := range strings.Split(s, "\n") {
+		a := strings.Fields(line)
+		if len(a) == 0 {
+			continue
+		}
+		if a[0] != "some" && a[0] != "full" {
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+		m := make(map[string]float64)
+		for _, f := range a[1:] {
+			kv := strings.SplitN(f, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("unexpected field %q", f)
+			}
+			x, err := strconv.ParseFloat(kv[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", kv[0], kv[1])
+			}
+			if kv[0] == "total" {
+				m[kv[0]] = x / 1e6
+				continue
+			}
+			m[kv[0]] = metrics.Ratio(x)
+		}
+		p[a[0]] = m
+	}
+	return p, nil
+}
+
+// vmstatKeys maps the counters of /proc/vmstat to the keys of the data.
+// The counters of the same key are added, e.g. the pages scanned by
+// kswapd and directly by the allocating tasks.
+var vmstatKeys = map[string]string{
+	"pgpgin":         "paged_in",
+	"pgpgout":        "paged_out",
+	"pswpin":         "swapped_in",
+	"pswpout":        "swapped_out",
+	"pgfault":        "page_faults",
+	"pgmajfault":     "major_page_faults",
+	"pgscan_kswapd":  "pages_scanned",
+	"pgscan_direct":  "pages_scanned",
+	"pgsteal_kswapd": "pages_reclaimed",
+	"pgsteal_direct": "pages_reclaimed",
+	"oom_kill":       "oom_kills",
+}
+
+// vmstat returns the paging and OOM kill counters of /proc/vmstat.
+// The paged in and out data is in bytes, and the others are counts
+// of pages or events.
+func vmstat() (map[string]uint64, error) {
+	f, err := host.Open("/proc/vmstat")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := make(map[string]uint64)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// pgmajfault 511
+		a := strings.Fields(s.Text())
+		if len(a) != 2 {
+			continue
+		}
+		k, ok := vmstatKeys[a[0]]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(a[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("pressure: invalid %s %q", a[0], a[1])
+		}
+		// pgpgin and pgpgout are in KiB.
+		if a[0] == "pgpgin" || a[0] == "pgpgout" {
+			n <<= 10
+		}
+		m[k] += n
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package pressure
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Data
+	}{
+		{
+			// Linux 3.13, without PSI and the OOM kill counter
+			fixture: "ubuntu-14.04",
+			want: Data{
+				"vmstat": map[string]uint64{
+					"paged_in":          5123456 << 10,
+					"paged_out":         9876543 << 10,
+					"swapped_in":        120,
+					"swapped_out":       3456,
+					"page_faults":       123456789,
+					"major_page_faults": 34567,
+				},
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			want: Data{
+				"pressure": map[string]interface{}{
+					"cpu": map[string]interface{}{
+						"some": map[string]float64{"avg10": 0.0611, "avg60": 0.0389, "avg300": 0.0244, "total": 27.851878},
+						"full": map[string]float64{"avg10": 0, "avg60": 0, "avg300": 0, "total": 0},
+					},
+					"memory": map[string]interface{}{
+						"some": map[string]float64{"avg10": 0.125, "avg60": 0.042, "avg300": 0.0105, "total": 9.876543},
+						"full": map[string]float64{"avg10": 0.0825, "avg60": 0.021, "avg300": 0.005, "total": 4.56789},
+					},
+					"io": map[string]interface{}{
+						"some": map[string]float64{"avg10": 0, "avg60": 0, "avg300": 0, "total": 2.48931},
+						"full": map[string]float64{"avg10": 0, "avg60": 0, "avg300": 0, "total": 2.04966},
+					},
+				},
+				"vmstat": map[string]uint64{
+					"paged_in":          710270 << 10,
+					"paged_out":         289336 << 10,
+					"swapped_in":        0,
+					"swapped_out":       0,
+					"page_faults":       9143051,
+					"major_page_faults": 511,
+					"pages_scanned":     1545,
+					"pages_reclaimed":   1230,
+					"oom_kills":         2,
+				},
+			},
+		},
+		{
+			// the CPU has no full line before Linux 5.13
+			fixture: "alpine-3.18",
+			want: Data{
+				"pressure": map[string]interface{}{
+					"cpu": map[string]interface{}{
+						"some": map[string]float64{"avg10": 0.005, "avg60": 0.0025, "avg300": 0.001, "total": 0.123456},
+					},
+				},
+				"vmstat": map[string]uint64{
+					"paged_in":          20480 << 10,
+					"paged_out":         4096 << 10,
+					"swapped_in":        0,
+					"swapped_out":       0,
+					"page_faults":       81234,
+					"major_page_faults": 12,
+					"pages_scanned":     0,
+					"pages_reclaimed":   0,
+					"oom_kills":         0,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, tt.want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.fixture, d, tt.want)
+		}
+	}
+}
+
+func TestParsePressureUnexpected(t *testing.T) {
+	for _, s := range []string{
+		"partial avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+		"some avg10 avg60=0.00\n",
+		"some avg10=abc\n",
+	} {
+		if _, err := parsePressure(s); err == nil {
+			t.Errorf("want error for %q", s)
+		}
+	}
+}
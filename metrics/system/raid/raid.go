@@ -0,0 +1,288 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+// Package raid provides the state of the Linux software RAID
+// arrays in /proc/mdstat, i.e. their failed and missing members
+// and the progress of their resync or recovery.
+//
+// Each array has a status; "ok", "warning" when it is degraded
+// but still has all the data, and "critical" when it is inactive
+// or has lost more members than it can tolerate. The worst of
+// them is the raid_status.
+package raid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"github.com/codeignition/recon/policy"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	metrics.Register(metrics.NewCollector("raid", "state of the software RAID arrays in /proc/mdstat", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
+// Data represents the RAID data.
+type Data map[string]interface{}
+
+// CollectData collects the data and returns an error if any.
+// A machine without the md driver has no arrays, which isn't
+// an error.
+func CollectData(ctx context.Context) (Data, error) {
+	b, err := host.ReadFile("/proc/mdstat")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	arrays, err := parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+	status := policy.StatusOK
+	for _, a := range arrays {
+		status = policy.Worse(status, a["status"].(string))
+	}
+	return Data{
+		"raid_arrays": arrays,
+		"raid_status": status,
+	}, nil
+}
+
+// parse parses the content of /proc/mdstat and returns
+// the arrays keyed by their name.
+func parse(s string) (map[string]map[string]interface{}, error) {
+	// Personalities : [raid1] [raid6] [raid5] [raid4]
+	// md0 : active raid1 sdb1[1] sda1[0]
+	//       976630464 blocks super 1.2 [2/2] [UU]
+	//       bitmap: 0/8 pages [0KB], 65536KB chunk
+	//
+	// md2 : active raid5 sdh1[3] sdg1[1] sdf1[0]
+	//       1953260544 blocks super 1.2 level 5, 512k chunk, algorithm 2 [3/2] [UU_]
+	//       [=>...................]  recovery =  8.5% (83064320/976630272) finish=87.3min speed=170536K/sec
+	//
+	// unused devices: <none>
+	arrays := make(map[string]map[string]interface{})
+	var a map[string]interface{} // current array
+	for _, line := range strings.Split(s, "\n") {
+		f := strings.Fields(line)
+		switch {
+		case len(f) == 0 || strings.HasPrefix(line, "Personalities") || strings.HasPrefix(line, "unused devices"):
+			a = nil
+		case len(f) >= 3 && f[1] == ":" && !strings.HasPrefix(line, " "):
+			var err error
+			a, err = parseArray(f[2:])
+			if err != nil {
+				return nil, fmt.Errorf("raid: %s: %v", f[0], err)
+			}
+			arrays[f[0]] = a
+		case a == nil:
+		case len(f) >= 2 && f[1] == "blocks":
+			if err := parseBlocks(a, f); err != nil {
+				return nil, fmt.Errorf("raid: %v", err)
+			}
+		case strings.Contains(line, "%") || strings.Contains(line, "=PENDING") || strings.Contains(line, "=DELAYED"):
+			if err := parseSync(a, line); err != nil {
+				return nil, fmt.Errorf("raid: %v", err)
+			}
+		}
+	}
+	for _, a := range arrays {
+		a["state"], a["status"] = health(a)
+	}
+	return arrays, nil
+}
+
+// parseArray parses the fields after the name of the array, i.e.
+// its state, level and members, e.g. active raid1 sdb1[1] sda1[0](F).
+func parseArray(f []string) (map[string]interface{}, error) {
+	a := map[string]interface{}{
+		"active":    f[0] == "active",
+		"read_only": false,
+	}
+	f = f[1:]
+	// (read-only) or (auto-read-only)
+	for len(f) > 0 && strings.HasPrefix(f[0], "(") {
+		if strings.Contains(f[0], "read-only") {
+			a["read_only"] = true
+		}
+		f = f[1:]
+	}
+	// An inactive array has no level.
+	if len(f) > 0 && !strings.Contains(f[0], "[") {
+		a["level"] = f[0]
+		f = f[1:]
+	}
+
+	var members []map[string]interface{}
+	var faulty, spare int
+	for _, dev := range f {
+		// sda1[0], sdb1[1](F), sdc1[2](S), sdd1[3](W)
+		i := strings.Index(dev, "[")
+		j := strings.Index(dev, "]")
+		if i == -1 || j < i {
+			return nil, fmt.Errorf("unexpected member %q", dev)
+		}
+		role, err := strconv.Atoi(dev[i+1 : j])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected member %q", dev)
+		}
+		m := map[string]interface{}{
+			"device": dev[:i],
+			"role":   role,
+			"state":  "active",
+		}
+		switch {
+		case strings.Contains(dev[j:], "(F)"):
+			m["state"] = "faulty"
+			faulty++
+		case strings.Contains(dev[j:], "(S)"):
+			m["state"] = "spare"
+			spare++
+		case strings.Contains(dev[j:], "(R)"):
+			m["state"] = "replacement"
+		}
+		if strings.Contains(dev[j:], "(W)") {
+			m["write_mostly"] = true
+		}
+		members = append(members, m)
+	}
+	a["members"] = members
+	a["faulty_members"] = faulty
+	a["spare_members"] = spare
+	return a, nil
+}
+
+// parseBlocks parses the line with the size of the array and,
+// for the levels with redundancy, the number of disks in the
+// array and in sync, e.g. 976630464 blocks super 1.2 [2/1] [U_].
+func parseBlocks(a map[string]interface{}, f []string) error {
+	n, err := strconv.ParseUint(f[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid size %q", f[0])
+	}
+	a["size"] = n << 10 // in 1 KiB blocks
+	for _, s := range f[2:] {
+		if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+			continue
+		}
+		s = strings.Trim(s, "[]")
+		if i := strings.Index(s, "/"); i != -1 {
+			disks, err1 := strconv.Atoi(s[:i])
+			inSync, err2 := strconv.Atoi(s[i+1:])
+			if err1 != nil || err2 != nil {
+				return fmt.Errorf("invalid disks [%s]", s)
+			}
+			a["raid_disks"] = disks
+			a["active_disks"] = inSync
+			continue
+		}
+		// [UU_]
+		a["map"] = s
+	}
+	return nil
+}
+
+// parseSync parses the progress of a resync, recovery, check or
+// reshape of the array, e.g.
+//
+//	[=>...................]  recovery =  8.5% (83064320/976630272) finish=87.3min speed=170536K/sec
+//	resync=PENDING
+func parseSync(a map[string]interface{}, line string) error {
+	f := strings.Fields(strings.Replace(line, "=", " = ", -1))
+	for i := 0; i+2 < len(f); i++ {
+		if f[i+1] != "=" {
+			continue
+		}
+		k, v := f[i], f[i+2]
+		switch k {
+		case "resync", "recovery", "check", "repair", "reshape":
+			a["sync_action"] = k
+			if v == "PENDING" || v == "DELAYED" {
+				a["sync_progress"] = 0.0
+				continue
+			}
+			r, err := metrics.ParseRatio(v)
+			if err != nil {
+				return err
+			}
+			a["sync_progress"] = r
+		case "finish":
+			min, err := strconv.ParseFloat(strings.TrimSuffix(v, "min"), 64)
+			if err != nil {
+				return fmt.Errorf("invalid finish %q", v)
+			}
+			a["sync_finish"] = min * 60
+		case "speed":
+			n, err := metrics.ParseBytes(strings.TrimSuffix(v, "/sec"))
+			if err != nil {
+				return err
+			}
+			a["sync_speed"] = n
+		}
+	}
+	if _, ok := a["sync_action"]; !ok {
+		return errors.New("unexpected sync progress " + strings.TrimSpace(line))
+	}
+	return nil
+}
+
+// tolerance maps the RAID levels to the number of
+// members they can lose without losing any data.
+var tolerance = map[string]int{
+	"raid4":  1,
+	"raid5":  1,
+	"raid6":  2,
+	"raid10": 1,
+}
+
+// health returns the state and the status of the array a.
+func health(a map[string]interface{}) (state, status string) {
+	if !a["active"].(bool) {
+		return "inactive", policy.StatusCritical
+	}
+	level, _ := a["level"].(string)
+	disks, ok := a["raid_disks"].(int)
+	if !ok {
+		// raid0 and linear have no redundancy
+		if a["faulty_members"].(int) > 0 {
+			return "failed", policy.StatusCritical
+		}
+		return "clean", policy.StatusOK
+	}
+	missing := disks - a["active_disks"].(int)
+	tol, ok := tolerance[level]
+	if level == "raid1" {
+		tol, ok = disks-1, true
+	}
+
+	state, status = "clean", policy.StatusOK
+	if missing > 0 {
+		state, status = "degraded", policy.StatusWarning
+		if ok && missing > tol {
+			state, status = "failed", policy.StatusCritical
+		}
+	}
+	if action, ok := a["sync_action"].(string); ok && status != policy.StatusCritical {
+		switch action {
+		case "recovery":
+			state = "recovering"
+		case "resync":
+			state = "resyncing"
+		case "reshape":
+			state = "reshaping"
+		case "check", "repair":
+			state = "checking"
+		}
+	}
+	return state, status
+}
@@ -0,0 +1,188 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package raid
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/policy"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Data
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			want: Data{
+				"raid_arrays": map[string]map[string]interface{}{
+					"md1": {
+						"active":    true,
+						"read_only": false,
+						"level":     "raid1",
+						"members": []map[string]interface{}{
+							{"device": "sdb2", "role": 2, "state": "active"},
+							{"device": "sda2", "role": 0, "state": "active"},
+						},
+						"faulty_members": 0,
+						"spare_members":  0,
+						"size":           uint64(998999195648),
+						"raid_disks":     2,
+						"active_disks":   1,
+						"map":            "U_",
+						"sync_action":    "recovery",
+						"sync_progress":  0.271,
+						"sync_finish":    3510.0,
+						"sync_speed":     uint64(207710208),
+						"state":          "recovering",
+						"status":         policy.StatusWarning,
+					},
+					"md0": {
+						"active":    true,
+						"read_only": false,
+						"level":     "raid1",
+						"members": []map[string]interface{}{
+							{"device": "sdb1", "role": 1, "state": "faulty"},
+							{"device": "sda1", "role": 0, "state": "active"},
+						},
+						"faulty_members": 1,
+						"spare_members":  0,
+						"size":           uint64(536543232),
+						"raid_disks":     2,
+						"active_disks":   1,
+						"map":            "U_",
+						"state":          "degraded",
+						"status":         policy.StatusWarning,
+					},
+				},
+				"raid_status": policy.StatusWarning,
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			want: Data{
+				"raid_arrays": map[string]map[string]interface{}{
+					"md127": {
+						"active":    false,
+						"read_only": false,
+						"members": []map[string]interface{}{
+							{"device": "sdk", "role": 2, "state": "spare"},
+						},
+						"faulty_members": 0,
+						"spare_members":  1,
+						"size":           uint64(2000263667712),
+						"state":          "inactive",
+						"status":         policy.StatusCritical,
+					},
+					"md3": {
+						"active":    true,
+						"read_only": false,
+						"level":     "raid10",
+						"members": []map[string]interface{}{
+							{"device": "sdh", "role": 3, "state": "active"},
+							{"device": "sdg", "role": 2, "state": "active"},
+							{"device": "sdf", "role": 1, "state": "active"},
+							{"device": "sde", "role": 0, "state": "active", "write_mostly": true},
+						},
+						"faulty_members": 0,
+						"spare_members":  0,
+						"size":           uint64(2000138797056),
+						"raid_disks":     4,
+						"active_disks":   4,
+						"map":            "UUUU",
+						"sync_action":    "check",
+						"sync_progress":  0.125,
+						"sync_finish":    7230.0,
+						"sync_speed":     uint64(241981440),
+						"state":          "checking",
+						"status":         policy.StatusOK,
+					},
+					"md2": {
+						"active":    true,
+						"read_only": false,
+						"level":     "raid0",
+						"members": []map[string]interface{}{
+							{"device": "sdj1", "role": 1, "state": "active"},
+							{"device": "sdi1", "role": 0, "state": "active"},
+						},
+						"faulty_members": 0,
+						"spare_members":  0,
+						"size":           uint64(2000138797056),
+						"state":          "clean",
+						"status":         policy.StatusOK,
+					},
+					"md1": {
+						"active":    true,
+						"read_only": false,
+						"level":     "raid5",
+						"members": []map[string]interface{}{
+							{"device": "sdd1", "role": 3, "state": "active"},
+							{"device": "sdc1", "role": 2, "state": "active"},
+							{"device": "sdb1", "role": 1, "state": "faulty"},
+							{"device": "sda1", "role": 0, "state": "faulty"},
+						},
+						"faulty_members": 2,
+						"spare_members":  0,
+						"size":           uint64(3000208195584),
+						"raid_disks":     4,
+						"active_disks":   2,
+						"map":            "__UU",
+						"state":          "failed",
+						"status":         policy.StatusCritical,
+					},
+					"md0": {
+						"active":    true,
+						"read_only": true,
+						"level":     "raid1",
+						"members": []map[string]interface{}{
+							{"device": "nvme1n1p1", "role": 1, "state": "active"},
+							{"device": "nvme0n1p1", "role": 0, "state": "active"},
+						},
+						"faulty_members": 0,
+						"spare_members":  0,
+						"size":           uint64(1073676288),
+						"raid_disks":     2,
+						"active_disks":   2,
+						"map":            "UU",
+						"sync_action":    "resync",
+						"sync_progress":  0.0,
+						"state":          "resyncing",
+						"status":         policy.StatusOK,
+					},
+				},
+				"raid_status": policy.StatusCritical,
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			want: Data{
+				"raid_arrays": map[string]map[string]interface{}{},
+				"raid_status": policy.StatusOK,
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: CollectData() error = %v", tt.fixture, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: CollectData() = %v, want %v", tt.fixture, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+// Package sensors provides the temperatures, fan speeds and voltages
+// of the hardware monitoring chips in /sys/class/hwmon and of the
+// thermal zones in /sys/class/thermal, along with their thresholds.
+//
+// The sensors are keyed by the name of their chip, or "thermal" for
+// the thermal zones, and by their label, e.g. "coretemp" and "Core 0".
+// The temperatures are in degrees Celsius, the voltages in volts and
+// the fan speeds in RPM. Each sensor has a status, "ok", "warning" or
+// "critical", found by comparing it with its thresholds, and the worst
+// of them is the sensors_status.
+package sensors
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"github.com/codeignition/recon/policy"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	metrics.Register(metrics.NewCollector("sensors", "temperatures, fan speeds and voltages from hwmon and thermal zones", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
+// Data represents the sensors data.
+type Data map[string]interface{}
+
+// CollectData collects the data and returns an error if any.
+// A machine without sensors, e.g. a virtual machine, has none
+// and isn't an error.
+func CollectData(ctx context.Context) (Data, error) {
+	chips := make(map[string]interface{})
+	if err := hwmon(chips); err != nil {
+		return nil, err
+	}
+	if err := thermal(chips); err != nil {
+		return nil, err
+	}
+
+	status := policy.StatusOK
+	for _, c := range chips {
+		for _, s := range c.(map[string]interface{}) {
+			status = policy.Worse(status, s.(map[string]interface{})["status"].(string))
+		}
+	}
+	return Data{
+		"sensors":        chips,
+		"sensors_status": status,
+	}, nil
+}
+
+// kinds are the kinds of the hwmon sensors, i.e. the prefixes of
+// their files, and the scale of their values.
+var kinds = []struct {
+	prefix, kind string
+	scale        float64
+}{
+	{"temp", "temperature", 1000}, // millidegrees Celsius
+	{"fan", "fan", 1},             // RPM
+	{"in", "voltage", 1000},       // millivolts
+}
+
+// hwmon adds the sensors of the hwmon chips to chips.
+func hwmon(chips map[string]interface{}) error {
+	const base = "/sys/class/hwmon"
+	devices, err := readDir(base)
+	if err != nil {
+		return err
+	}
+	for _, dev := range devices {
+		dir := filepath.Join(base, dev)
+		// Before Linux 3.15 or so, the files of most of
+		// the drivers are in the directory of the device.
+		if !host.Exists(filepath.Join(dir, "name")) {
+			dir = filepath.Join(dir, "device")
+		}
+		name, err := readString(dir, "name")
+		if err != nil || name == "" {
+			continue
+		}
+		files, err := readDir(dir)
+		if err != nil {
+			return err
+		}
+
+		sensors := make(map[string]interface{})
+		for _, f := range files {
+			for _, k := range kinds {
+				// temp1_input
+				if !strings.HasPrefix(f, k.prefix) || !strings.HasSuffix(f, "_input") {
+					continue
+				}
+				id := strings.TrimSuffix(f, "_input")
+				if _, err := strconv.Atoi(strings.TrimPrefix(id, k.prefix)); err != nil {
+					continue
+				}
+				s, ok := readSensor(dir, id, k.kind, k.scale)
+				if !ok {
+					continue
+				}
+				label, err := readString(dir, id+"_label")
+				if err != nil || label == "" {
+					label = id
+				}
+				if _, ok := sensors[label]; ok {
+					label += " " + id
+				}
+				sensors[label] = s
+			}
+		}
+		if len(sensors) == 0 {
+			continue
+		}
+		if _, ok := chips[name]; ok {
+			name += "-" + dev
+		}
+		chips[name] = sensors
+	}
+	return nil
+}
+
+// readSensor reads the value and the thresholds of the sensor id in
+// dir. It returns false if the sensor can't be read, e.g. because it
+// isn't connected.
+func readSensor(dir, id, kind string, scale float64) (map[string]interface{}, bool) {
+	v, ok := readValue(dir, id+"_input", scale)
+	if !ok {
+		return nil, false
+	}
+	s := map[string]interface{}{
+		"type":  kind,
+		"value": v,
+	}
+	for _, t := range [...]string{"min", "max", "crit", "lcrit"} {
+		if x, ok := readValue(dir, id+"_"+t, scale); ok {
+			s[thresholdKeys[t]] = x
+		}
+	}
+	// An alarm is raised by the chip itself.
+	alarm, _ := readValue(dir, id+"_alarm", 1)
+	s["status"] = status(s, alarm != 0)
+	return s, true
+}
+
+// thresholdKeys maps the suffixes of the hwmon threshold
+// files to the keys of the thresholds in the data.
+var thresholdKeys = map[string]string{
+	"min":   "min",
+	"max":   "max",
+	"crit":  "critical",
+	"lcrit": "critical_low",
+}
+
+// status returns the status of the sensor s by comparing its value
+// with its thresholds. A fan slower than its minimum is critical, as
+// it has most likely failed, while a temperature or a voltage beyond
+// its min or max is a warning, and critical beyond its critical limits.
+func status(s map[string]interface{}, alarm bool) string {
+	v := s["value"].(float64)
+	limit := func(k string) (float64, bool) {
+		x, ok := s[k].(float64)
+		return x, ok
+	}
+	st := policy.StatusOK
+	if alarm {
+		st = policy.StatusWarning
+	}
+	if x, ok := limit("critical"); ok && x > 0 && v >= x {
+		return policy.StatusCritical
+	}
+	// A limit of 0 is unset, e.g. on an unconnected voltage input
+	// reading 0, but a low one may be negative, e.g. -40°C.
+	if x, ok := limit("critical_low"); ok && x != 0 && v <= x {
+		return policy.StatusCritical
+	}
+	if x, ok := limit("min"); ok && x > 0 && v < x {
+		if s["type"] == "fan" {
+			return policy.StatusCritical
+		}
+		st = policy.Worse(st, policy.StatusWarning)
+	}
+	if x, ok := limit("max"); ok && x > 0 && v >= x {
+		st = policy.Worse(st, policy.StatusWarning)
+	}
+	return st
+}
+
+// thermal adds the temperatures of the thermal zones to chips.
+func thermal(chips map[string]interface{}) error {
+	const base = "/sys/class/thermal"
+	zones, err := readDir(base)
+	if err != nil {
+		return err
+	}
+	sensors := make(map[string]interface{})
+	for _, z := range zones {
+		if !strings.HasPrefix(z, "thermal_zone") {
+			continue
+		}
+		dir := filepath.Join(base, z)
+		s, ok := readZone(dir)
+		if !ok {
+			continue
+		}
+		label, err := readString(dir, "type")
+		if err != nil || label == "" {
+			label = z
+		}
+		if _, ok := sensors[label]; ok {
+			label += " " + z
+		}
+		sensors[label] = s
+	}
+	if len(sensors) > 0 {
+		chips["thermal"] = sensors
+	}
+	return nil
+}
+
+// readZone reads the temperature of the thermal zone in dir, and
+// its critical and hot trip points as the critical and max thresholds.
+func readZone(dir string) (map[string]interface{}, bool) {
+	v, ok := readValue(dir, "temp", 1000)
+	if !ok {
+		return nil, false
+	}
+	s := map[string]interface{}{
+		"type":  "temperature",
+		"value": v,
+	}
+	files, err := readDir(dir)
+	if err != nil {
+		return nil, false
+	}
+	for _, f := range files {
+		// trip_point_0_type and trip_point_0_temp
+		if !strings.HasPrefix(f, "trip_point_") || !strings.HasSuffix(f, "_type") {
+			continue
+		}
+		typ, err := readString(dir, f)
+		if err != nil {
+			continue
+		}
+		t, ok := readValue(dir, strings.TrimSuffix(f, "_type")+"_temp", 1000)
+		if !ok {
+			continue
+		}
+		switch typ {
+		case "critical":
+			s["critical"] = t
+		case "hot":
+			s["max"] = t
+		}
+	}
+	s["status"] = status(s, false)
+	return s, true
+}
+
+// readDir returns the sorted names in the directory, or nil if
+// it doesn't exist.
+func readDir(dir string) ([]string, error) {
+	names, err := host.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readString returns the trimmed content of the named file in dir.
+func readString(dir, name string) (string, error) {
+	b, err := host.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// readValue returns the number in the named file in dir divided by
+// scale. It returns false if the file doesn't exist or can't be read,
+// as happens with the sensors which aren't connected.
+func readValue(dir, name string, scale float64) (float64, bool) {
+	s, err := readString(dir, name)
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n / scale, true
+}
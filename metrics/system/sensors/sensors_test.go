@@ -0,0 +1,114 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package sensors
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/policy"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Data
+	}{
+		{
+			fixture: "ubuntu-22.04",
+			want: Data{
+				"sensors": map[string]interface{}{
+					"coretemp": map[string]interface{}{
+						"Package id 0": map[string]interface{}{"type": "temperature", "value": 52.0, "max": 84.0, "critical": 100.0, "status": policy.StatusOK},
+						"Core 0":       map[string]interface{}{"type": "temperature", "value": 88.0, "max": 84.0, "critical": 100.0, "status": policy.StatusWarning},
+					},
+					"nvme": map[string]interface{}{
+						"Composite": map[string]interface{}{"type": "temperature", "value": 38.85, "min": -273.15, "max": 81.85, "critical": 84.85, "status": policy.StatusOK},
+					},
+					"nvme-hwmon4": map[string]interface{}{
+						"Composite": map[string]interface{}{"type": "temperature", "value": 41.85, "max": 81.85, "critical": 84.85, "status": policy.StatusOK},
+					},
+					"nct6798": map[string]interface{}{
+						"fan1":    map[string]interface{}{"type": "fan", "value": 1180.0, "min": 300.0, "status": policy.StatusOK},
+						"CPU_FAN": map[string]interface{}{"type": "fan", "value": 0.0, "min": 300.0, "status": policy.StatusCritical},
+						"in0":     map[string]interface{}{"type": "voltage", "value": 1.032, "min": 0.0, "max": 1.744, "status": policy.StatusOK},
+						"+12V":    map[string]interface{}{"type": "voltage", "value": 12.096, "min": 10.8, "max": 13.2, "status": policy.StatusOK},
+					},
+					"thermal": map[string]interface{}{
+						"acpitz":       map[string]interface{}{"type": "temperature", "value": 27.8, "critical": 119.0, "status": policy.StatusOK},
+						"x86_pkg_temp": map[string]interface{}{"type": "temperature", "value": 52.0, "max": 95.0, "status": policy.StatusOK},
+					},
+				},
+				"sensors_status": policy.StatusCritical,
+			},
+		},
+		{
+			fixture: "ubuntu-14.04",
+			want: Data{
+				"sensors": map[string]interface{}{
+					"coretemp": map[string]interface{}{
+						"Core 0": map[string]interface{}{"type": "temperature", "value": 45.0, "max": 87.0, "critical": 105.0, "status": policy.StatusOK},
+						"Core 1": map[string]interface{}{"type": "temperature", "value": 106.0, "max": 87.0, "critical": 105.0, "status": policy.StatusCritical},
+					},
+					"thermal": map[string]interface{}{
+						"acpitz": map[string]interface{}{"type": "temperature", "value": 46.0, "critical": 98.0, "status": policy.StatusOK},
+					},
+				},
+				"sensors_status": policy.StatusCritical,
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			want: Data{
+				"sensors":        map[string]interface{}{},
+				"sensors_status": policy.StatusOK,
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, tt.want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.fixture, d, tt.want)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		s     map[string]interface{}
+		alarm bool
+		want  string
+	}{
+		{map[string]interface{}{"type": "temperature", "value": 40.0}, false, policy.StatusOK},
+		{map[string]interface{}{"type": "temperature", "value": 40.0}, true, policy.StatusWarning},
+		{map[string]interface{}{"type": "temperature", "value": 90.0, "max": 85.0}, false, policy.StatusWarning},
+		{map[string]interface{}{"type": "temperature", "value": 100.0, "max": 85.0, "critical": 100.0}, false, policy.StatusCritical},
+		{map[string]interface{}{"type": "voltage", "value": 10.0, "min": 10.8, "max": 13.2}, false, policy.StatusWarning},
+		{map[string]interface{}{"type": "voltage", "value": 9.0, "min": 10.8, "critical_low": 9.6}, false, policy.StatusCritical},
+		{map[string]interface{}{"type": "voltage", "value": 0.0, "min": 0.0, "critical_low": 0.0}, false, policy.StatusOK},
+		{map[string]interface{}{"type": "temperature", "value": -45.0, "critical_low": -40.0}, false, policy.StatusCritical},
+		{map[string]interface{}{"type": "fan", "value": 120.0, "min": 300.0}, false, policy.StatusCritical},
+		{map[string]interface{}{"type": "fan", "value": 0.0, "min": 0.0}, false, policy.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := status(tt.s, tt.alarm); got != tt.want {
+			t.Errorf("status(%v, %v) = %s; want %s", tt.s, tt.alarm, got, tt.want)
+		}
+	}
+}
@@ -8,10 +8,42 @@
 package system
 
 import (
+	"time"
+
+	"github.com/codeignition/recon/metrics"
 	"github.com/codeignition/recon/metrics/system/disk"
+	"github.com/codeignition/recon/metrics/system/lvm"
+	"github.com/codeignition/recon/metrics/system/pressure"
+	"github.com/codeignition/recon/metrics/system/raid"
+	"github.com/codeignition/recon/metrics/system/sensors"
 	"github.com/codeignition/recon/metrics/system/top"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("system", "system summary data from top, disk, pressure, sensors, raid and lvm", func(ctx context.Context) (interface{}, error) {
+		d, errs := CollectData(ctx, DefaultTimeout)
+		if len(errs) == 0 {
+			return d, nil
+		}
+		if len(d) == 0 {
+			return nil, errs
+		}
+		// The data of the other collectors isn't lost
+		// when e.g. the raid or lvm tools are missing.
+		d["errors"] = errs
+		return d, nil
+	}))
+}
+
+// DefaultTimeout is the default time each system
+// collector is given to collect its data.
+const DefaultTimeout = 10 * time.Second
+
+// collectors are the names of the collectors whose
+// data is merged into the system data.
+var collectors = []string{"top", "disk", "pressure", "sensors", "raid", "lvm"}
+
 // Data denotes system data
 type Data map[string]interface{}
 
@@ -23,18 +55,35 @@ func (d Data) Merge(a map[string]interface{}) {
 	}
 }
 
-// CollectData collects the data and returns an error if any.
-func CollectData() (Data, error) {
-	d := make(Data)
-	top, err := top.CollectData()
-	if err != nil {
-		return d, err
+// CollectData runs all the system collectors in parallel, each
+// with the given timeout, and merges the data of the ones that
+// succeeded. The failures, including the collectors which timed
+// out, are reported per collector.
+func CollectData(ctx context.Context, timeout time.Duration) (Data, metrics.Errors) {
+	var cs []metrics.Collector
+	for _, name := range collectors {
+		if c, ok := metrics.Lookup(name); ok {
+			cs = append(cs, c)
+		}
 	}
-	d.Merge(top)
-	disk, err := disk.CollectData()
-	if err != nil {
-		return d, err
+	a, errs := metrics.CollectAll(ctx, timeout, cs...)
+
+	d := make(Data)
+	for _, name := range collectors {
+		switch v := a[name].(type) {
+		case top.Data:
+			d.Merge(v)
+		case disk.Data:
+			d.Merge(v)
+		case pressure.Data:
+			d.Merge(v)
+		case sensors.Data:
+			d.Merge(v)
+		case raid.Data:
+			d.Merge(v)
+		case lvm.Data:
+			d.Merge(v)
+		}
 	}
-	d.Merge(disk)
-	return d, nil
+	return d, errs
 }
@@ -6,92 +6,202 @@
 
 // Package top provides selective data provided by the `top` command.
 // It collects system summary data, current running tasks, etc.
+//
+// It understands the summary area of procps 3.2 (Mem: 123k total),
+// procps-ng (KiB Mem, MiB Mem, ...) and busybox top.
 package top
 
 import (
 	"errors"
 	"fmt"
-	"os/exec"
+	"math"
 	"strconv"
 	"strings"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	metrics.Register(metrics.NewCollector("top", "load average, CPU, memory and swap summary from top", func(ctx context.Context) (interface{}, error) {
+		return CollectData(ctx)
+	}))
+}
+
 type Data map[string]interface{}
 
 // CollectData collects the data and returns
 // an error if any.
-func CollectData() (Data, error) {
-	d := make(Data)
-	count := 0 // count of the top output iteration
+func CollectData(ctx context.Context) (Data, error) {
 	iters := 2 // number of iterations that top command should collect
-	out, err := exec.Command("top", "-bn", strconv.Itoa(iters)).Output()
+	out, err := host.Command(ctx, "top", "-bn", strconv.Itoa(iters))
 	if err != nil {
-		return d, err
+		return make(Data), err
 	}
-	lines := strings.Split(string(out), "\n") // use a bufio.Scanner if memory problems arise
-	if len(lines) < 7 {
-		return d, errors.New("top: unexpected output")
-	}
-	base := 0
+	return parse(string(out))
+}
+
+// parse parses the summary area of the last iteration in the
+// output of top.
+func parse(out string) (Data, error) {
+	d := make(Data)
+	lines := strings.Split(out, "\n") // use a bufio.Scanner if memory problems arise
+
+	// The CPU usage of the first iteration is the average since boot,
+	// so only the summary area of the last iteration is parsed. It starts
+	// with the "top - " line, or with the "Mem:" line for busybox.
+	base := -1
 	for i, line := range lines {
-		if strings.HasPrefix(line, "top") {
-			count++
+		if strings.HasPrefix(line, "top - ") {
 			base = i
 		}
-		if count == iters {
-			switch i - base {
-			case 0:
-				err := d.parseUptimeLoadAvgData(line)
-				if err != nil {
-					return d, err
-				}
-			case 1:
-				// we are not collecting the tasks data for now
-				continue
-			case 2:
-				err := d.parseCPUData(line)
-				if err != nil {
-					return d, err
-				}
-			case 3:
-				err := d.parseMemoryData(line)
-				if err != nil {
-					return d, err
-				}
-			case 4:
-				err := d.parseSwapData(line)
-				if err != nil {
-					return d, err
-				}
+	}
+	if base == -1 {
+		for i, line := range lines {
+			if strings.HasPrefix(line, "Mem:") {
+				base = i
+			}
+		}
+	}
+	if base == -1 {
+		return d, errors.New("top: unexpected output")
+	}
+
+	for _, line := range lines[base:] {
+		f := strings.Fields(line)
+		// the summary area ends at the tasks column headings
+		if len(f) == 0 || f[0] == "PID" {
+			break
+		}
+		label := strings.TrimSpace(strings.SplitN(line, ":", 2)[0])
+		var err error
+		switch {
+		case strings.HasPrefix(line, "top - "):
+			err = d.parseUptimeLoadAvgData(line)
+		case strings.HasPrefix(line, "Load average:"):
+			err = d.parseLoadAvgData(line)
+		case strings.HasSuffix(label, "Cpu(s)") || label == "CPU":
+			err = d.parseCPUData(line)
+		case strings.HasSuffix(label, "Mem"):
+			err = d.parseMemoryData(line)
+		case strings.HasSuffix(label, "Swap"):
+			err = d.parseSwapData(line)
+		}
+		if err != nil {
+			return d, err
+		}
+	}
+	if _, ok := d["memory"]; !ok {
+		return d, errors.New("top: unable to find memory data")
+	}
+	if metrics.LegacyUnits {
+		return d, nil
+	}
+	return d, d.convertUnits()
+}
+
+// convertUnits converts the memory and swap data from KiB to bytes,
+// the CPU data from percentages to ratios and the uptime to seconds.
+func (d Data) convertUnits() error {
+	for _, k := range [...]string{"memory", "swap"} {
+		if m, ok := d[k].(Data); ok {
+			for key, v := range m {
+				m[key] = uint64(v.(int)) << 10
+			}
+		}
+	}
+	if m, ok := d["cpu"].(Data); ok {
+		for k, v := range m {
+			m[k] = metrics.Ratio(v.(float64))
+		}
+	}
+	if s, ok := d["uptime"].(string); ok {
+		secs, err := parseUptime(s)
+		if err != nil {
+			return err
+		}
+		d["uptime"] = secs
+	}
+	return nil
+}
+
+// parseUptime parses the uptime shown by top, e.g. "3 days,  1:02",
+// "1 day, 15 min" or "1:02", and returns it in seconds.
+func parseUptime(s string) (int, error) {
+	var secs int
+	for _, f := range strings.Split(s, ",") {
+		a := strings.Fields(f)
+		switch {
+		case len(a) == 1 && strings.Contains(a[0], ":"):
+			var h, m int
+			if _, err := fmt.Sscanf(a[0], "%d:%d", &h, &m); err != nil {
+				return 0, fmt.Errorf("top: unable to parse uptime %q", s)
+			}
+			secs += h*60*60 + m*60
+		case len(a) == 2:
+			n, err := strconv.Atoi(a[0])
+			if err != nil {
+				return 0, fmt.Errorf("top: unable to parse uptime %q", s)
+			}
+			switch a[1] {
+			case "day", "days":
+				secs += n * 24 * 60 * 60
+			case "min":
+				secs += n * 60
 			default:
-				break
+				return 0, fmt.Errorf("top: unable to parse uptime %q", s)
 			}
+		default:
+			return 0, fmt.Errorf("top: unable to parse uptime %q", s)
 		}
 	}
-	return d, nil
+	return secs, nil
 }
 
 func (d Data) parseUptimeLoadAvgData(s string) error {
 	a := strings.SplitN(s, "load average: ", 2)
-	b := strings.SplitN(a[0], "up", 2)
-	t := strings.SplitN(b[1], "users", 2)
-	c := strings.TrimSpace(t[0])
-	i := strings.LastIndex(c, ",")
-	if i == -1 {
+	if len(a) != 2 {
+		return errors.New("top: unable to find load average data")
+	}
+	b := strings.SplitN(a[0], " up ", 2)
+	if len(b) != 2 {
 		return errors.New("top: unable to parse uptime data")
 	}
-	d["uptime"] = c[:i]
-	l := strings.Split(a[1], ",")
-	if len(l) != 3 {
+	// 3 days,  1:02,  2 users,
+	// 15 min,  1 user,
+	c := b[1]
+	if i := strings.Index(c, "user"); i != -1 {
+		c = c[:i]
+		i = strings.LastIndex(c, ",")
+		if i == -1 {
+			return errors.New("top: unable to parse uptime data")
+		}
+		c = c[:i]
+	}
+	d["uptime"] = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(c), ","))
+	return d.parseLoadAvg(a[1])
+}
+
+// parseLoadAvgData parses the load average line of busybox top.
+func (d Data) parseLoadAvgData(s string) error {
+	return d.parseLoadAvg(strings.TrimPrefix(s, "Load average:"))
+}
+
+// parseLoadAvg parses the load averages in s which
+// are separated either by commas or by spaces.
+func (d Data) parseLoadAvg(s string) error {
+	l := strings.Fields(strings.Replace(s, ",", " ", -1))
+	if len(l) < 3 {
 		return errors.New("top: unexpected number of load averages found")
 	}
 	var f [3]float64
-	for i := range l {
-		x, err := strconv.ParseFloat(strings.TrimSpace(l[i]), 64)
-		f[i] = x
+	for i := range f {
+		x, err := strconv.ParseFloat(l[i], 64)
 		if err != nil {
 			return err
 		}
+		f[i] = x
 	}
 	d["load_average"] = Data{
 		"last_1_min":  f[0],
@@ -101,69 +211,136 @@ func (d Data) parseUptimeLoadAvgData(s string) error {
 	return nil
 }
 
+// cpuKeys maps the CPU state labels of the different
+// top versions to the keys of the CPU data.
+var cpuKeys = map[string]string{
+	"us":   "userspace",
+	"usr":  "userspace",
+	"sy":   "system",
+	"sys":  "system",
+	"id":   "idle",
+	"idle": "idle",
+	"wa":   "iowait",
+	"io":   "iowait",
+	"st":   "stolen",
+}
+
 func (d Data) parseCPUData(s string) error {
-	a := strings.TrimPrefix(s, "%Cpu(s): ")
-	a = strings.TrimPrefix(a, "Cpu(s): ") // some systems have the prefix Cpu(s): without the % sign
-	b := strings.Split(a, ",")
-	if len(b) != 8 {
+	// %Cpu(s):  2.3 us,  0.8 sy,  0.0 ni, 96.6 id,  0.2 wa,  0.0 hi,  0.1 si,  0.0 st
+	// Cpu(s):  2.3%us,  0.8%sy,  0.0%ni, 96.6%id,  0.2%wa,  0.0%hi,  0.1%si,  0.0%st
+	// CPU:   2% usr   1% sys   0% nic  96% idle   0% io   0% irq   0% sirq
+	a := strings.SplitN(s, ":", 2)
+	r := strings.NewReplacer(",", " ", "%", " ")
+	b := strings.Fields(r.Replace(a[1]))
+	if len(b) == 0 || len(b)%2 != 0 {
 		return errors.New("top: unknown number of CPU data")
 	}
-	var c [8]float64
-	for i := range b {
-		t := strings.TrimSpace(b[i])
-		x, err := strconv.ParseFloat(t[:len(t)-3], 64)
-		c[i] = x
+	c := make(Data)
+	for i := 0; i < len(b); i += 2 {
+		x, err := strconv.ParseFloat(b[i], 64)
 		if err != nil {
 			return err
 		}
+		if k, ok := cpuKeys[b[i+1]]; ok {
+			c[k] = x
+		}
 	}
-	d["cpu"] = Data{
-		"userspace": c[0],
-		"idle":      c[3],
-		"system":    c[1],
-		"iowait":    c[4],
-		"stolen":    c[7],
-	}
+	d["cpu"] = c
 	return nil
 }
 
-func (d Data) parseMemoryData(s string) error {
-	var total, used, free, buffers int
-	var err error
-	if strings.HasPrefix(s, "KiB Mem:") {
-		_, err = fmt.Sscanf(s, "KiB Mem:\t%d total,\t%d used,\t%d free,\t%d buffers", &total, &used, &free, &buffers)
-	} else if strings.HasPrefix(s, "Mem:") {
-		_, err = fmt.Sscanf(s, "Mem:\t%dk total,\t%dk used,\t%dk free,\t%dk buffers", &total, &used, &free, &buffers)
+// memKeys maps the memory labels of the different
+// top versions to the keys of the memory data.
+var memKeys = map[string]string{
+	"total":      "total",
+	"used":       "used",
+	"free":       "free",
+	"buffers":    "buffers",
+	"buff":       "buffers",
+	"shrd":       "shared",
+	"cached":     "cached",
+	"cached Mem": "cached",
+	"buff/cache": "buff_cache",
+	"avail Mem":  "available",
+}
+
+// parseSummary parses a memory or swap line of top and
+// returns the values in KiB mapped to their labels.
+func parseSummary(s string) (map[string]int, error) {
+	// KiB Mem:   8048832 total,  7731620 used,   317212 free,   355352 buffers
+	// KiB Swap:  8263676 total,   123456 used,  8140220 free.  4455240 cached Mem
+	// MiB Mem :  15921.0 total,   1234.5 free,   5678.9 used,   9007.6 buff/cache
+	// MiB Swap:   2048.0 total,   2048.0 free,      0.0 used.   9876.5 avail Mem
+	// Mem:   8048832k total,  7731620k used,   317212k free,   355352k buffers
+	// Mem: 1626872K used, 409748K free, 1172K shrd, 95940K buff, 1037264K cached
+	a := strings.SplitN(s, ":", 2)
+	if len(a) != 2 {
+		return nil, errors.New("missing values")
+	}
+	scale := 1.0
+	switch {
+	case strings.HasPrefix(a[0], "MiB"):
+		scale = 1 << 10
+	case strings.HasPrefix(a[0], "GiB"):
+		scale = 1 << 20
+	case strings.HasPrefix(a[0], "TiB"):
+		scale = 1 << 30
 	}
+
+	m := make(map[string]int)
+	// values are separated by commas, and sometimes by a period
+	fields := strings.FieldsFunc(strings.Replace(a[1], ". ", ", ", -1), func(r rune) bool { return r == ',' })
+	for _, field := range fields {
+		f := strings.Fields(field)
+		if len(f) < 2 {
+			return nil, fmt.Errorf("unexpected value %q", field)
+		}
+		v := strings.TrimRight(f[0], "kK")
+		x, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		m[strings.Join(f[1:], " ")] = int(math.Floor(x*scale + 0.5))
+	}
+	return m, nil
+}
+
+func (d Data) parseMemoryData(s string) error {
+	a, err := parseSummary(s)
 	if err != nil {
 		return fmt.Errorf("top: unable to parse memory data; %s", err)
 	}
-	d["memory"] = Data{
-		"total":   total,
-		"used":    used,
-		"free":    free,
-		"buffers": buffers,
+	mem := make(Data)
+	for k, v := range a {
+		if key, ok := memKeys[k]; ok {
+			mem[key] = v
+		}
+	}
+	// busybox doesn't report the total memory
+	if _, ok := mem["total"]; !ok {
+		mem["total"] = a["used"] + a["free"]
 	}
+	d["memory"] = mem
 	return nil
 }
 
 func (d Data) parseSwapData(s string) error {
-	var total, used, free, cached int
-	var err error
-	if strings.HasPrefix(s, "KiB Swap:") {
-		_, err = fmt.Sscanf(s, "KiB Swap:\t%d total,\t%d used,\t%d free.\t%d cached Mem", &total, &used, &free, &cached)
-	} else if strings.HasPrefix(s, "Swap:") {
-		_, err = fmt.Sscanf(s, "Swap:\t%dk total,\t%dk used,\t%dk free,\t%dk cached", &total, &used, &free, &cached)
-	}
+	a, err := parseSummary(s)
 	if err != nil {
 		return fmt.Errorf("top: unable to parse swap data; %s", err)
 	}
 	d["swap"] = Data{
-		"total": total,
-		"used":  used,
-		"free":  free,
+		"total": a["total"],
+		"used":  a["used"],
+		"free":  a["free"],
+	}
+	// The swap line holds the cached or available memory.
+	if mem, ok := d["memory"].(Data); ok {
+		for k, v := range a {
+			if k == "cached Mem" || k == "avail Mem" {
+				mem[memKeys[k]] = v
+			}
+		}
 	}
-	mem := d["memory"].(Data)
-	mem["cached"] = cached
 	return nil
 }
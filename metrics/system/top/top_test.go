@@ -0,0 +1,185 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package top
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeignition/recon/internal/host"
+	"github.com/codeignition/recon/metrics"
+	"golang.org/x/net/context"
+)
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Data
+	}{
+		{
+			fixture: "ubuntu-14.04",
+			want: Data{
+				"uptime": 3*24*60*60 + 62*60,
+				"load_average": Data{
+					"last_1_min":  0.19,
+					"last_5_min":  0.34,
+					"last_15_min": 0.40,
+				},
+				"cpu": Data{
+					"userspace": 0.031,
+					"system":    0.01,
+					"idle":      0.954,
+					"iowait":    0.003,
+					"stolen":    0.0,
+				},
+				"memory": Data{
+					"total":   uint64(8242003968),
+					"used":    uint64(7917428736),
+					"free":    uint64(324575232),
+					"buffers": uint64(363880448),
+					"cached":  uint64(4562227200),
+				},
+				"swap": Data{
+					"total": uint64(8462004224),
+					"used":  uint64(126418944),
+					"free":  uint64(8335585280),
+				},
+			},
+		},
+		{
+			fixture: "ubuntu-22.04",
+			want: Data{
+				"uptime": 15 * 60,
+				"load_average": Data{
+					"last_1_min":  1.48,
+					"last_5_min":  0.98,
+					"last_15_min": 0.47,
+				},
+				"cpu": Data{
+					"userspace": 0.02,
+					"system":    0.007,
+					"idle":      0.969,
+					"iowait":    0.001,
+					"stolen":    0.002,
+				},
+				"memory": Data{
+					"total":      uint64(16694378496),
+					"used":       uint64(5959267328),
+					"free":       uint64(1289958400),
+					"buff_cache": uint64(9445152768),
+					"available":  uint64(10351752192),
+				},
+				"swap": Data{
+					"total": uint64(2147483648),
+					"used":  uint64(0),
+					"free":  uint64(2147483648),
+				},
+			},
+		},
+		{
+			fixture: "alpine-3.18",
+			want: Data{
+				"load_average": Data{
+					"last_1_min":  0.07,
+					"last_5_min":  0.03,
+					"last_15_min": 0.01,
+				},
+				"cpu": Data{
+					"userspace": 0.01,
+					"system":    0.01,
+					"idle":      0.97,
+					"iowait":    0.0,
+				},
+				"memory": Data{
+					"total":   uint64(2085498880),
+					"used":    uint64(1665945600),
+					"free":    uint64(419553280),
+					"shared":  uint64(1200128),
+					"buffers": uint64(98242560),
+					"cached":  uint64(1062164480),
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		restore, err := host.Fixture(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := CollectData(context.Background())
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, tt.want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.fixture, d, tt.want)
+		}
+	}
+}
+
+func TestParseUnexpectedOutput(t *testing.T) {
+	for _, out := range []string{
+		"",
+		"top: failed tty get\n",
+		"top - 11:02:13 up 3 days,  1:02,  2 users,  load average: 0.21, 0.35\n",
+	} {
+		if _, err := parse(out); err == nil {
+			t.Errorf("want error for top output %q", out)
+		}
+	}
+}
+
+func TestParseUptime(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"3 days,  1:02", 3*24*60*60 + 62*60},
+		{"1 day, 15 min", 24*60*60 + 15*60},
+		{"15 min", 15 * 60},
+		{"1:02", 62 * 60},
+	}
+	for _, tt := range tests {
+		got, err := parseUptime(tt.s)
+		if err != nil {
+			t.Errorf("%q: %v", tt.s, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %d; want %d", tt.s, got, tt.want)
+		}
+	}
+	if _, err := parseUptime("3 fortnights"); err == nil {
+		t.Error("want error for unknown uptime unit")
+	}
+}
+
+func TestCollectDataLegacyUnits(t *testing.T) {
+	restore, err := host.Fixture(filepath.Join("testdata", "ubuntu-14.04"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+	metrics.LegacyUnits = true
+	defer func() { metrics.LegacyUnits = false }()
+
+	d, err := CollectData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d["uptime"], "3 days,  1:02"; got != want {
+		t.Errorf("got uptime %v; want %v", got, want)
+	}
+	if got, want := d["memory"].(Data)["total"], 8048832; got != want {
+		t.Errorf("got total memory %v; want %v", got, want)
+	}
+	if got, want := d["cpu"].(Data)["userspace"], 3.1; got != want {
+		t.Errorf("got userspace CPU %v; want %v", got, want)
+	}
+}
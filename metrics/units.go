@@ -0,0 +1,81 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LegacyUnits makes the collectors return the values as the strings
+// they used to, with the units embedded, e.g. "16303256 kB" and "42%".
+// It is meant for consumers which still parse the old shape and
+// must be set before collecting.
+var LegacyUnits bool
+
+// byteUnits maps the unit suffixes found in /proc and in the
+// output of commands to their multiples. A "k" means KiB
+// everywhere in /proc, and the others follow it.
+var byteUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tb":  1 << 40,
+	"tib": 1 << 40,
+}
+
+// ParseBytes parses a size such as "16303256 kB", "6144 KB"
+// or "512" and returns it in bytes.
+func ParseBytes(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i == -1 {
+		i = len(s)
+	}
+	unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok || i == 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if strings.Contains(s[:i], ".") {
+		f, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid size %q", s)
+		}
+		return uint64(f*float64(unit) + 0.5), nil
+	}
+	n, err := strconv.ParseUint(s[:i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * unit, nil
+}
+
+// ParseRatio parses a percentage such as "42%" or "3.1"
+// and returns it as a ratio, 0.42 and 0.031 respectively.
+func ParseRatio(s string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	return Ratio(f), nil
+}
+
+// Ratio returns the percentage p as a ratio. It is rounded to
+// 4 decimal places, so that 3.1% is 0.031 and not 0.031000000000000003.
+func Ratio(p float64) float64 {
+	return float64(int64(p*100+0.5)) / 10000
+}
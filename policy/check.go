@@ -0,0 +1,81 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultCheckTimeout is the time given to an attempt of a check
+// whose policy has no "timeout" key. It doesn't depend on the interval,
+// so that a check run hourly doesn't hang for an hour.
+const DefaultCheckTimeout = 10 * time.Second
+
+// CheckFunc is an attempt of a check, such as a TCP dial.
+// It must give up and return when ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+// CheckOptions are the options of the check-style handlers, such as
+// TCP, which probe a service, so that they all time out and retry the
+// same way. They are parsed from the keys of the policy:
+//
+//	timeout         the time given to each attempt, 10s by default; see Policy.Duration
+//	retries         the attempts made after the first fails, 0 by default
+//	retry_interval  the time waited between the attempts, 1s by default
+type CheckOptions struct {
+	Timeout       time.Duration
+	Retries       int
+	RetryInterval time.Duration
+}
+
+// ParseCheckOptions parses the check options in the keys of the policy p.
+func ParseCheckOptions(p Policy) (CheckOptions, error) {
+	o := CheckOptions{
+		Timeout:       DefaultCheckTimeout,
+		RetryInterval: time.Second,
+	}
+	var err error
+	if o.Timeout, err = p.Duration("timeout", o.Timeout); err != nil {
+		return o, err
+	}
+	if o.RetryInterval, err = p.Duration("retry_interval", o.RetryInterval); err != nil {
+		return o, err
+	}
+	if s, ok := p.M["retries"]; ok {
+		if o.Retries, err = strconv.Atoi(s); err != nil {
+			return o, err
+		}
+		if o.Retries < 0 {
+			return o, errors.New("retries can't be negative")
+		}
+	}
+	return o, nil
+}
+
+// Run runs the check f, giving each attempt the timeout, until it
+// succeeds or all the retries fail. It returns the number of attempts
+// made and the error of the last one.
+func (o CheckOptions) Run(ctx context.Context, f CheckFunc) (attempts int, err error) {
+	for {
+		attempts++
+		actx, cancel := context.WithTimeout(ctx, o.Timeout)
+		err = f(actx)
+		cancel()
+		if err == nil || attempts > o.Retries {
+			return attempts, err
+		}
+		t := time.NewTimer(o.RetryInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return attempts, err
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestParseCheckOptions(t *testing.T) {
+	o, err := ParseCheckOptions(Policy{M: map[string]string{"interval": "1h"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (CheckOptions{Timeout: DefaultCheckTimeout, RetryInterval: time.Second}); o != want {
+		t.Errorf("got %+v; want %+v", o, want)
+	}
+	o, err = ParseCheckOptions(Policy{M: map[string]string{"timeout": "3s", "retries": "2", "retry_interval": "500ms"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (CheckOptions{Timeout: 3 * time.Second, Retries: 2, RetryInterval: 500 * time.Millisecond}); o != want {
+		t.Errorf("got %+v; want %+v", o, want)
+	}
+	for _, m := range []map[string]string{
+		{"timeout": "0s"},
+		{"timeout": "soon"},
+		{"retries": "-1"},
+		{"retries": "many"},
+		{"retry_interval": "-1s"},
+	} {
+		if _, err := ParseCheckOptions(Policy{M: m}); err == nil {
+			t.Errorf("ParseCheckOptions(%v): want an error", m)
+		}
+	}
+}
+
+func TestCheckOptionsRun(t *testing.T) {
+	o := CheckOptions{Timeout: 10 * time.Millisecond, Retries: 2, RetryInterval: time.Millisecond}
+	fail := errors.New("connection refused")
+
+	tests := []struct {
+		failures int // before the check succeeds
+		attempts int
+		err      error
+	}{
+		{0, 1, nil},
+		{2, 3, nil},
+		{5, 3, fail},
+	}
+	for _, tt := range tests {
+		n := 0
+		attempts, err := o.Run(context.Background(), func(ctx context.Context) error {
+			n++
+			if n <= tt.failures {
+				return fail
+			}
+			return nil
+		})
+		if attempts != tt.attempts || err != tt.err {
+			t.Errorf("%d failures: got %d attempts, %v; want %d, %v", tt.failures, attempts, err, tt.attempts, tt.err)
+		}
+	}
+
+	// An attempt which hangs times out.
+	start := time.Now()
+	attempts, err := o.Run(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if attempts != 3 || err != context.DeadlineExceeded {
+		t.Errorf("got %d attempts, %v; want 3, %v", attempts, err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("took %v; want the attempts to time out", d)
+	}
+}
@@ -4,12 +4,87 @@
 
 package policy
 
-import "time"
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EventVersion is the version of the schema of the events. It is
+// incremented whenever the fields of Event change incompatibly.
+//
+// Version 1 had only the Time, PolicyName, AgentUID and Data.
+const EventVersion = 2
+
+// The statuses of the events, from the best to the worst.
+const (
+	StatusOK       = "ok"
+	StatusUnknown  = "unknown" // e.g. the data couldn't be collected
+	StatusWarning  = "warning"
+	StatusCritical = "critical"
+)
+
+var statusRank = map[string]int{
+	StatusOK:       0,
+	StatusUnknown:  1,
+	StatusWarning:  2,
+	StatusCritical: 3,
+}
+
+// Worse returns the worse of the statuses a and b. An unrecognized
+// status, e.g. misspelled by a collector, is taken as StatusUnknown.
+func Worse(a, b string) string {
+	a, b = knownStatus(a), knownStatus(b)
+	if statusRank[b] > statusRank[a] {
+		return b
+	}
+	return a
+}
+
+// knownStatus returns s if it is one of the statuses, and
+// StatusUnknown otherwise.
+func knownStatus(s string) string {
+	if _, ok := statusRank[s]; ok {
+		return s
+	}
+	return StatusUnknown
+}
 
 // Event data that will be sent by policy handlers
 type Event struct {
+	Version    int    `bson:"version"` // Version is the EventVersion of the event
+	Seq        uint64 `bson:"seq"`     // Seq increases with each event made since the agent started
 	Time       time.Time
-	PolicyName string      `bson:"policy_name"`
-	AgentUID   string      `bson:"agent_uid"`
-	Data       interface{} // Data may include status, stats, etc.
+	PolicyName string            `bson:"policy_name"`
+	PolicyType string            `bson:"policy_type"`
+	AgentUID   string            `bson:"agent_uid"`
+	Status     string            `bson:"status"`           // Status is one of StatusOK, StatusWarning, StatusCritical and StatusUnknown
+	Labels     map[string]string `bson:"labels,omitempty"` // Labels are those of the policy and the agent
+	Data       interface{}       // Data may include status, stats, etc.
+	Suppressed bool              `bson:"suppressed"` // Suppressed is set if the policy was silenced
+}
+
+// seq is the sequence number of the last event made.
+var seq uint64
+
+// NewEvent returns an event of the policy p at t, with the
+// given status and data, and a copy of the labels of p.
+func (p Policy) NewEvent(t time.Time, status string, data interface{}) Event {
+	var labels map[string]string
+	if len(p.Labels) > 0 {
+		labels = make(map[string]string, len(p.Labels))
+		for k, v := range p.Labels {
+			labels[k] = v
+		}
+	}
+	return Event{
+		Version:    EventVersion,
+		Seq:        atomic.AddUint64(&seq, 1),
+		Time:       t,
+		PolicyName: p.Name,
+		PolicyType: p.Type,
+		AgentUID:   p.AgentUID,
+		Status:     status,
+		Labels:     labels,
+		Data:       data,
+	}
 }
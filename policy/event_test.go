@@ -0,0 +1,56 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWorse(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{StatusOK, StatusOK, StatusOK},
+		{StatusOK, StatusWarning, StatusWarning},
+		{StatusCritical, StatusWarning, StatusCritical},
+		{StatusUnknown, StatusOK, StatusUnknown},
+		{StatusUnknown, StatusWarning, StatusWarning},
+		{StatusOK, "degraded", StatusUnknown},
+		{"degraded", StatusOK, StatusUnknown},
+		{"degraded", StatusWarning, StatusWarning},
+		{StatusCritical, "degraded", StatusCritical},
+	}
+	for _, tt := range tests {
+		if got := Worse(tt.a, tt.b); got != tt.want {
+			t.Errorf("Worse(%q, %q) = %q; want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNewEvent(t *testing.T) {
+	p := Policy{
+		Name:     "web",
+		AgentUID: "13fcdf794886",
+		Type:     "tcp",
+		Labels:   map[string]string{"team": "payments"},
+	}
+	now := time.Now()
+	e := p.NewEvent(now, StatusCritical, "down")
+	if e.Version != EventVersion || e.PolicyName != "web" || e.PolicyType != "tcp" ||
+		e.AgentUID != p.AgentUID || e.Status != StatusCritical || e.Data != "down" || !e.Time.Equal(now) {
+		t.Errorf("got %+v; want the fields of the policy", e)
+	}
+	if e.Labels["team"] != "payments" {
+		t.Errorf("got labels %v; want those of the policy", e.Labels)
+	}
+	e.Labels["team"] = "search"
+	if p.Labels["team"] != "payments" {
+		t.Error("want the labels of the event to be a copy")
+	}
+	if next := p.NewEvent(now, StatusOK, nil); next.Seq <= e.Seq {
+		t.Errorf("got seq %d after %d; want it to increase", next.Seq, e.Seq)
+	}
+}
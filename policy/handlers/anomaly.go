@@ -0,0 +1,228 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codeignition/recon/aggregate"
+	"github.com/codeignition/recon/anomaly"
+	"github.com/codeignition/recon/metrics/system"
+	"github.com/codeignition/recon/policy"
+	"golang.org/x/net/context"
+)
+
+// StateDir is the directory in which the handlers keep their state
+// across restarts, such as the baselines of the anomaly policies.
+// The state isn't kept if it is empty.
+var StateDir string
+
+// anomalySaveInterval is the interval at which
+// the baselines of an anomaly policy are saved.
+const anomalySaveInterval = time.Minute
+
+// Anomaly collects the system data on the schedule of the policy, and
+// sends an event when any of its numbers deviates from its baseline by
+// more than "threshold" standard deviations, 3 by default. The metrics
+// are named by the keys they are nested in, joined by dots, e.g.
+// "disk./dev/sda1.used_ratio"; "metrics" is a comma separated list of
+// the prefixes of the names of those checked, all of them by default.
+//
+// The baselines are exponentially weighted with the factor "alpha",
+// 0.05 by default, and used once they have "min_samples" values, 30 by
+// default. If "seasonality" is "hour_of_week", each metric also has a
+// baseline for each hour of the week, which is used once it has enough
+// values. The baselines are kept in StateDir across restarts.
+func Anomaly(ctx context.Context, p policy.Policy) (<-chan policy.Event, error) {
+	sch, err := policy.ParseSchedule(p)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := p.Duration("timeout", system.DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	threshold := 3.0
+	if s, ok := p.M["threshold"]; ok {
+		threshold, err = strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		if threshold <= 0 {
+			return nil, errors.New("threshold must be a positive quantity")
+		}
+	}
+	alpha, err := optionalFactor(p, "alpha", 0.05)
+	if err != nil {
+		return nil, err
+	}
+	minSamples := 30
+	if s, ok := p.M["min_samples"]; ok {
+		minSamples, err = strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		if minSamples < 2 {
+			return nil, errors.New("min_samples must be at least 2")
+		}
+	}
+	var seasonal bool
+	switch s := p.M["seasonality"]; s {
+	case "", "none":
+	case "hour_of_week":
+		seasonal = true
+	default:
+		return nil, fmt.Errorf("unknown seasonality %q", s)
+	}
+	var prefixes []string
+	for _, s := range strings.Split(p.M["metrics"], ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			prefixes = append(prefixes, s)
+		}
+	}
+
+	a := &anomalyDetector{
+		threshold: threshold,
+		prefixes:  prefixes,
+		file:      anomalyStateFile(p.Name),
+	}
+	a.load(alpha, seasonal, minSamples)
+
+	ticks := sch.Tick(ctx)
+
+	out := make(chan policy.Event)
+	go func() {
+		defer close(out)
+		defer policy.Recover(p, out)
+		saved := time.Now()
+		for now := range ticks {
+			data, errs := system.CollectData(ctx, timeout)
+			if anomalies := a.observe(now, data); len(anomalies) > 0 {
+				e := map[string]interface{}{
+					"status":    "anomaly",
+					"threshold": threshold,
+					"anomalies": anomalies,
+				}
+				if len(errs) > 0 {
+					e["errors"] = errs
+				}
+				out <- p.NewEvent(now, policy.StatusWarning, e)
+			}
+			if now.Sub(saved) >= anomalySaveInterval {
+				a.save()
+				saved = now
+			}
+		}
+		a.save()
+	}()
+	return out, nil
+}
+
+// anomalyStateFile returns the file in StateDir in which the
+// baselines of the anomaly policy with the given name are kept.
+func anomalyStateFile(name string) string {
+	if StateDir == "" {
+		return ""
+	}
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+	return filepath.Join(StateDir, "anomaly-"+safe+".json")
+}
+
+// anomalyDetector checks the system data against the baselines.
+type anomalyDetector struct {
+	threshold float64
+	prefixes  []string // of the names of the metrics checked
+	file      string   // in which the baselines are kept, if any
+	d         *anomaly.Detector
+}
+
+// load loads the baselines saved in the file of a, if any,
+// and sets their parameters to those of the policy.
+func (a *anomalyDetector) load(alpha float64, seasonal bool, minSamples int) {
+	if a.file != "" {
+		d, err := anomaly.Load(a.file)
+		switch {
+		case err == nil:
+			a.d = d
+		case !os.IsNotExist(err):
+			log.Printf("anomaly: dropping the baselines in %s: %v", a.file, err)
+		}
+	}
+	if a.d == nil {
+		a.d = anomaly.NewDetector(alpha, seasonal, minSamples)
+		return
+	}
+	a.d.Alpha = alpha
+	a.d.MinSamples = minSamples
+	if a.d.Seasonal && !seasonal {
+		a.d.Seasons = make(map[string][]*anomaly.Baseline)
+	}
+	a.d.Seasonal = seasonal
+}
+
+// save saves the baselines in the file of a, if any.
+func (a *anomalyDetector) save() {
+	if a.file == "" {
+		return
+	}
+	if err := a.d.Save(a.file); err != nil {
+		log.Printf("anomaly: %v", err)
+	}
+}
+
+// observe adds the numbers in the system data collected at now to
+// their baselines and returns those which deviate from them, keyed
+// by their metric. The baselines of the metrics gone are dropped.
+func (a *anomalyDetector) observe(now time.Time, data interface{}) map[string]interface{} {
+	anomalies := make(map[string]interface{})
+	seen := make(map[string]bool)
+	aggregate.Walk(data, func(path []string, x float64) {
+		m := strings.Join(path, ".")
+		if !a.checked(m) {
+			return
+		}
+		seen[m] = true
+		b, ok := a.d.Observe(m, now, x)
+		if !ok {
+			return
+		}
+		if z := b.Z(x); z > a.threshold || z < -a.threshold {
+			anomalies[m] = map[string]interface{}{
+				"value":  x,
+				"mean":   b.Mean,
+				"stddev": b.StdDev(),
+				"z":      z,
+			}
+		}
+	})
+	a.d.Forget(func(m string) bool { return seen[m] })
+	return anomalies
+}
+
+// checked reports whether the metric m is checked.
+func (a *anomalyDetector) checked(m string) bool {
+	if len(a.prefixes) == 0 {
+		return true
+	}
+	for _, p := range a.prefixes {
+		if m == p || strings.HasPrefix(m, p+".") {
+			return true
+		}
+	}
+	return false
+}
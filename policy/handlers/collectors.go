@@ -0,0 +1,68 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/codeignition/recon/metrics"
+	_ "github.com/codeignition/recon/metrics/all"
+	"github.com/codeignition/recon/policy"
+	"golang.org/x/net/context"
+)
+
+// Collectors periodically runs the metric collectors named in
+// the comma separated "collectors" key of the policy.
+func Collectors(ctx context.Context, p policy.Policy) (<-chan policy.Event, error) {
+	names, ok := p.M["collectors"]
+	if !ok {
+		return nil, errors.New(`"collectors" key missing in collectors policy`)
+	}
+	var cs []metrics.Collector
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		c, ok := metrics.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		cs = append(cs, c)
+	}
+
+	sch, err := policy.ParseSchedule(p)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := p.Duration("timeout", sch.Period())
+	if err != nil {
+		return nil, err
+	}
+	ticks := sch.Tick(ctx)
+
+	out := make(chan policy.Event)
+	go func() {
+		defer close(out)
+		defer policy.Recover(p, out)
+		for range ticks {
+			data, status := collect(ctx, timeout, cs)
+			out <- p.NewEvent(time.Now(), status, data)
+		}
+	}()
+	return out, nil
+}
+
+// collect runs the collectors in parallel and maps their names to the
+// collected data. The collectors which failed or timed out are listed
+// under the "errors" key, and make the status unknown.
+func collect(ctx context.Context, timeout time.Duration, cs []metrics.Collector) (map[string]interface{}, string) {
+	a, errs := metrics.CollectAll(ctx, timeout, cs...)
+	if len(errs) > 0 {
+		a["errors"] = errs
+		return a, policy.StatusUnknown
+	}
+	return a, policy.StatusOK
+}
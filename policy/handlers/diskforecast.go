@@ -0,0 +1,200 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/codeignition/recon/forecast"
+	"github.com/codeignition/recon/internal/mount"
+	"github.com/codeignition/recon/policy"
+	"golang.org/x/net/context"
+)
+
+// minForecastSamples is the number of samples of a
+// filesystem needed before its usage is forecast.
+const minForecastSamples = 3
+
+// DiskForecast periodically samples the used space of the filesystems
+// and forecasts when they will be full from the samples of the last
+// "window", 24h by default. The estimated_time_to_full of each
+// mountpoint, in seconds, is left out while it isn't growing.
+//
+// It sends a warning event when the estimated time to full of a
+// filesystem falls below the "horizon", 24h by default. The "method"
+// is "linear", the default, or "holt", whose smoothing factors are
+// "alpha" and "beta".
+func DiskForecast(ctx context.Context, p policy.Policy) (<-chan policy.Event, error) {
+	sch, err := policy.ParseSchedule(p)
+	if err != nil {
+		return nil, err
+	}
+	window, err := p.Duration("window", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	horizon, err := p.Duration("horizon", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	model, err := forecastModel(p)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &diskForecaster{
+		window:  window,
+		horizon: horizon,
+		model:   model,
+		windows: make(map[string]*forecast.Window),
+		warned:  make(map[string]bool),
+	}
+	ticks := sch.Tick(ctx)
+
+	out := make(chan policy.Event)
+	go func() {
+		defer close(out)
+		defer policy.Recover(p, out)
+		for now := range ticks {
+			data, warnings := f.sample(ctx, now)
+			status := policy.StatusOK
+			if _, failed := data["error"]; failed {
+				status = policy.StatusUnknown
+			}
+			out <- p.NewEvent(now, status, data)
+			for _, w := range warnings {
+				out <- p.NewEvent(now, policy.StatusWarning, w)
+			}
+		}
+	}()
+	return out, nil
+}
+
+// forecastModel returns the forecast model in the "method"
+// key of the policy p.
+func forecastModel(p policy.Policy) (forecast.Model, error) {
+	switch m := p.M["method"]; m {
+	case "", "linear":
+		return forecast.Linear, nil
+	case "holt":
+		alpha, err := optionalFactor(p, "alpha", 0.5)
+		if err != nil {
+			return nil, err
+		}
+		beta, err := optionalFactor(p, "beta", 0.1)
+		if err != nil {
+			return nil, err
+		}
+		return forecast.Holt(alpha, beta), nil
+	default:
+		return nil, fmt.Errorf("unknown forecast method %q", m)
+	}
+}
+
+// optionalFactor parses the smoothing factor in the given policy key,
+// which must be between 0 and 1. It returns def if the key is absent.
+func optionalFactor(p policy.Policy, key string, def float64) (float64, error) {
+	s, ok := p.M[key]
+	if !ok {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if f <= 0 || f > 1 {
+		return 0, errors.New(key + " must be between 0 and 1")
+	}
+	return f, nil
+}
+
+// diskForecaster keeps the samples of the used space
+// of the filesystems, keyed by their mountpoint.
+type diskForecaster struct {
+	window  time.Duration
+	horizon time.Duration
+	model   forecast.Model
+	windows map[string]*forecast.Window
+	warned  map[string]bool // mountpoints whose warning was sent
+}
+
+// sample samples the filesystems at now and returns the forecasts,
+// along with the warnings for the filesystems whose estimated time
+// to full has just fallen below the horizon.
+func (f *diskForecaster) sample(ctx context.Context, now time.Time) (data map[string]interface{}, warnings []map[string]interface{}) {
+	mounts, err := mount.List(mount.DefaultFilter)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	forecasts := make(map[string]interface{})
+	seen := make(map[string]bool)
+	for _, m := range mounts {
+		seen[m.Point] = true
+		u, err := mount.Stat(ctx, m.Point)
+		if err != nil {
+			forecasts[m.Point] = map[string]interface{}{"error": err.Error()}
+			continue
+		}
+		w, ok := f.windows[m.Point]
+		if !ok {
+			w = &forecast.Window{Span: f.window}
+			f.windows[m.Point] = w
+		}
+		w.Add(forecast.Sample{Time: now, Value: float64(u.Used)})
+
+		// The space available to the unprivileged users,
+		// which excludes the blocks reserved for root.
+		capacity := u.Used + u.Available
+		fc := map[string]interface{}{
+			"used":     u.Used,
+			"capacity": capacity,
+			"samples":  len(w.Samples),
+		}
+		forecasts[m.Point] = fc
+		if len(w.Samples) < minForecastSamples {
+			continue
+		}
+		level, trend, ok := f.model(w.Samples)
+		if !ok {
+			continue
+		}
+		fc["growth_rate"] = trend
+		ttf, ok := forecast.TimeTo(level, trend, float64(capacity))
+		if !ok {
+			delete(f.warned, m.Point)
+			continue
+		}
+		fc["estimated_time_to_full"] = ttf.Seconds()
+
+		if ttf >= f.horizon {
+			delete(f.warned, m.Point)
+			continue
+		}
+		if f.warned[m.Point] {
+			continue
+		}
+		f.warned[m.Point] = true
+		warnings = append(warnings, map[string]interface{}{
+			"status":                 "warning",
+			"mountpoint":             m.Point,
+			"device":                 m.Device,
+			"estimated_time_to_full": ttf.Seconds(),
+			"horizon":                f.horizon.Seconds(),
+			"message":                fmt.Sprintf("%s is estimated to be full in %v", m.Point, ttf-ttf%time.Minute),
+		})
+	}
+	// Forget the filesystems which were unmounted.
+	for p := range f.windows {
+		if !seen[p] {
+			delete(f.windows, p)
+			delete(f.warned, p)
+		}
+	}
+	return map[string]interface{}{"disk_forecast": forecasts}, warnings
+}
@@ -4,9 +4,14 @@
 
 package handlers
 
-import "github.com/codeignition/recon/policy"
+import (
+	"github.com/codeignition/recon/policy"
+)
 
 func init() {
 	policy.RegisterHandler("tcp", TCP)
 	policy.RegisterHandler("system_data", SystemData)
+	policy.RegisterHandler("collectors", Collectors)
+	policy.RegisterHandler("disk_forecast", DiskForecast)
+	policy.RegisterHandler("anomaly", Anomaly)
 }
@@ -6,57 +6,138 @@ package handlers
 
 import (
 	"errors"
-	"log"
+	"strings"
 	"time"
 
+	"github.com/codeignition/recon/aggregate"
+	"github.com/codeignition/recon/metrics"
 	"github.com/codeignition/recon/metrics/system"
 	"github.com/codeignition/recon/policy"
 	"golang.org/x/net/context"
 )
 
+// SystemData periodically sends the system data, on the schedule of
+// the policy, e.g. every "interval".
+//
+// If the policy has a "sample_interval", shorter than the time between
+// the runs, the data is sampled at it and each number is sent as its
+// min, max, mean and 95th percentile over the samples since the last run.
 func SystemData(ctx context.Context, p policy.Policy) (<-chan policy.Event, error) {
-	interval, ok := p.M["interval"]
-	if !ok {
-		return nil, errors.New(`"interval" key missing in systemdata policy`)
-	}
-	d, err := time.ParseDuration(interval)
+	sch, err := policy.ParseSchedule(p)
 	if err != nil {
 		return nil, err
 	}
-	// This check is here to ensure time.Ticker(d) doesn't panic
-	if d <= 0 {
-		return nil, errors.New("interval must be a positive quantity")
+	if _, ok := p.M["sample_interval"]; ok {
+		return aggregateSystemData(ctx, p, sch)
+	}
+	timeout, err := p.Duration("timeout", system.DefaultTimeout)
+	if err != nil {
+		return nil, err
 	}
+	ticks := sch.Tick(ctx)
 
 	out := make(chan policy.Event)
 	go func() {
-		t := time.NewTicker(d)
-		for {
-			select {
-			case <-ctx.Done():
-				t.Stop()
-				close(out)
-				return
-			case <-t.C:
-				out <- policy.Event{
-					Time:       time.Now(),
-					PolicyName: p.Name,
-					AgentUID:   p.AgentUID,
-					Data:       accumulateSystemData(),
-				}
-			}
+		defer close(out)
+		defer policy.Recover(p, out)
+		for range ticks {
+			data, status := accumulateSystemData(ctx, timeout)
+			out <- p.NewEvent(time.Now(), status, data)
 		}
 	}()
 	return out, nil
 }
 
-func accumulateSystemData() interface{} {
-	d, err := system.CollectData()
-	if err != nil {
-		log.Print(err)
-	}
+// accumulateSystemData collects the system data and returns it with
+// its status. The collectors which failed or timed out are listed
+// under the "errors" key.
+func accumulateSystemData(ctx context.Context, timeout time.Duration) (interface{}, string) {
+	d, errs := system.CollectData(ctx, timeout)
 	a := map[string]interface{}{
 		"system": d,
 	}
-	return a
+	if len(errs) > 0 {
+		a["errors"] = errs
+	}
+	return a, systemStatus(d, errs)
+}
+
+// systemStatus returns the worst of the statuses in the system data d,
+// such as the "raid_status", or unknown if any collector failed and
+// none is worse.
+func systemStatus(d system.Data, errs metrics.Errors) string {
+	status := policy.StatusOK
+	if len(errs) > 0 {
+		status = policy.StatusUnknown
+	}
+	for k, v := range d {
+		if s, ok := v.(string); ok && strings.HasSuffix(k, "_status") {
+			status = policy.Worse(status, s)
+		}
+	}
+	return status
+}
+
+// aggregateSystemData samples the system data every "sample_interval"
+// of the policy p and sends the aggregated samples on its schedule.
+func aggregateSystemData(ctx context.Context, p policy.Policy, sch *policy.Schedule) (<-chan policy.Event, error) {
+	sample, err := p.Duration("sample_interval", sch.Period())
+	if err != nil {
+		return nil, err
+	}
+	if sample >= sch.Period() {
+		return nil, errors.New("sample_interval must be shorter than the time between the runs")
+	}
+	// A sample mustn't take longer than the sample interval.
+	timeout := system.DefaultTimeout
+	if sample < timeout {
+		timeout = sample
+	}
+	timeout, err = p.Duration("timeout", timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	ticks := sch.Tick(ctx)
+
+	out := make(chan policy.Event)
+	go func() {
+		defer close(out)
+		defer policy.Recover(p, out)
+		st := time.NewTicker(sample)
+		defer st.Stop()
+		var a aggregate.Aggregator
+		var errs metrics.Errors   // of the last sample which had any
+		status := policy.StatusOK // the worst of the samples
+		for {
+			select {
+			case <-st.C:
+				d, e := system.CollectData(ctx, timeout)
+				a.Add(d)
+				if len(e) > 0 {
+					errs = e
+				}
+				status = policy.Worse(status, systemStatus(d, e))
+			case _, ok := <-ticks:
+				if !ok {
+					return
+				}
+				if a.Len() == 0 {
+					continue
+				}
+				data := map[string]interface{}{
+					"system":  a.Result(),
+					"samples": a.Len(),
+				}
+				if len(errs) > 0 {
+					data["errors"] = errs
+				}
+				out <- p.NewEvent(time.Now(), status, data)
+				a.Reset()
+				errs = nil
+				status = policy.StatusOK
+			}
+		}
+	}()
+	return out, nil
 }
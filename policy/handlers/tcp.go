@@ -14,66 +14,48 @@ import (
 	"golang.org/x/net/context"
 )
 
+// TCP periodically checks that a connection can be made to the
+// "address" of the policy. The dials time out and are retried as
+// set by the check options of the policy.
 func TCP(ctx context.Context, p policy.Policy) (<-chan policy.Event, error) {
 	// Always use v, ok := p[key] form to avoid panic
 	addr, ok := p.M["address"]
 	if !ok {
 		return nil, errors.New(`"address" key missing in tcp policy`)
 	}
-	interval, ok := p.M["interval"]
-	if !ok {
-		return nil, errors.New(`"interval" key missing in tcp policy`)
-	}
-
-	// From the time package docs:
-	//
-	// ParseDuration parses a duration string.
-	// A duration string is a possibly signed sequence of
-	// decimal numbers, each with optional fraction and a unit suffix,
-	// such as "300ms", "-1.5h" or "2h45m".
-	// Valid time units are "ns", "us", "ms", "s", "m", "h".
-	d, err := time.ParseDuration(interval)
+	opts, err := policy.ParseCheckOptions(p)
 	if err != nil {
 		return nil, err
 	}
-
-	// This check is here to ensure time.Ticker(d) doesn't panic
-	if d <= 0 {
-		return nil, errors.New("interval must be a positive quantity")
+	ticks, err := policy.Tick(ctx, p)
+	if err != nil {
+		return nil, err
 	}
 
 	out := make(chan policy.Event)
 	go func() {
-		t := time.NewTicker(d)
-		for {
-			select {
-			case <-ctx.Done():
-				t.Stop()
-				close(out)
-				return
-			case <-t.C:
-				_, err := net.DialTimeout("tcp", addr, d)
+		defer close(out)
+		defer policy.Recover(p, out)
+		for range ticks {
+			attempts, err := opts.Run(ctx, func(ctx context.Context) error {
+				var d net.Dialer
+				conn, err := d.DialContext(ctx, "tcp", addr)
 				if err != nil {
-					out <- policy.Event{
-						Time:       time.Now(),
-						PolicyName: p.Name,
-						AgentUID:   p.AgentUID,
-						Data: map[string]interface{}{
-							"status": "failure",
-							"error":  err.Error(),
-						},
-					}
-				} else {
-					out <- policy.Event{
-						Time:       time.Now(),
-						PolicyName: p.Name,
-						AgentUID:   p.AgentUID,
-						Data: map[string]interface{}{
-							"status": "success",
-						},
-					}
+					return err
 				}
+				return conn.Close()
+			})
+			data := map[string]interface{}{
+				"status":   "success",
+				"attempts": attempts,
+			}
+			status := policy.StatusOK
+			if err != nil {
+				data["status"] = "failure"
+				data["error"] = err.Error()
+				status = policy.StatusCritical
 			}
+			out <- p.NewEvent(time.Now(), status, data)
 		}
 	}()
 	return out, nil
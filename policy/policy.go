@@ -7,6 +7,7 @@ package policy
 import (
 	"errors"
 	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 )
@@ -17,6 +18,29 @@ type Policy struct {
 	AgentUID string            // Agent UID
 	Type     string            // Type denotes the monitoring policy type. e.g. "tcp"
 	M        map[string]string // M is the map containing the rules of a particular monitoring policy.
+	Labels   map[string]string `json:",omitempty"` // Labels are copied to the events of the policy, e.g. "team": "payments"
+}
+
+// Duration parses the positive duration in the given key of the policy.
+// It returns def if the key is absent.
+//
+// The "timeout" key means the same for every handler: the time given to
+// each run of the policy, e.g. to the collectors of a system_data policy
+// or to an attempt of a check. The check-style handlers take it from
+// their CheckOptions and mustn't give it another meaning.
+func (p Policy) Duration(key string, def time.Duration) (time.Duration, error) {
+	s, ok := p.M[key]
+	if !ok {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, errors.New(key + " must be a positive quantity")
+	}
+	return d, nil
 }
 
 // Config is a slice of monitoring policies
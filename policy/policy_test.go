@@ -42,8 +42,8 @@ func fakePolicyHandler(ctx context.Context, p Policy) (<-chan Event, error) {
 				return
 			case <-t.C:
 				out <- Event{
-					Time:   time.Now(),
-					Policy: p,
+					Time:       time.Now(),
+					PolicyName: p.Name,
 					Data: map[string]interface{}{
 						"foo": foo,
 					},
@@ -74,14 +74,16 @@ func TestRegisterHandler(t *testing.T) {
 func TestRegisterHandlerConcurrent(t *testing.T) {
 	f1 := new(HandlerFunc)
 	f2 := new(HandlerFunc)
-	// This checks for a data race when `go test -race` is executed
+	// This checks for a data race when `go test -race` is executed.
+	// The types differ, or one of the two would fail as registered
+	// twice, and from the goroutine after the test has completed.
 	go func() {
-		err := RegisterHandler("foo", *f1)
+		err := RegisterHandler("foo1", *f1)
 		if err != nil {
 			t.Error(err)
 		}
 	}()
-	err := RegisterHandler("foo", *f2)
+	err := RegisterHandler("foo2", *f2)
 	if err != nil {
 		t.Error(err)
 	}
@@ -103,6 +105,21 @@ func TestValid(t *testing.T) {
 	}
 }
 
+func TestDuration(t *testing.T) {
+	p := Policy{M: map[string]string{"timeout": "3s", "window": "0s", "horizon": "soon"}}
+	if d, err := p.Duration("timeout", time.Second); err != nil || d != 3*time.Second {
+		t.Errorf("got %v, %v; want 3s", d, err)
+	}
+	if d, err := p.Duration("interval", time.Second); err != nil || d != time.Second {
+		t.Errorf("got %v, %v; want the default 1s", d, err)
+	}
+	for _, key := range []string{"window", "horizon"} {
+		if _, err := p.Duration(key, time.Second); err == nil {
+			t.Errorf("%s: want an error", key)
+		}
+	}
+}
+
 func TestExecuteInvalidPolicy(t *testing.T) {
 	p := Policy{
 		Name: "dummy",
@@ -138,8 +155,9 @@ func TestExecute(t *testing.T) {
 	// this test should have been running forever.
 	for evt := range out {
 		count++
-		if evt.Data["foo"] != "foo_value" {
-			t.Errorf(`want evt.Data["foo"] = %s; got %s`, "foo", evt.Data["foo"])
+		data := evt.Data.(map[string]interface{})
+		if data["foo"] != "foo_value" {
+			t.Errorf(`want evt.Data["foo"] = %s; got %s`, "foo", data["foo"])
 		}
 	}
 
@@ -0,0 +1,321 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeignition/recon/internal/cron"
+	"golang.org/x/net/context"
+)
+
+// Schedule is the schedule of the runs of a policy, shared by the
+// handlers so that they needn't roll their own tickers. It is parsed
+// from the keys of the policy:
+//
+//	interval        run every interval, e.g. "30s"
+//	cron            or run at the minutes the cron schedule matches, e.g. "*/5 * * * *"
+//	jitter          delay the runs by a random duration up to it, e.g. "10s"
+//	run_immediately "true" to run once as soon as the policy starts
+//	time_window     comma separated times of the day to run in, e.g. "09:00-17:30,22:00-02:00"
+//
+// One of interval and cron is required. The jitter spreads the runs of
+// the agents which start together, or share a cron schedule, so that
+// they don't all hit the same backend at once: an interval schedule is
+// offset once, when it starts, and a cron schedule at every run. The
+// windows are in the local time; the runs outside them are skipped.
+type Schedule struct {
+	Interval       time.Duration
+	Cron           *cron.Schedule
+	Jitter         time.Duration
+	RunImmediately bool
+	Windows        []Window
+}
+
+// Window is a time of the day, in minutes since midnight.
+// It wraps around midnight if End is before Start.
+type Window struct {
+	Start, End int
+}
+
+// Contains reports whether the window contains the time of the day of t.
+func (w Window) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.Start <= w.End {
+		return w.Start <= m && m < w.End
+	}
+	return m >= w.Start || m < w.End
+}
+
+// next returns the first time, from t on, in the window.
+func (w Window) next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	s := time.Date(t.Year(), t.Month(), t.Day(), w.Start/60, w.Start%60, 0, 0, t.Location())
+	if s.Before(t) {
+		s = time.Date(t.Year(), t.Month(), t.Day()+1, w.Start/60, w.Start%60, 0, 0, t.Location())
+	}
+	return s
+}
+
+// parseWindow parses a window such as "09:00-17:30".
+func parseWindow(s string) (Window, error) {
+	i := strings.Index(s, "-")
+	if i < 0 {
+		return Window{}, fmt.Errorf("invalid window %q; want e.g. 09:00-17:30", s)
+	}
+	start, err := parseTimeOfDay(s[:i])
+	if err != nil {
+		return Window{}, err
+	}
+	end, err := parseTimeOfDay(s[i+1:])
+	if err != nil {
+		return Window{}, err
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("window %q is empty", s)
+	}
+	return Window{start, end}, nil
+}
+
+// parseTimeOfDay parses a time of the day such as
+// "17:30" and returns it in minutes since midnight.
+func parseTimeOfDay(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return 0, fmt.Errorf("invalid time of the day %q; want e.g. 17:30", s)
+	}
+	h, err := strconv.Atoi(s[:i])
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid time of the day %q", s)
+	}
+	m, err := strconv.Atoi(s[i+1:])
+	if err != nil || m < 0 || m > 59 || h == 24 && m != 0 {
+		return 0, fmt.Errorf("invalid time of the day %q", s)
+	}
+	return (h*60 + m) % (24 * 60), nil
+}
+
+// ParseSchedule parses the schedule in the keys of the policy p.
+func ParseSchedule(p Policy) (*Schedule, error) {
+	var s Schedule
+	interval, hasInterval := p.M["interval"]
+	spec, hasCron := p.M["cron"]
+	switch {
+	case hasInterval && hasCron:
+		return nil, errors.New(`policy can't have both "interval" and "cron" keys`)
+	case hasInterval:
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return nil, err
+		}
+		// This check is here to ensure the schedule doesn't spin
+		if d <= 0 {
+			return nil, errors.New("interval must be a positive quantity")
+		}
+		s.Interval = d
+	case hasCron:
+		c, err := cron.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		s.Cron = c
+	default:
+		return nil, fmt.Errorf(`"interval" or "cron" key missing in %s policy`, p.Type)
+	}
+	if v, ok := p.M["jitter"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		if d < 0 {
+			return nil, errors.New("jitter can't be negative")
+		}
+		s.Jitter = d
+	}
+	if v, ok := p.M["run_immediately"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("run_immediately: %v", err)
+		}
+		s.RunImmediately = b
+	}
+	if v, ok := p.M["time_window"]; ok {
+		for _, w := range strings.Split(v, ",") {
+			win, err := parseWindow(w)
+			if err != nil {
+				return nil, err
+			}
+			s.Windows = append(s.Windows, win)
+		}
+	}
+	return &s, nil
+}
+
+// Tick parses the schedule of the policy p and returns a channel on
+// which the times of its runs are sent. The channel is closed when
+// ctx is done.
+func Tick(ctx context.Context, p Policy) (<-chan time.Time, error) {
+	s, err := ParseSchedule(p)
+	if err != nil {
+		return nil, err
+	}
+	return s.Tick(ctx), nil
+}
+
+// Period returns the time between two runs, for the handlers to
+// bound the work of a run by. For a cron schedule, it is the time
+// between its next two runs.
+func (s *Schedule) Period() time.Duration {
+	if s.Interval > 0 {
+		return s.Interval
+	}
+	t := s.Cron.Next(time.Now())
+	if u := s.Cron.Next(t); !u.IsZero() {
+		return u.Sub(t)
+	}
+	return time.Minute
+}
+
+// inWindow reports whether t is in any of the windows of s.
+func (s *Schedule) inWindow(t time.Time) bool {
+	if len(s.Windows) == 0 {
+		return true
+	}
+	for _, w := range s.Windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextInWindow returns the first time, from t on, in any of the windows of s.
+func (s *Schedule) nextInWindow(t time.Time) time.Time {
+	if len(s.Windows) == 0 {
+		return t
+	}
+	var next time.Time
+	for _, w := range s.Windows {
+		if n := w.next(t); next.IsZero() || n.Before(next) {
+			next = n
+		}
+	}
+	return next
+}
+
+// maxSkips bounds the runs a schedule skips looking for one in its
+// windows, e.g. if a window is shorter than the interval and never
+// contains a run.
+const maxSkips = 10000
+
+// Next returns the time of the first run after t of the schedule whose
+// interval runs start at base, without the jitter. It returns the zero
+// time if there is none.
+func (s *Schedule) Next(base, t time.Time) time.Time {
+	for i := 0; i < maxSkips; i++ {
+		var next time.Time
+		if s.Interval > 0 {
+			k := int64(0)
+			if !t.Before(base) {
+				k = int64(t.Sub(base)/s.Interval) + 1
+			}
+			next = base.Add(time.Duration(k) * s.Interval)
+		} else {
+			next = s.Cron.Next(t)
+			if next.IsZero() {
+				return next
+			}
+		}
+		if s.inWindow(next) {
+			return next
+		}
+		// Skip to the start of the next window, minus a nanosecond
+		// since the next run after it is looked for.
+		t = s.nextInWindow(next).Add(-time.Nanosecond)
+	}
+	return time.Time{}
+}
+
+// jitterRand is the source of the jitter.
+var jitterRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{
+	Rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+}
+
+// jitter returns a random duration up to the jitter of s.
+func (s *Schedule) jitter() time.Duration {
+	if s.Jitter <= 0 {
+		return 0
+	}
+	jitterRand.Lock()
+	defer jitterRand.Unlock()
+	return time.Duration(jitterRand.Int63n(int64(s.Jitter)))
+}
+
+// Tick returns a channel on which the times of the runs of the schedule
+// are sent. The runs missed while the receiver was busy are skipped.
+// The channel is closed when ctx is done.
+func (s *Schedule) Tick(ctx context.Context) <-chan time.Time {
+	c := make(chan time.Time)
+	go func() {
+		defer close(c)
+		send := func(t time.Time) bool {
+			select {
+			case c <- t:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		now := time.Now()
+		if s.RunImmediately && s.inWindow(now) && !send(now) {
+			return
+		}
+		base := now
+		if s.Interval > 0 {
+			base = now.Add(s.jitter())
+		}
+		last := now
+		for {
+			next := s.Next(base, last)
+			if next.IsZero() {
+				// The schedule has no more runs,
+				// e.g. on the 30th of February.
+				<-ctx.Done()
+				return
+			}
+			at := next
+			if s.Cron != nil {
+				at = at.Add(s.jitter())
+			}
+			t := time.NewTimer(at.Sub(time.Now()))
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case now := <-t.C:
+				if !send(now) {
+					return
+				}
+			}
+			last = next
+			if now := time.Now(); now.After(last) {
+				last = now
+			}
+		}
+	}()
+	return c
+}
@@ -0,0 +1,99 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, m := range []map[string]string{
+		{},
+		{"interval": "1m", "cron": "* * * * *"},
+		{"interval": "0s"},
+		{"interval": "soon"},
+		{"cron": "* * * *"},
+		{"interval": "1m", "jitter": "-1s"},
+		{"interval": "1m", "run_immediately": "yes please"},
+		{"interval": "1m", "time_window": "09:00"},
+		{"interval": "1m", "time_window": "09:00-25:00"},
+		{"interval": "1m", "time_window": "09:00-09:00"},
+	} {
+		if _, err := ParseSchedule(Policy{Name: "p", Type: "fake", M: m}); err == nil {
+			t.Errorf("ParseSchedule(%v): want an error", m)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	day := func(h, m, s int) time.Time {
+		return time.Date(2015, 6, 1, h, m, s, 0, time.Local)
+	}
+	base := day(8, 0, 10)
+	tests := []struct {
+		m    map[string]string
+		t    time.Time
+		want time.Time
+	}{
+		{map[string]string{"interval": "1m"}, day(8, 0, 10), day(8, 1, 10)},
+		{map[string]string{"interval": "1m"}, day(8, 0, 0), day(8, 0, 10)},
+		{map[string]string{"interval": "1m"}, day(8, 30, 40), day(8, 31, 10)},
+		{map[string]string{"cron": "*/5 * * * *"}, day(8, 0, 10), day(8, 5, 0)},
+		{map[string]string{"interval": "1h", "time_window": "09:00-17:00"}, day(8, 30, 0), day(9, 0, 10)},
+		{map[string]string{"interval": "1h", "time_window": "09:00-17:00"}, day(16, 0, 10), day(9, 0, 10).AddDate(0, 0, 1)},
+		{map[string]string{"interval": "1h", "time_window": "22:00-02:00"}, day(8, 30, 0), day(22, 0, 10)},
+		{map[string]string{"interval": "1h", "time_window": "22:00-02:00"}, day(23, 30, 0), day(0, 0, 10).AddDate(0, 0, 1)},
+		{map[string]string{"interval": "1h", "time_window": "09:00-09:30,12:00-13:00"}, day(9, 0, 10), day(12, 0, 10)},
+		{map[string]string{"cron": "0 * * * *", "time_window": "12:00-13:00"}, day(8, 0, 10), day(12, 0, 0)},
+		{map[string]string{"interval": "2h", "time_window": "09:30-10:00"}, day(8, 30, 0), time.Time{}}, // never in the window
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(Policy{Name: "p", M: tt.m})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Next(base, tt.t); !got.Equal(tt.want) {
+			t.Errorf("%v: Next(%v) = %v; want %v", tt.m, tt.t.Format("15:04:05"), got, tt.want)
+		}
+	}
+}
+
+func TestScheduleJitter(t *testing.T) {
+	s := &Schedule{Interval: time.Minute, Jitter: 10 * time.Second}
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		j := s.jitter()
+		if j < 0 || j >= s.Jitter {
+			t.Fatalf("jitter() = %v; want it in [0, %v)", j, s.Jitter)
+		}
+		seen[j] = true
+	}
+	if len(seen) < 2 {
+		t.Error("want random jitters")
+	}
+}
+
+func TestTick(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := Tick(ctx, Policy{Name: "p", M: map[string]string{"interval": "10ms", "run_immediately": "true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	first := <-c
+	if first.Sub(start) > 5*time.Millisecond {
+		t.Errorf("first run after %v; want it immediately", first.Sub(start))
+	}
+	second := <-c
+	if d := second.Sub(first); d < 5*time.Millisecond {
+		t.Errorf("second run after %v; want it after the interval", d)
+	}
+	cancel()
+	for range c {
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"errors"
+	"time"
+
+	"github.com/codeignition/recon/internal/cron"
+)
+
+// Silence is a maintenance window of a policy, or of all the policies
+// of the agent. The events of the policies silenced are still sent, but
+// they are marked as suppressed and not alerted on.
+//
+// A silence is either a time range, from Start to End, or recurs for
+// Duration from each minute the cron schedule Cron matches in the local
+// time, e.g. "0 2 * * sun" for 2 AM every Sunday. A recurring silence
+// may also be bound by Start and End.
+type Silence struct {
+	Name     string // Name identifies the silence
+	Policy   string `json:",omitempty"` // Policy is the name of the policy silenced; all if empty
+	Start    time.Time
+	End      time.Time
+	Cron     string `json:",omitempty"`
+	Duration string `json:",omitempty"` // Duration is the length of each recurring window, e.g. "30m"
+	Comment  string `json:",omitempty"` // Comment tells why, e.g. "kernel upgrade"
+}
+
+// Valid checks whether the silence is valid.
+func (s Silence) Valid() error {
+	if s.Name == "" {
+		return errors.New("silence name can't be empty")
+	}
+	if !s.Start.IsZero() && !s.End.IsZero() && !s.End.After(s.Start) {
+		return errors.New("silence must end after it starts")
+	}
+	if s.Cron == "" {
+		if s.Duration != "" {
+			return errors.New("silence duration needs a cron schedule")
+		}
+		if s.Start.IsZero() || s.End.IsZero() {
+			return errors.New("silence needs a start and an end, or a cron schedule")
+		}
+		return nil
+	}
+	if _, err := cron.Parse(s.Cron); err != nil {
+		return err
+	}
+	d, err := time.ParseDuration(s.Duration)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return errors.New("silence duration must be a positive quantity")
+	}
+	return nil
+}
+
+// Active reports whether the silence is in effect at t.
+// An invalid silence never is.
+func (s Silence) Active(t time.Time) bool {
+	if !s.Start.IsZero() && t.Before(s.Start) {
+		return false
+	}
+	if !s.End.IsZero() && !t.Before(s.End) {
+		return false
+	}
+	if s.Cron == "" {
+		return !s.Start.IsZero() && !s.End.IsZero()
+	}
+	sch, err := cron.Parse(s.Cron)
+	if err != nil {
+		return false
+	}
+	d, err := time.ParseDuration(s.Duration)
+	if err != nil || d <= 0 {
+		return false
+	}
+	// The window which started last before t, if any,
+	// started within d of it.
+	t = t.Local()
+	next := sch.Next(t.Add(-d))
+	return !next.IsZero() && !next.After(t)
+}
+
+// Expired reports whether the silence will never be in effect after t.
+func (s Silence) Expired(t time.Time) bool {
+	return !s.End.IsZero() && !t.Before(s.End)
+}
+
+// Silences is a list of silences.
+type Silences []Silence
+
+// Silenced reports whether the policy with the given
+// name is silenced at t by any of the silences.
+func (ss Silences) Silenced(policyName string, t time.Time) bool {
+	for _, s := range ss {
+		if (s.Policy == "" || s.Policy == policyName) && s.Active(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSilenceValid(t *testing.T) {
+	start := time.Date(2015, 6, 1, 2, 0, 0, 0, time.Local)
+	tests := []struct {
+		s     Silence
+		valid bool
+	}{
+		{Silence{Name: "upgrade", Start: start, End: start.Add(time.Hour)}, true},
+		{Silence{Name: "backup", Cron: "0 2 * * sun", Duration: "1h"}, true},
+		{Silence{Name: "backup", Cron: "0 2 * * sun", Duration: "1h", Start: start}, true},
+		{Silence{Start: start, End: start.Add(time.Hour)}, false},
+		{Silence{Name: "upgrade", Start: start}, false},
+		{Silence{Name: "upgrade", Start: start, End: start}, false},
+		{Silence{Name: "upgrade", Start: start, End: start.Add(time.Hour), Duration: "1h"}, false},
+		{Silence{Name: "backup", Cron: "0 2 * *", Duration: "1h"}, false},
+		{Silence{Name: "backup", Cron: "0 2 * * sun"}, false},
+		{Silence{Name: "backup", Cron: "0 2 * * sun", Duration: "-1h"}, false},
+	}
+	for i, tt := range tests {
+		if err := tt.s.Valid(); (err == nil) != tt.valid {
+			t.Errorf("%d: Valid() = %v; want valid %v", i, err, tt.valid)
+		}
+	}
+}
+
+func TestSilenced(t *testing.T) {
+	// Sunday, 31 May 2015.
+	sunday := time.Date(2015, 5, 31, 0, 0, 0, 0, time.Local)
+	ss := Silences{
+		{Name: "upgrade", Policy: "web", Start: sunday.Add(10 * time.Hour), End: sunday.Add(11 * time.Hour)},
+		{Name: "backup", Cron: "30 2 * * sun", Duration: "1h"},
+	}
+	tests := []struct {
+		policy string
+		at     time.Duration // since sunday
+		want   bool
+	}{
+		{"web", 10 * time.Hour, true},
+		{"web", 10*time.Hour + 59*time.Minute, true},
+		{"web", 11 * time.Hour, false},
+		{"db", 10 * time.Hour, false},
+		{"db", 2*time.Hour + 29*time.Minute, false},
+		{"db", 2*time.Hour + 30*time.Minute, true},
+		{"web", 3*time.Hour + 29*time.Minute, true},
+		{"web", 3*time.Hour + 30*time.Minute, false},
+		{"db", 7*24*time.Hour + 2*time.Hour + 45*time.Minute, true}, // the next week
+		{"db", 24*time.Hour + 2*time.Hour + 45*time.Minute, false},
+	}
+	for _, tt := range tests {
+		at := sunday.Add(tt.at)
+		if got := ss.Silenced(tt.policy, at); got != tt.want {
+			t.Errorf("Silenced(%q, %v) = %v; want %v", tt.policy, at, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Recover recovers a panic of the goroutine of a handler of the policy
+// p, and sends it on out as an error event with its stack trace, for
+// the Supervisor to restart the policy. The handlers must defer it, after
+// deferring the closing of out, in the goroutines which send the events:
+//
+//	go func() {
+//		defer close(out)
+//		defer policy.Recover(p, out)
+//		...
+//	}()
+func Recover(p Policy, out chan<- Event) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	out <- panicEvent(p, r, debug.Stack())
+}
+
+// panicEvent returns the error event of the panic r of the policy p.
+func panicEvent(p Policy, r interface{}, stack []byte) Event {
+	log.Printf("policy %s panicked: %v\n%s", p.Name, r, stack)
+	e := errorEvent(p, fmt.Sprintf("panic: %v", r))
+	e.Data.(map[string]interface{})["stack"] = string(stack)
+	return e
+}
+
+// errorEvent returns an error event of the policy p. Its status
+// is unknown, since the policy no longer checks anything.
+func errorEvent(p Policy, err string) Event {
+	return p.NewEvent(time.Now(), StatusUnknown, map[string]interface{}{
+		"status": "error",
+		"error":  err,
+	})
+}
+
+// Supervisor runs the policies and restarts them when their handlers
+// crash, i.e. panic or stop sending events before they are cancelled.
+// A policy which crashes MaxCrashes times in a row is marked as failed
+// and no longer restarted. It is restarted after a backoff, which
+// doubles with each crash from MinBackoff up to MaxBackoff; a run which
+// lasts longer than MaxBackoff resets the crashes.
+type Supervisor struct {
+	MaxCrashes int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultSupervisor is the Supervisor used by Supervise.
+var DefaultSupervisor = Supervisor{
+	MaxCrashes: 5,
+	MinBackoff: time.Second,
+	MaxBackoff: 5 * time.Minute,
+}
+
+// Supervise runs the policy p with the DefaultSupervisor.
+func (p Policy) Supervise(ctx context.Context) (<-chan Event, error) {
+	return DefaultSupervisor.Run(ctx, p)
+}
+
+// execute executes the policy p, recovering a panic of its handler.
+func execute(ctx context.Context, p Policy) (events <-chan Event, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicEvent(p, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return p.Execute(ctx)
+}
+
+// Run executes the policy p and returns its events, along with the
+// error events of its crashes and, if it fails, an event whose status
+// is "failed". It returns the error of the first execution, e.g. an
+// invalid policy, which isn't retried. The events are closed when ctx
+// is done or the policy fails.
+func (s Supervisor) Run(ctx context.Context, p Policy) (<-chan Event, error) {
+	// Each run has its own context, so that the goroutines
+	// left behind by a crashed handler are stopped.
+	runCtx, cancel := context.WithCancel(ctx)
+	events, err := execute(runCtx, p)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		crashes := 0
+		backoff := s.MinBackoff
+		for {
+			start := time.Now()
+			for e := range events {
+				out <- e
+			}
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			if time.Since(start) > s.MaxBackoff {
+				crashes = 0
+				backoff = s.MinBackoff
+			}
+			crashes++
+			if crashes >= s.MaxCrashes {
+				log.Printf("policy %s failed after %d crashes", p.Name, crashes)
+				e := errorEvent(p, fmt.Sprintf("policy failed after %d crashes", crashes))
+				e.Data.(map[string]interface{})["status"] = "failed"
+				e.Data.(map[string]interface{})["crashes"] = crashes
+				out <- e
+				return
+			}
+			log.Printf("policy %s crashed, restarting it in %v", p.Name, backoff)
+			t := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+			if backoff *= 2; backoff > s.MaxBackoff {
+				backoff = s.MaxBackoff
+			}
+			runCtx, cancel = context.WithCancel(ctx)
+			events, err = execute(runCtx, p)
+			if err != nil {
+				// The restart crashed too.
+				out <- errorEvent(p, err.Error())
+				c := make(chan Event)
+				close(c)
+				events = c
+			}
+		}
+	}()
+	return out, nil
+}
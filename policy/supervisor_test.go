@@ -0,0 +1,75 @@
+// Copyright 2015 CodeIgnition. All rights reserved.
+// Use of this source code is governed by a BSD
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func init() {
+	RegisterHandler("panicky", func(ctx context.Context, p Policy) (<-chan Event, error) {
+		if p.M["panic"] == "now" {
+			panic("before the events")
+		}
+		out := make(chan Event)
+		go func() {
+			defer close(out)
+			defer Recover(p, out)
+			out <- Event{PolicyName: p.Name, Data: map[string]interface{}{"status": "ok"}}
+			var m map[string]int
+			m["boom"]++
+		}()
+		return out, nil
+	})
+}
+
+func TestSupervisor(t *testing.T) {
+	s := Supervisor{MaxCrashes: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Second}
+	events, err := s.Run(context.Background(), Policy{Name: "p", Type: "panicky"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for e := range events {
+		d := e.Data.(map[string]interface{})
+		got = append(got, d["status"].(string))
+		if d["status"] == "error" {
+			if !strings.HasPrefix(d["error"].(string), "panic: ") || !strings.Contains(d["stack"].(string), "supervisor_test.go") {
+				t.Errorf("got %v; want the panic and its stack", d)
+			}
+		}
+	}
+	want := []string{"ok", "error", "ok", "error", "ok", "error", "failed"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestSupervisorCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := Supervisor{MaxCrashes: 3, MinBackoff: time.Hour, MaxBackoff: time.Hour}
+	events, err := s.Run(ctx, Policy{Name: "p", Type: "panicky"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-events // ok
+	<-events // error
+	cancel() // while backing off
+	for e := range events {
+		t.Errorf("got %v after the cancel", e)
+	}
+}
+
+func TestSupervisorPanicBeforeEvents(t *testing.T) {
+	_, err := DefaultSupervisor.Run(context.Background(), Policy{Name: "p", Type: "panicky", M: map[string]string{"panic": "now"}})
+	if err == nil || !strings.HasPrefix(err.Error(), "panic: ") {
+		t.Errorf("got error %v; want the panic", err)
+	}
+}